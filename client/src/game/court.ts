@@ -1,6 +1,6 @@
 // Shared constants — mirrors server/internal/game/state.go
 
-export const TICK_RATE = 60;
+export const TICK_RATE = 60; // the default; a room's own rate comes in GameStart
 export const DT = 1 / TICK_RATE;
 
 export const COURT_WIDTH = 960;
@@ -5,6 +5,7 @@ import {
   PlayerState,
   BallState,
   GamePhase,
+  isLivePhase,
   MsgGameState,
   MsgGameStart,
   MsgGameOver,
@@ -12,6 +13,21 @@ import {
   MsgPlayerDisconnected,
   MsgScored,
   MsgTournamentResult,
+  MsgReplayFrame,
+  MsgRematchOffer,
+  MsgJoinQueue,
+  MsgLeaveQueue,
+  MsgMatchPaused,
+  MsgMatchResumed,
+  MsgQueueStatus,
+  MsgRoomCode,
+  MatchPausedPayload,
+  QueueStatusPayload,
+  RoomCodePayload,
+  CloseRoomExpired,
+  CloseIdle,
+  CloseInviteExpired,
+  CloseInviteInvalid,
   Message,
   ScoredPayload,
   TournamentResultPayload,
@@ -20,6 +36,7 @@ import { GameSocket } from '../network/socket';
 import { InputManager } from './input';
 import { TouchController } from './touch';
 import { Interpolator } from './interpolation';
+import { TICK_RATE } from './court';
 
 export class Game {
   socket: GameSocket;
@@ -32,20 +49,39 @@ export class Game {
   lastScoreFlash: { scorer: number; points: number; time: number } | null = null;
   gameOverData: GameOverPayload | null = null;
   isTournament: boolean = false;
+  rules: string = ''; // rules preset announced in GameStart
+  tickRate: number = TICK_RATE; // room's simulation rate, announced in GameStart
   tournamentResult: TournamentResultPayload | null = null;
+  rematchRequested: boolean = false; // we pressed Play Again, waiting on the opponent
+  rematchOffered: boolean = false; // opponent pressed Play Again
+  sessionEnded: string | null = null; // why the server closed us, if it did on purpose
+  pausedUntil: number | null = null; // opponent dropped; performance.now() deadline for their return
+  queueStatus: QueueStatusPayload | null = null; // latest matchmaking update while waiting
+  leftQueue: boolean = false; // backed out of matchmaking; still connected
+  roomCode: string | null = null; // invite code while hosting a private room
   onScore: ((scorerIndex: number) => void) | null = null;
   private prevMoveX = 0;
   private prevJump = false;
   private prevShoot = false;
+  private inputSeq = 0; // increasing per sent input; server echoes it in state.inputSeq
   private interpolator = new Interpolator();
   private lastFrameTime = 0;
 
   constructor(socket: GameSocket, canvas: HTMLCanvasElement) {
     this.socket = socket;
-    this.input = new InputManager(canvas, () => this.isGameOver() || this.opponentDisconnected);
+    this.input = new InputManager(canvas, () => this.isGameOver() || this.opponentDisconnected || this.leftQueue);
 
     socket.onMessage((msg) => this.handleMessage(msg));
-    socket.onClose(() => this.resetState());
+    socket.onClose((code) => {
+      if (code === CloseRoomExpired) this.sessionEnded = 'MATCH CLOSED';
+      else if (code === CloseIdle) this.sessionEnded = 'DISCONNECTED (IDLE)';
+      else if (code === CloseInviteExpired) this.sessionEnded = 'ROOM CODE EXPIRED';
+      else if (code === CloseInviteInvalid) {
+        this.sessionEnded = 'ROOM NOT FOUND';
+        socket.roomCode = null; // trying again hosts our own room
+      }
+      this.resetState();
+    });
   }
 
   resetState(): void {
@@ -55,6 +91,9 @@ export class Game {
     this.gameOverData = null;
     this.lastScoreFlash = null;
     this.tournamentResult = null;
+    this.queueStatus = null;
+    this.leftQueue = false;
+    this.roomCode = null;
     this.interpolator.reset();
     // Don't reset opponentDisconnected or isTournament here — they're reset on new GameStart
   }
@@ -77,12 +116,24 @@ export class Game {
         this.gameOverData = null;
         this.opponentDisconnected = false;
         this.isTournament = payload.isTournament || false;
+        this.rules = payload.rules || '';
+        this.tickRate = payload.tickRate || TICK_RATE;
         this.tournamentResult = null;
+        this.rematchRequested = false;
+        this.rematchOffered = false;
+        this.sessionEnded = null;
+        this.pausedUntil = null;
+        this.queueStatus = null;
+        this.leftQueue = false;
+        this.roomCode = null;
+        this.socket.roomCode = null; // codes are single-use; reconnecting hosts a new room
+        this.socket.resumeToken = payload.resumeToken ?? null;
         this.interpolator.reset();
         console.log(`Game started! You are player ${this.playerIndex} (${this.playerNames[this.playerIndex]})${this.isTournament ? ' [TOURNAMENT]' : ''}`);
         break;
       }
-      case MsgGameState: {
+      case MsgGameState:
+      case MsgReplayFrame: {
         const serverState = msg.payload as GameStatePayload;
         this.state = serverState;
         this.interpolator.pushServerState(serverState);
@@ -96,11 +147,35 @@ export class Game {
       }
       case MsgGameOver: {
         this.gameOverData = msg.payload as GameOverPayload;
+        this.pausedUntil = null;
+        this.socket.resumeToken = null; // nothing left to resume
         console.log('Game over!', this.gameOverData);
         break;
       }
       case MsgPlayerDisconnected: {
         this.opponentDisconnected = true;
+        this.rematchOffered = false;
+        break;
+      }
+      case MsgMatchPaused: {
+        const paused = msg.payload as MatchPausedPayload;
+        this.pausedUntil = performance.now() + paused.grace * 1000;
+        break;
+      }
+      case MsgMatchResumed: {
+        this.pausedUntil = null;
+        break;
+      }
+      case MsgQueueStatus: {
+        this.queueStatus = msg.payload as QueueStatusPayload;
+        break;
+      }
+      case MsgRoomCode: {
+        this.roomCode = (msg.payload as RoomCodePayload).code;
+        break;
+      }
+      case MsgRematchOffer: {
+        this.rematchOffered = true;
         break;
       }
       case MsgTournamentResult: {
@@ -114,7 +189,7 @@ export class Game {
     if (!this.connected || !this.state || this.playerIndex < 0) return;
 
     // Don't send input during non-playing phases
-    if (this.state.phase !== GamePhase.Playing) return;
+    if (!isLivePhase(this.state.phase)) return;
 
     const input = this.input.getInput();
 
@@ -129,11 +204,37 @@ export class Game {
     const msg: Message = {
       type: MsgPlayerInput,
       tick: this.state.tick,
-      payload: input,
+      payload: { ...input, seq: ++this.inputSeq },
     };
     this.socket.send(msg);
   }
 
+  /**
+   * Play Again over the current connection. After a game over the server
+   * treats it as a rematch request; if the opponent already left, or we left
+   * the queue, it puts us back in the matchmaking queue.
+   */
+  requestPlayAgain(): void {
+    this.socket.send({ type: MsgJoinQueue, tick: 0, payload: { name: this.playerNames[this.playerIndex] ?? '' } });
+    if (this.leftQueue) {
+      this.leftQueue = false;
+    } else if (this.opponentDisconnected) {
+      this.opponentDisconnected = false;
+      this.resetState();
+    } else {
+      this.rematchRequested = true;
+    }
+  }
+
+  /** Leave matchmaking without closing the socket; Play Again rejoins. */
+  leaveQueue(): void {
+    if (this.connected || this.leftQueue || !this.socket.isOpen()) return;
+    this.socket.send({ type: MsgLeaveQueue, tick: 0, payload: {} });
+    this.leftQueue = true;
+    this.queueStatus = null;
+    this.roomCode = null; // withdrawn; Play Again hosts a new one
+  }
+
   getLocalPlayer(): PlayerState | null {
     if (!this.state || this.playerIndex < 0) return null;
     return this.state.players[this.playerIndex];
@@ -34,6 +34,12 @@ const nicknameOk = document.getElementById('nickname-ok')!;
 const nicknameError = document.getElementById('nickname-error')!;
 const tournamentBtn = document.getElementById('tournament-btn')!;
 const leaderboardBtn = document.getElementById('leaderboard-btn')!;
+const createRoomBtn = document.getElementById('create-room-btn')!;
+const joinRoomBtn = document.getElementById('join-room-btn')!;
+const roomCodeInput = document.getElementById('room-code-input') as HTMLInputElement;
+const botBtn = document.getElementById('bot-btn')!;
+const botDifficulty = document.getElementById('bot-difficulty') as HTMLSelectElement;
+const rulesSelect = document.getElementById('rules-select') as HTMLSelectElement;
 
 function hideOverlay(): void {
   overlay.classList.add('hidden');
@@ -44,7 +50,7 @@ function showOverlay(): void {
   nicknameInput.focus();
 }
 
-function tryStartWithMode(mode: string): void {
+function tryStartWithMode(mode: string, roomCode: string | null = null): void {
   const valid = validateNickname(nicknameInput.value);
   if (!valid) {
     nicknameError.textContent = '2-12 characters (letters, digits, _)';
@@ -53,7 +59,7 @@ function tryStartWithMode(mode: string): void {
   nicknameError.textContent = '';
   saveNickname(valid);
   hideOverlay();
-  startGame(valid, mode);
+  startGame(valid, mode, roomCode);
 }
 
 nicknameOk.addEventListener('click', () => tryStartWithMode(''));
@@ -64,6 +70,22 @@ nicknameInput.addEventListener('keydown', (e) => {
 tournamentBtn.addEventListener('click', () => tryStartWithMode('tournament'));
 leaderboardBtn.addEventListener('click', () => showLeaderboard());
 
+// Private rooms: create one and share the code, or join a friend's
+createRoomBtn.addEventListener('click', () => tryStartWithMode('private'));
+function tryJoinRoom(): void {
+  const code = roomCodeInput.value.trim().toUpperCase();
+  if (!/^[A-Z0-9]{5}$/.test(code)) {
+    nicknameError.textContent = 'Room codes are 5 letters/digits';
+    return;
+  }
+  tryStartWithMode('private', code);
+}
+joinRoomBtn.addEventListener('click', tryJoinRoom);
+botBtn.addEventListener('click', () => tryStartWithMode('bot'));
+roomCodeInput.addEventListener('keydown', (e) => {
+  if (e.key === 'Enter') tryJoinRoom();
+});
+
 // ── Game startup ──
 
 const canvas = document.getElementById('game') as HTMLCanvasElement;
@@ -78,7 +100,30 @@ function resizeCanvas() {
   canvas.style.height = `${Math.floor(COURT_HEIGHT * scale)}px`;
 }
 
-function startGame(nickname: string, mode: string = ''): void {
+// ── Snapshot rate ──
+
+interface NetworkInformation extends EventTarget {
+  effectiveType?: string;
+  saveData?: boolean;
+}
+
+function networkInfo(): NetworkInformation | undefined {
+  return (navigator as Navigator & { connection?: NetworkInformation }).connection;
+}
+
+// Slow or metered connections ask the server for fewer state snapshots;
+// ?rate=<hz> on the page overrides it.
+function preferredSnapshotRate(): number | null {
+  const param = Number(new URLSearchParams(location.search).get('rate'));
+  if (param > 0) return param;
+  const net = networkInfo();
+  if (net && (net.saveData || ['slow-2g', '2g', '3g'].includes(net.effectiveType ?? ''))) {
+    return 20;
+  }
+  return null;
+}
+
+function startGame(nickname: string, mode: string = '', roomCode: string | null = null): void {
   // Show canvas
   canvas.style.display = 'block';
   resizeCanvas();
@@ -94,6 +139,13 @@ function startGame(nickname: string, mode: string = ''): void {
   const wsUrl = `${wsProtocol}//${location.host}/ws`;
 
   const socket = new GameSocket(wsUrl, nickname, mode);
+  socket.roomCode = roomCode;
+  socket.botDifficulty = mode === 'bot' ? botDifficulty.value : null;
+  // Hosts pick the rules; queued games use the server's default preset.
+  const picksRules = mode === 'bot' || (mode === 'private' && !roomCode);
+  socket.rules = picksRules && rulesSelect.value ? rulesSelect.value : null;
+  socket.snapshotRate = preferredSnapshotRate();
+  networkInfo()?.addEventListener('change', () => socket.setSnapshotRate(preferredSnapshotRate()));
   const game = new Game(socket, canvas);
   game.isTournament = mode === 'tournament';
   const renderer = new Renderer(canvas);
@@ -108,15 +160,22 @@ function startGame(nickname: string, mode: string = ''): void {
 
   // ── Play Again / Next Match (keyboard + touch) ──
   function triggerPlayAgain(): void {
-    if (game.isGameOver() || game.opponentDisconnected) {
-      socket.disconnect();
-      game.opponentDisconnected = false;
-      setTimeout(() => socket.connect(), 300);
+    if (!(game.isGameOver() || game.opponentDisconnected || game.sessionEnded || game.leftQueue) || game.rematchRequested) return;
+    if (socket.isOpen()) {
+      // Stay on this connection: rematch, or back into the queue
+      game.requestPlayAgain();
+      return;
     }
+    socket.disconnect();
+    socket.resumeToken = null;
+    game.opponentDisconnected = false;
+    game.sessionEnded = null;
+    setTimeout(() => socket.connect(), 300);
   }
 
   window.addEventListener('keydown', (e) => {
     if (e.code === 'Enter') triggerPlayAgain();
+    if (e.code === 'Escape') game.leaveQueue();
   });
 
   game.getTouchController().setPlayAgainHandler(triggerPlayAgain);
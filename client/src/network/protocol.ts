@@ -1,7 +1,13 @@
 // Message type IDs — mirrors server/internal/ws/message.go
+// This client speaks the JSON codec only; the server's binary codec and the
+// delta snapshots that need it are for native/bot clients that ask for them.
 export const MsgPlayerInput = 0x01;
 export const MsgJoinQueue = 0x02;
 export const MsgPing = 0x04;
+export const MsgStateAck = 0x05; // { tick } — enables delta snapshots (binary codec only)
+export const MsgRequestKeyframe = 0x06;
+export const MsgLeaveQueue = 0x07; // stop matchmaking, keep the connection
+export const MsgSnapshotRate = 0x08; // { rate } — states per second we want; 0 = the server's
 
 export const MsgGameState = 0x81;
 export const MsgGameStart = 0x82;
@@ -10,6 +16,19 @@ export const MsgScored = 0x84;
 export const MsgPong = 0x86;
 export const MsgPlayerDisconnected = 0x87;
 export const MsgTournamentResult = 0x88;
+export const MsgReplayFrame = 0x89; // payload is GameStatePayload
+export const MsgGameStateDelta = 0x8a; // binary only
+export const MsgRematchOffer = 0x8b; // opponent pressed Play Again
+export const MsgMatchPaused = 0x8c; // a player dropped; game frozen while they may resume
+export const MsgMatchResumed = 0x8d;
+export const MsgQueueStatus = 0x8e; // where we stand in the matchmaking queue
+export const MsgRoomCode = 0x8f; // invite code for the private room we're hosting
+
+// Close codes for sessions the server ends on its own — don't auto-reconnect.
+export const CloseRoomExpired = 4000; // game-over screen timed out
+export const CloseIdle = 4001; // no input for too long
+export const CloseInviteExpired = 4002; // nobody joined our private room in time
+export const CloseInviteInvalid = 4003; // unknown, used or expired room code
 
 export interface Message {
   type: number;
@@ -21,12 +40,37 @@ export interface PlayerInputPayload {
   moveX: number;
   jump: boolean;
   shoot: boolean;
+  seq?: number; // increasing per input; echoed back in GameStatePayload.inputSeq
 }
 
 export interface GameStartPayload {
   playerIndex: number;
   names: [string, string];
   isTournament?: boolean;
+  seed: number; // room RNG seed
+  spectator?: boolean; // read-only viewer — server ignores input
+  resumeToken?: string; // reconnect to this seat with /ws?resume=<token>
+  rules?: string; // rules preset the room plays by, e.g. "classic", "quick"
+  snapshotRate?: number; // states per second we're sent; events still arrive right away
+  tickRate?: number; // ticks per second the room simulates; absent from older servers, which ran at 60
+}
+
+export interface QueueStatusPayload {
+  position: number; // 1 = next in line
+  queueSize: number;
+  elapsed: number; // seconds waited so far
+  estimatedWait: number; // seconds; 0 when the server has no estimate yet
+  fallback: boolean; // any rating (regular) or a repeat opponent (tournament) will do now
+}
+
+export interface RoomCodePayload {
+  code: string;
+  expiresIn: number; // seconds
+}
+
+export interface MatchPausedPayload {
+  playerIndex: number; // who dropped
+  grace: number; // seconds they have to come back
 }
 
 export interface TournamentPlayerStats {
@@ -36,11 +80,16 @@ export interface TournamentPlayerStats {
   draws: number;
   pointsFor: number;
   gamesPlayed: number;
+  forfeits: number;
+  rating: number;
+  ratingDeviation: number;
 }
 
 export interface TournamentResultPayload {
   yourStats: TournamentPlayerStats;
   opponentStats: TournamentPlayerStats;
+  yourRatingChange: number; // rating points gained (+) or lost (-) this game
+  opponentRatingChange: number;
 }
 
 export const enum GamePhase {
@@ -49,6 +98,16 @@ export const enum GamePhase {
   Playing = 2,
   Scored = 3,
   GameOver = 4,
+  // Playing, near the end of a game (see the server's Rules)
+  GamePoint = 5, // a basket can win it
+  WinByTwo = 6, // score target reached, lead under two
+  Overtime = 7, // sudden death after a tied regulation
+}
+
+// isLivePhase reports whether the ball is in play.
+export function isLivePhase(phase: GamePhase): boolean {
+  return phase === GamePhase.Playing || phase === GamePhase.GamePoint ||
+    phase === GamePhase.WinByTwo || phase === GamePhase.Overtime;
 }
 
 export const enum AnimState {
@@ -91,11 +150,14 @@ export interface GameStatePayload {
   shotClock: number;
   gameClock: number;
   winner: number; // -1=tie, 0=p1, 1=p2
+  inputSeq: [number, number]; // last input seq the server applied, per player
 }
 
 export interface GameOverPayload {
   winner: number;
   score: [number, number];
+  forfeit?: boolean; // loser dropped and didn't come back in time
+  reason?: string; // clock, score-target, win-by-two, overtime or forfeit
 }
 
 export interface ScoredPayload {
@@ -104,6 +166,10 @@ export interface ScoredPayload {
   newScore: [number, number];
 }
 
+export interface RematchOfferPayload {
+  playerIndex: number;
+}
+
 export interface PlayerDisconnectedPayload {
   playerIndex: number;
 }
@@ -1,7 +1,7 @@
-import { Message } from './protocol';
+import { CloseIdle, CloseInviteExpired, CloseInviteInvalid, CloseRoomExpired, Message, MsgSnapshotRate } from './protocol';
 
 export type MessageHandler = (msg: Message) => void;
-export type CloseHandler = () => void;
+export type CloseHandler = (code: number) => void;
 
 export class GameSocket {
   private ws: WebSocket | null = null;
@@ -10,6 +10,11 @@ export class GameSocket {
   private mode: string;
   private handler: MessageHandler | null = null;
   private closeHandler: CloseHandler | null = null;
+  resumeToken: string | null = null; // set while in a match, so a reconnect takes the same seat
+  roomCode: string | null = null; // private mode: join this room; null hosts a new one
+  botDifficulty: string | null = null; // bot mode: easy, normal or hard
+  rules: string | null = null; // rules preset for a hosted private room or a bot game
+  snapshotRate: number | null = null; // states per second to ask for; null takes the server's
   private reconnectTimer: number | null = null;
   private autoReconnect: boolean = true;
   private reconnectAttempts: number = 0;
@@ -30,6 +35,21 @@ export class GameSocket {
     if (this.mode) {
       url += `&mode=${encodeURIComponent(this.mode)}`;
     }
+    if (this.mode === 'private') {
+      url += this.roomCode ? `&code=${encodeURIComponent(this.roomCode)}` : '&create=1';
+    }
+    if (this.mode === 'bot' && this.botDifficulty) {
+      url += `&difficulty=${encodeURIComponent(this.botDifficulty)}`;
+    }
+    if (this.rules) {
+      url += `&rules=${encodeURIComponent(this.rules)}`;
+    }
+    if (this.resumeToken) {
+      url += `&resume=${encodeURIComponent(this.resumeToken)}`;
+    }
+    if (this.snapshotRate) {
+      url += `&rate=${this.snapshotRate}`;
+    }
     this.ws = new WebSocket(url);
 
     this.ws.onopen = () => {
@@ -52,10 +72,15 @@ export class GameSocket {
       }
     };
 
-    this.ws.onclose = () => {
-      console.log('WebSocket closed');
+    this.ws.onclose = (event) => {
+      console.log('WebSocket closed', event.code);
       if (this.closeHandler) {
-        this.closeHandler();
+        this.closeHandler(event.code);
+      }
+      // The server timed this session out on purpose; wait for the player.
+      if (event.code === CloseRoomExpired || event.code === CloseIdle ||
+          event.code === CloseInviteExpired || event.code === CloseInviteInvalid) {
+        this.autoReconnect = false;
       }
       if (this.autoReconnect) {
         this.scheduleReconnect();
@@ -75,12 +100,23 @@ export class GameSocket {
     this.closeHandler = handler;
   }
 
+  isOpen(): boolean {
+    return this.ws !== null && this.ws.readyState === WebSocket.OPEN;
+  }
+
   send(msg: Message): void {
     if (this.ws && this.ws.readyState === WebSocket.OPEN) {
       this.ws.send(JSON.stringify(msg));
     }
   }
 
+  /** Ask for a different snapshot rate mid-connection (null: the server's). */
+  setSnapshotRate(rate: number | null): void {
+    if (rate === this.snapshotRate) return;
+    this.snapshotRate = rate;
+    this.send({ type: MsgSnapshotRate, tick: 0, payload: { rate: rate ?? 0 } });
+  }
+
   private scheduleReconnect(): void {
     if (this.reconnectTimer !== null) return;
     if (this.reconnectAttempts >= GameSocket.MAX_RECONNECT_ATTEMPTS) {
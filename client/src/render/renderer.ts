@@ -3,10 +3,10 @@ import {
   COURT_WIDTH, COURT_HEIGHT, FLOOR_Y,
   PLAYER_WIDTH, PLAYER_HEIGHT, BALL_RADIUS,
   HOOP_LEFT_X, HOOP_RIGHT_X, HOOP_Y,
-  RIM_WIDTH, BACKBOARD_HEIGHT,
+  RIM_WIDTH, BACKBOARD_HEIGHT, TICK_RATE,
 } from '../game/court';
 import { drawRect, drawCircle, drawCircleOutline, drawLine, drawText, drawRectOutline } from './draw';
-import { PlayerState, BallState, AnimState, GamePhase, GameStatePayload } from '../network/protocol';
+import { PlayerState, BallState, AnimState, GamePhase, GameStatePayload, isLivePhase } from '../network/protocol';
 import { SpriteSet, buildSpriteSet, getSprite } from './sprites';
 import { ParticleSystem } from './particles';
 import { TouchController } from '../game/touch';
@@ -89,14 +89,14 @@ export class Renderer {
       // Draw particles behind players
       this.particles.draw(ctx);
 
-      this.drawPlayers(displayState.players, game.playerIndex, game.playerNames, displayState.tick, now);
+      this.drawPlayers(displayState.players, game.playerIndex, game.playerNames, displayState.tick, game.tickRate, now);
       this.drawBall(displayState.ball, dt);
 
       this.drawHUD(game, displayState);
 
       // Phase-specific overlays
       if (displayState.phase === GamePhase.Countdown) {
-        this.drawCountdown(displayState.phaseTimer);
+        this.drawCountdown(displayState.phaseTimer, game.rules);
       }
 
       if (displayState.phase === GamePhase.Scored) {
@@ -108,12 +108,16 @@ export class Renderer {
       }
     }
 
-    if (!game.connected) {
-      this.drawWaiting(now, game.isTournament);
+    if (game.sessionEnded) {
+      this.drawDisconnected(game, game.sessionEnded);
+    } else if (!game.connected) {
+      this.drawWaiting(now, game);
     }
 
     if (game.opponentDisconnected && !game.isGameOver()) {
       this.drawDisconnected(game);
+    } else if (game.pausedUntil !== null && !game.isGameOver()) {
+      this.drawPaused(Math.max(0, Math.ceil((game.pausedUntil - now) / 1000)));
     }
 
     // Score flash (during playing phase after a score)
@@ -253,13 +257,14 @@ export class Renderer {
 
   // ── Dynamic elements ──
 
-  private drawPlayers(players: [PlayerState, PlayerState], localIdx: number, names: [string, string], tick: number, now: number): void {
+  private drawPlayers(players: [PlayerState, PlayerState], localIdx: number, names: [string, string], tick: number, tickRate: number, now: number): void {
     const ctx = this.ctx;
-    const STEAL_CD_MAX = 30; // must match server StealCooldownTicks
+    const STEAL_CD_MAX = tickRate / 2; // must match server stealCooldownSecs (0.5 s)
+    const animTick = Math.floor((tick * TICK_RATE) / tickRate); // sprites are timed in 60 Hz ticks
 
     for (let i = 0; i < 2; i++) {
       const p = players[i];
-      const sprite = getSprite(this.spriteSets[i], p.anim, p.facing, tick);
+      const sprite = getSprite(this.spriteSets[i], p.anim, p.facing, animTick);
 
       const x = Math.floor(p.x - PLAYER_WIDTH / 2);
       const y = Math.floor(p.y - PLAYER_HEIGHT / 2);
@@ -415,27 +420,33 @@ export class Renderer {
     // Game clock
     const mins = Math.floor(Math.max(0, s.gameClock) / 60);
     const secs = Math.floor(Math.max(0, s.gameClock) % 60);
-    const clockText = `${mins}:${secs.toString().padStart(2, '0')}`;
+    const clockText = s.phase === GamePhase.Overtime ? 'OT' : `${mins}:${secs.toString().padStart(2, '0')}`;
     const clockColor = s.gameClock <= 30 ? '#EF4444' : '#94A3B8';
     drawText(ctx, clockText, COURT_WIDTH / 2, 58, clockColor, 12, 'center');
 
     // Shot clock
-    if (s.phase === GamePhase.Playing) {
+    if (isLivePhase(s.phase)) {
       const shotSecs = Math.ceil(s.shotClock);
       const shotColor = s.shotClock <= 5 ? '#EF4444' : s.shotClock <= 10 ? '#FBBF24' : '#94A3B8';
       const shotSize = s.shotClock <= 5 ? 16 : 13;
       drawText(ctx, `${shotSecs}`, COURT_WIDTH / 2, 74, shotColor, shotSize, 'center');
     }
 
+    const banner = PHASE_BANNERS[s.phase];
+    if (banner) {
+      drawText(ctx, banner, COURT_WIDTH / 2, 92, '#FBBF24', 11, 'center');
+    }
+
     // Controls hint (fades out) — skip on touch devices (controls are visible)
-    if (s.phase === GamePhase.Playing && s.tick < 300 && !game.getTouchController().isEnabled()) {
-      ctx.globalAlpha = Math.max(0, 1 - s.tick / 300);
+    const hintTicks = 5 * game.tickRate;
+    if (isLivePhase(s.phase) && s.tick < hintTicks && !game.getTouchController().isEnabled()) {
+      ctx.globalAlpha = Math.max(0, 1 - s.tick / hintTicks);
       drawText(ctx, 'A/D: Move  W: Jump  Space: Shoot', COURT_WIDTH / 2, COURT_HEIGHT - 10, '#64748B', 10, 'center');
       ctx.globalAlpha = 1;
     }
   }
 
-  private drawCountdown(timer: number): void {
+  private drawCountdown(timer: number, rules: string): void {
     const ctx = this.ctx;
 
     ctx.fillStyle = 'rgba(0, 0, 0, 0.5)';
@@ -457,6 +468,9 @@ export class Renderer {
       ctx.globalAlpha = 1;
 
       drawText(ctx, 'GET READY!', COURT_WIDTH / 2, COURT_HEIGHT / 2 + 50, '#94A3B8', 18, 'center');
+      if (rules && rules !== 'classic') {
+        drawText(ctx, rulesLabel(rules), COURT_WIDTH / 2, COURT_HEIGHT / 2 + 80, '#0EA5E9', 14, 'center');
+      }
     } else {
       drawText(ctx, 'GO!', COURT_WIDTH / 2, COURT_HEIGHT / 2, '#22C55E', 64, 'center');
     }
@@ -515,36 +529,55 @@ export class Renderer {
       } else {
         drawText(ctx, "IT'S A TIE!", COURT_WIDTH / 2, 260, '#FBBF24', 36, 'center');
       }
+      const reason = GAME_OVER_REASONS[game.gameOverData?.forfeit ? 'forfeit' : game.gameOverData?.reason ?? ''];
+      if (reason) {
+        drawText(ctx, reason, COURT_WIDTH / 2, 230, '#64748B', 12, 'center');
+      }
     }
 
     // Tournament stats
     if (game.isTournament && game.tournamentResult) {
       const tr = game.tournamentResult;
       drawText(ctx, 'TOURNAMENT', COURT_WIDTH / 2, 310, '#8B5CF6', 14, 'center');
-      drawText(ctx, `Record: ${tr.yourStats.wins}W ${tr.yourStats.losses}L ${tr.yourStats.draws}D  |  Total Pts: ${tr.yourStats.pointsFor}`,
+      const delta = Math.round(tr.yourRatingChange);
+      drawText(ctx, `Record: ${tr.yourStats.wins}W ${tr.yourStats.losses}L ${tr.yourStats.draws}D  |  Total Pts: ${tr.yourStats.pointsFor}  |  Rating: ${Math.round(tr.yourStats.rating)} (${delta >= 0 ? '+' : ''}${delta})`,
         COURT_WIDTH / 2, 328, '#94A3B8', 11, 'center');
     }
 
     const pulse = 0.5 + Math.sin(now / 500) * 0.5;
     ctx.globalAlpha = 0.6 + pulse * 0.4;
     const isTouch = game.getTouchController().isEnabled();
-    const restartHint = game.isTournament
+    let restartHint = game.isTournament
       ? (isTouch ? 'Tap for next match' : 'Press ENTER for next match')
       : (isTouch ? 'Tap to play again' : 'Press ENTER to play again');
+    if (game.rematchRequested) {
+      restartHint = 'Waiting for opponent...';
+    } else if (game.rematchOffered) {
+      const what = game.isTournament ? 'an unrated rematch' : 'a rematch';
+      restartHint = isTouch ? `Opponent wants ${what}! Tap to accept` : `Opponent wants ${what}! Press ENTER`;
+    }
     drawText(ctx, restartHint, COURT_WIDTH / 2, 350, '#94A3B8', 16, 'center');
     ctx.globalAlpha = 1;
   }
 
-  private drawDisconnected(game?: Game): void {
+  private drawPaused(secondsLeft: number): void {
     const ctx = this.ctx;
     ctx.fillStyle = 'rgba(0, 0, 0, 0.6)';
     ctx.fillRect(0, 0, COURT_WIDTH, COURT_HEIGHT);
-    drawText(ctx, 'OPPONENT LEFT', COURT_WIDTH / 2, COURT_HEIGHT / 2 - 10, '#EF4444', 32, 'center');
+    drawText(ctx, 'OPPONENT RECONNECTING', COURT_WIDTH / 2, COURT_HEIGHT / 2 - 10, '#FBBF24', 28, 'center');
+    drawText(ctx, `Forfeit in ${secondsLeft}s`, COURT_WIDTH / 2, COURT_HEIGHT / 2 + 25, '#94A3B8', 14, 'center');
+  }
+
+  private drawDisconnected(game?: Game, title: string = 'OPPONENT LEFT'): void {
+    const ctx = this.ctx;
+    ctx.fillStyle = 'rgba(0, 0, 0, 0.6)';
+    ctx.fillRect(0, 0, COURT_WIDTH, COURT_HEIGHT);
+    drawText(ctx, title, COURT_WIDTH / 2, COURT_HEIGHT / 2 - 10, '#EF4444', 32, 'center');
     const hint = game?.getTouchController().isEnabled() ? 'Tap to find a new match' : 'Press ENTER to find a new match';
     drawText(ctx, hint, COURT_WIDTH / 2, COURT_HEIGHT / 2 + 25, '#94A3B8', 14, 'center');
   }
 
-  private drawWaiting(now: number, isTournament: boolean = false): void {
+  private drawWaiting(now: number, game: Game): void {
     const ctx = this.ctx;
     ctx.fillStyle = 'rgba(0, 0, 0, 0.7)';
     ctx.fillRect(0, 0, COURT_WIDTH, COURT_HEIGHT);
@@ -552,7 +585,7 @@ export class Renderer {
     drawText(ctx, 'PIXEL', COURT_WIDTH / 2, COURT_HEIGHT / 2 - 70, '#FFD700', 40, 'center');
     drawText(ctx, 'BASKETBALL', COURT_WIDTH / 2, COURT_HEIGHT / 2 - 30, '#FFD700', 40, 'center');
 
-    if (isTournament) {
+    if (game.isTournament) {
       drawText(ctx, 'TOURNAMENT MODE', COURT_WIDTH / 2, COURT_HEIGHT / 2 - 100, '#8B5CF6', 16, 'center');
     }
 
@@ -561,10 +594,27 @@ export class Renderer {
     drawCircle(ctx, COURT_WIDTH / 2, ballY + bounce, 10, BALL_COLOR);
     drawCircleOutline(ctx, COURT_WIDTH / 2, ballY + bounce, 10, '#C2410C', 1);
 
-    const dots = '.'.repeat(Math.floor(now / 500) % 4);
-    drawText(ctx, `Waiting for opponent${dots}`, COURT_WIDTH / 2, COURT_HEIGHT / 2 + 50, '#94A3B8', 16, 'center');
+    const touch = game.getTouchController().isEnabled();
+    if (game.leftQueue) {
+      drawText(ctx, 'Left the queue', COURT_WIDTH / 2, COURT_HEIGHT / 2 + 50, '#94A3B8', 16, 'center');
+      drawText(ctx, touch ? 'Tap to search again' : 'Press ENTER to search again', COURT_WIDTH / 2, COURT_HEIGHT / 2 + 68, '#64748B', 11, 'center');
+    } else {
+      const dots = '.'.repeat(Math.floor(now / 500) % 4);
+      drawText(ctx, `Waiting for opponent${dots}`, COURT_WIDTH / 2, COURT_HEIGHT / 2 + 50, '#94A3B8', 16, 'center');
+      const queue = game.queueStatus;
+      if (game.roomCode) {
+        drawText(ctx, `Room code: ${game.roomCode}  —  share it with a friend`, COURT_WIDTH / 2, COURT_HEIGHT / 2 + 68, '#FFD700', 11, 'center');
+      } else if (queue) {
+        const eta = queue.estimatedWait > 0 ? `  |  ~${queue.estimatedWait}s left` : '';
+        const fallback = queue.fallback ? (game.isTournament ? '  |  rematches allowed' : '  |  any opponent') : '';
+        drawText(ctx, `#${queue.position} of ${queue.queueSize}  |  ${queue.elapsed}s${eta}${fallback}`,
+          COURT_WIDTH / 2, COURT_HEIGHT / 2 + 68, '#64748B', 11, 'center');
+      }
+    }
 
-    drawText(ctx, 'A/D: Move  |  W: Jump  |  Space: Shoot', COURT_WIDTH / 2, COURT_HEIGHT / 2 + 85, '#475569', 11, 'center');
+    const leave = game.roomCode ? 'Esc: Cancel room' : 'Esc: Leave queue';
+    const controls = game.leftQueue || touch ? 'A/D: Move  |  W: Jump  |  Space: Shoot' : `A/D: Move  |  W: Jump  |  Space: Shoot  |  ${leave}`;
+    drawText(ctx, controls, COURT_WIDTH / 2, COURT_HEIGHT / 2 + 85, '#475569', 11, 'center');
   }
 
   // ── Touch controls overlay ──
@@ -627,3 +677,31 @@ export class Renderer {
     drawText(ctx, 'Landscape mode required', COURT_WIDTH / 2, COURT_HEIGHT / 2 + 40, '#94A3B8', 14, 'center');
   }
 }
+
+const PHASE_BANNERS: Partial<Record<GamePhase, string>> = {
+  [GamePhase.GamePoint]: 'GAME POINT',
+  [GamePhase.WinByTwo]: 'WIN BY TWO',
+  [GamePhase.Overtime]: 'SUDDEN DEATH',
+};
+
+// Shown under the final score; a plain clock finish needs no note.
+const GAME_OVER_REASONS: Record<string, string> = {
+  'forfeit': 'by forfeit',
+  'score-target': 'first to the target',
+  'win-by-two': 'won by two',
+  'overtime': 'in overtime',
+};
+
+const RULES_LABELS: Record<string, string> = {
+  'quick': 'QUICK 60S',
+  'first-to-21': 'FIRST TO 21',
+  'win-by-two': 'TO 21, WIN BY 2',
+  'overtime': 'SUDDEN DEATH OT',
+  'no-steals': 'NO STEALS',
+};
+
+// rulesLabel names a rules preset for the countdown screen; presets from a
+// server rules file fall back to their own name.
+function rulesLabel(rules: string): string {
+  return RULES_LABELS[rules] ?? rules.replace(/-/g, ' ').toUpperCase();
+}
@@ -6,15 +6,22 @@ export interface LeaderboardEntry {
   pointsFor: number;
   pointsAgainst: number;
   gamesPlayed: number;
+  forfeits: number;
+  rating: number;
+  ratingDeviation: number;
 }
 
 const overlay = document.getElementById('leaderboard-overlay')!;
 const content = document.getElementById('leaderboard-content')!;
 const tabWins = document.getElementById('lb-tab-wins')!;
 const tabPoints = document.getElementById('lb-tab-points')!;
+const tabRating = document.getElementById('lb-tab-rating')!;
 const closeBtn = document.getElementById('leaderboard-close')!;
 
-let currentSort: 'wins' | 'points' = 'wins';
+type SortKey = 'wins' | 'points' | 'rating';
+const tabs: Record<SortKey, HTMLElement> = { wins: tabWins, points: tabPoints, rating: tabRating };
+
+let currentSort: SortKey = 'wins';
 
 export function showLeaderboard(): void {
   overlay.classList.remove('hidden');
@@ -25,19 +32,15 @@ export function hideLeaderboard(): void {
   overlay.classList.add('hidden');
 }
 
-tabWins.addEventListener('click', () => {
-  currentSort = 'wins';
-  tabWins.classList.add('active');
-  tabPoints.classList.remove('active');
-  fetchLeaderboard('wins');
-});
-
-tabPoints.addEventListener('click', () => {
-  currentSort = 'points';
-  tabPoints.classList.add('active');
-  tabWins.classList.remove('active');
-  fetchLeaderboard('points');
-});
+for (const key of Object.keys(tabs) as SortKey[]) {
+  tabs[key].addEventListener('click', () => {
+    currentSort = key;
+    for (const k of Object.keys(tabs) as SortKey[]) {
+      tabs[k].classList.toggle('active', k === key);
+    }
+    fetchLeaderboard(key);
+  });
+}
 
 closeBtn.addEventListener('click', hideLeaderboard);
 
@@ -58,17 +61,22 @@ function renderTable(entries: LeaderboardEntry[], sort: string): void {
     return;
   }
 
-  const header = sort === 'points'
-    ? '<th>#</th><th>Player</th><th>PTS</th><th>W</th><th>L</th><th>D</th><th>GP</th>'
-    : '<th>#</th><th>Player</th><th>W</th><th>L</th><th>D</th><th>PTS</th><th>GP</th>';
+  const header = sort === 'rating'
+    ? '<th>#</th><th>Player</th><th>RATING</th><th>±</th><th>W</th><th>L</th><th>D</th><th>GP</th>'
+    : sort === 'points'
+    ? '<th>#</th><th>Player</th><th>PTS</th><th>W</th><th>L</th><th>D</th><th>GP</th><th>FF</th>'
+    : '<th>#</th><th>Player</th><th>W</th><th>L</th><th>D</th><th>PTS</th><th>GP</th><th>FF</th>';
 
   const rows = entries.map((e, i) => {
     const rank = i + 1;
     const name = escapeHtml(e.nickname);
+    if (sort === 'rating') {
+      return `<tr><td>${rank}</td><td>${name}</td><td>${e.rating}</td><td>${e.ratingDeviation}</td><td>${e.wins}</td><td>${e.losses}</td><td>${e.draws}</td><td>${e.gamesPlayed}</td></tr>`;
+    }
     if (sort === 'points') {
-      return `<tr><td>${rank}</td><td>${name}</td><td>${e.pointsFor}</td><td>${e.wins}</td><td>${e.losses}</td><td>${e.draws}</td><td>${e.gamesPlayed}</td></tr>`;
+      return `<tr><td>${rank}</td><td>${name}</td><td>${e.pointsFor}</td><td>${e.wins}</td><td>${e.losses}</td><td>${e.draws}</td><td>${e.gamesPlayed}</td><td>${e.forfeits ?? 0}</td></tr>`;
     }
-    return `<tr><td>${rank}</td><td>${name}</td><td>${e.wins}</td><td>${e.losses}</td><td>${e.draws}</td><td>${e.pointsFor}</td><td>${e.gamesPlayed}</td></tr>`;
+    return `<tr><td>${rank}</td><td>${name}</td><td>${e.wins}</td><td>${e.losses}</td><td>${e.draws}</td><td>${e.pointsFor}</td><td>${e.gamesPlayed}</td><td>${e.forfeits ?? 0}</td></tr>`;
   }).join('');
 
   content.innerHTML = `<table><thead><tr>${header}</tr></thead><tbody>${rows}</tbody></table>`;
@@ -3,17 +3,24 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	rtmetrics "runtime/metrics"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/vladimirvolkov/basketball/server/internal/game"
+	"github.com/vladimirvolkov/basketball/server/internal/logging"
+	"github.com/vladimirvolkov/basketball/server/internal/metrics"
 	"github.com/vladimirvolkov/basketball/server/internal/middleware"
 	"github.com/vladimirvolkov/basketball/server/internal/ws"
 )
@@ -33,29 +40,129 @@ func securityHeaders(next http.Handler) http.Handler {
 type GameManager struct {
 	hub        *ws.Hub
 	tournament *game.Tournament
+	form       *game.RecentForm // casual results, for pairing the regular queue
 	engine     *game.Engine
+	replays    *game.ReplayStore // nil disables recording
+	timeouts   game.RoomTimeouts
+	rules      *game.RuleBook
+	snapRate   int // states per second a room sends at most
 }
 
+// CreateRoom starts a queue match, or a private one under the host's (p1's)
+// choice of rules.
 func (gm *GameManager) CreateRoom(p1, p2 *ws.Conn) {
-	room := game.NewRoom(p1, p2)
-	room.Start(context.Background())
-	gm.engine.AddRoom(room)
-	go func() {
-		<-room.Done()
-		gm.hub.RoomEnded()
-	}()
+	rules := gm.defaultRules()
+	if p1.Mode == "private" {
+		rules = gm.rulesFor(p1)
+	}
+	room := game.NewRoom(game.NewWSEndpoint(p1), game.NewWSEndpoint(p2))
+	room.SetRecentForm(gm.form)
+	gm.startRoom(room, rules)
 }
 
+// CreateTournamentRoom always plays the default rules, so rated games stay
+// comparable, with sudden-death overtime so they can't end in a draw.
 func (gm *GameManager) CreateTournamentRoom(p1, p2 *ws.Conn) {
-	room := game.NewTournamentRoom(p1, p2, gm.tournament)
+	rules := gm.defaultRules()
+	rules.SuddenDeath = true
+	gm.startRoom(game.NewTournamentRoom(game.NewWSEndpoint(p1), game.NewWSEndpoint(p2), gm.tournament), rules)
+}
+
+func (gm *GameManager) CreateBotRoom(p *ws.Conn, difficulty string) {
+	level, ok := game.ParseBotDifficulty(difficulty)
+	if !ok {
+		p.Log.Warn("unknown bot difficulty", "difficulty", difficulty, "using", level.String())
+	}
+	gm.startRoom(game.NewBotRoom(game.NewWSEndpoint(p), level), gm.rulesFor(p))
+}
+
+func (gm *GameManager) defaultRules() game.Rules {
+	rules, _ := gm.rules.Preset("")
+	return rules
+}
+
+// rulesFor looks up the preset a player asked for, falling back to the
+// default for unknown names.
+func (gm *GameManager) rulesFor(p *ws.Conn) game.Rules {
+	rules, ok := gm.rules.Preset(p.Rules)
+	if !ok {
+		p.Log.Warn("unknown rules preset", "rules", p.Rules, "using", gm.rules.Default)
+		return gm.defaultRules()
+	}
+	return rules
+}
+
+func (gm *GameManager) startRoom(room *game.Room, rules game.Rules) {
+	room.SetRules(rules)
+	room.SetTimeouts(gm.timeouts)
+	room.SetSnapshotRate(gm.snapRate)
+	if gm.replays != nil {
+		room.EnableReplay(gm.replays)
+	}
 	room.Start(context.Background())
 	gm.engine.AddRoom(room)
 	go func() {
 		<-room.Done()
 		gm.hub.RoomEnded()
+		gm.releasePlayers(room)
 	}()
 }
 
+// releasePlayers hands a finished room's connections back to the hub: a
+// mutual rematch gets a fresh room, a lone Play Again goes back to the queue,
+// timed-out players are disconnected, and anyone else still connected waits
+// in the lobby. The hub takes them over only once the room's read loops have
+// exited, so the room can't swallow a message meant for what comes next.
+func (gm *GameManager) releasePlayers(room *game.Room) {
+	conns, ends, released := room.Conns(), room.Ends(), room.Released()
+	if ends[0] == game.EndRematch && ends[1] == game.EndRematch {
+		if bot := room.Bot(); bot != nil {
+			gm.hub.RematchBot(conns[0], bot.Level().String(), released)
+			return
+		}
+		gm.hub.Rematch(conns[0], conns[1], released)
+		return
+	}
+	for i, c := range conns {
+		if c == nil {
+			continue // bot seat
+		}
+		switch ends[i] {
+		case game.EndRequeue:
+			gm.hub.Requeue(c, released)
+		case game.EndLobby:
+			gm.hub.Lobby(c, released)
+		case game.EndExpired:
+			go c.CloseWith(ws.CloseRoomExpired, "game over")
+		case game.EndIdle:
+			go c.CloseWith(ws.CloseIdle, "idle")
+		}
+	}
+}
+
+// Spectate attaches a read-only viewer to a live room.
+func (gm *GameManager) Spectate(conn *ws.Conn, roomID string) error {
+	return gm.engine.Spectate(game.NewWSEndpoint(conn), roomID)
+}
+
+// Resume rebinds a reconnecting player to its seat.
+func (gm *GameManager) Resume(conn *ws.Conn, token string) error {
+	return gm.engine.Resume(game.NewWSEndpoint(conn), token)
+}
+
+// StreamReplay plays a saved replay to a viewer connection.
+func (gm *GameManager) StreamReplay(conn *ws.Conn, id string) error {
+	if gm.replays == nil {
+		return game.ErrReplayNotFound
+	}
+	rep, err := gm.replays.Load(id)
+	if err != nil {
+		return err
+	}
+	game.StreamReplay(context.Background(), game.NewWSEndpoint(conn), rep)
+	return nil
+}
+
 func main() {
 	// Use all available CPU cores for game loop parallelism
 	runtime.GOMAXPROCS(runtime.NumCPU())
@@ -65,8 +172,15 @@ func main() {
 	// GOGC=400 lets heap grow 4× before collecting, trading memory for lower latency.
 	debug.SetGCPercent(400)
 
-	// Write logs to stdout so Railway doesn't mark them as errors
-	log.SetOutput(os.Stdout)
+	// Write logs to stdout so Railway doesn't mark them as errors.
+	// LOG_LEVEL is debug, info (default), warn or error; debug adds
+	// play-by-play. LOG_FORMAT is text (default) or json.
+	logger, err := logging.New(os.Stdout, os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -92,14 +206,82 @@ func main() {
 	limiter := middleware.NewIPRateLimiter(200, 300, time.Second, trustProxy)
 
 	// Multi-core game engine: one worker per CPU core, each pinned to an OS thread.
-	// All game rooms are distributed across workers and ticked in parallel at 60 Hz.
+	// All game rooms are distributed across workers and ticked in parallel, at
+	// the fastest rules preset's tick rate (started below, once presets load).
+	// ENGINE_DEGRADE picks what a saturated worker gives up (see loadPolicy).
 	engine := game.NewEngine(runtime.NumCPU())
+	engine.SetLoadPolicy(loadPolicy())
+
+	store, err := openTournamentStore()
+	if err != nil {
+		fatal("tournament store", "err", err)
+	}
+	tournament, err := game.NewTournament(store)
+	if err != nil {
+		fatal("tournament", "err", err)
+	}
+	if v := os.Getenv("FORFEIT_SCORE"); v != "" {
+		var w, l uint8
+		if _, err := fmt.Sscanf(v, "%d-%d", &w, &l); err != nil {
+			fatal("FORFEIT_SCORE: want winner-loser, e.g. 20-0", "value", v)
+		}
+		tournament.SetForfeitScore(w, l)
+	}
+	replays, err := openReplayStore()
+	if err != nil {
+		slog.Warn("replays disabled", "err", err)
+		replays = nil
+	}
+
+	timeouts := game.RoomTimeouts{
+		GameOver:  envDuration("GAMEOVER_TIMEOUT", game.DefaultRoomTimeouts.GameOver),
+		Idle:      envDuration("ROOM_IDLE_TIMEOUT", game.DefaultRoomTimeouts.Idle),
+		Reconnect: envDuration("RECONNECT_GRACE", game.DefaultRoomTimeouts.Reconnect),
+	}
+
+	// Rules presets: the built-ins plus any from RULES_FILE; RULES_PRESET
+	// picks the one queue and tournament games use.
+	rules := game.DefaultRuleBook()
+	if path := os.Getenv("RULES_FILE"); path != "" {
+		if rules, err = game.LoadRuleBook(path); err != nil {
+			fatal("rules", "err", err)
+		}
+	}
+	if name := os.Getenv("RULES_PRESET"); name != "" {
+		if _, ok := rules.Preset(name); !ok {
+			fatal("RULES_PRESET: unknown preset", "value", name, "want", strings.Join(rules.Names(), ", "))
+		}
+		rules.Default = name
+	}
+	slog.Info("default rules", "rules", rules.Default)
+	engine.SetTickRate(rules.MaxTickRate())
 	engine.Start(context.Background())
 
-	tournament := game.NewTournament()
-	manager := &GameManager{tournament: tournament, engine: engine}
-	hub := ws.NewHub(manager, limiter, originPatterns, tournament)
+	// Rooms simulate at their rules' tickRate and send state at up to
+	// SNAPSHOT_RATE per second (every tick by default); clients may ask for
+	// fewer with /ws?rate=.
+	var snapRate int
+	if v := os.Getenv("SNAPSHOT_RATE"); v != "" {
+		if snapRate, err = strconv.Atoi(v); err != nil || snapRate < game.MinSnapshotRate || snapRate > game.MaxTickRate {
+			fatal("SNAPSHOT_RATE: want states per second", "value", v, "min", game.MinSnapshotRate, "max", game.MaxTickRate)
+		}
+	}
+
+	manager := &GameManager{tournament: tournament, form: game.NewRecentForm(tournament), engine: engine, replays: replays, timeouts: timeouts, rules: rules, snapRate: snapRate}
+	hub := ws.NewHub(manager, limiter, originPatterns, tournament, manager, manager)
 	manager.hub = hub
+	hub.SetLoadShedder(engine)
+	hub.SetSkillRater(manager.form)
+
+	// A lone player in the regular queue gets a bot after BOT_WAIT ("0" disables).
+	botDifficulty := os.Getenv("BOT_DIFFICULTY")
+	if botDifficulty == "" {
+		botDifficulty = ws.DefaultBotDifficulty
+	}
+	if _, ok := game.ParseBotDifficulty(botDifficulty); !ok {
+		fatal("BOT_DIFFICULTY: want easy, normal or hard", "value", botDifficulty)
+	}
+	hub.SetBotFallback(envDuration("BOT_WAIT", 30*time.Second), botDifficulty)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", hub.HandleWS)
@@ -111,6 +293,11 @@ func main() {
 		json.NewEncoder(w).Encode(stats)
 	})
 
+	// Prometheus scrape endpoint; the hub and runtime numbers are read on
+	// each scrape, everything else is counted as it happens.
+	registerMetrics(hub)
+	mux.Handle("/metrics", metrics.Handler())
+
 	// Tournament leaderboard endpoint
 	mux.HandleFunc("/tournament/leaderboard", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -118,14 +305,62 @@ func main() {
 		limit := 20
 
 		var entries []game.LeaderboardEntry
-		if sortBy == "points" {
+		switch sortBy {
+		case "points":
 			entries = tournament.LeaderboardByPoints(limit)
-		} else {
+		case "rating":
+			entries = tournament.LeaderboardByRating(limit)
+		default:
 			entries = tournament.LeaderboardByWins(limit)
 		}
 		json.NewEncoder(w).Encode(entries)
 	})
 
+	// Rules presets a host can pick with /ws?rules=<name>
+	mux.HandleFunc("/rules", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Default string   `json:"default"`
+			Presets []string `json:"presets"`
+		}{rules.Default, rules.Names()})
+	})
+
+	// Live rooms, for picking a game to spectate via /ws?mode=spectate&room=<id>
+	mux.HandleFunc("/rooms", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		rooms := engine.Rooms()
+		if rooms == nil {
+			rooms = []game.RoomInfo{}
+		}
+		json.NewEncoder(w).Encode(rooms)
+	})
+
+	// Per-worker room count and tick cost, to watch the engine's balance
+	mux.HandleFunc("/engine", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(engine.Load())
+	})
+
+	// Replay metadata (seed, names, key events). Watch via /ws?mode=replay&id=<id>.
+	mux.HandleFunc("GET /replays/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if replays == nil {
+			http.NotFound(w, r)
+			return
+		}
+		rep, err := replays.Load(r.PathValue("id"))
+		if errors.Is(err, game.ErrReplayNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			slog.Error("replay load failed", "replay", r.PathValue("id"), "err", err)
+			http.Error(w, "replay unavailable", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rep)
+	})
+
 	// Static files with no-cache headers (prevents stale JS in browser)
 	fs := http.FileServer(http.Dir(staticDir))
 	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -150,14 +385,150 @@ func main() {
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 		<-sigCh
-		log.Println("shutting down...")
+		slog.Info("shutting down")
 		server.Close()
 	}()
 
-	log.Printf("Pixel Basketball server starting on :%s", port)
-	log.Printf("serving static files from %s", staticDir)
+	slog.Info("Pixel Basketball server starting", "port", port, "static", staticDir)
 	if err := server.ListenAndServe(); err != http.ErrServerClosed {
-		log.Fatalf("server error: %v", err)
+		fatal("server error", "err", err)
+	}
+	// Stop ticking before closing the store, so no room records a result
+	// into a closed one; queued results are written out first.
+	engine.Stop()
+	if err := tournament.Close(); err != nil {
+		slog.Error("tournament store close", "err", err)
+	}
+	slog.Info("server stopped")
+}
+
+// fatal logs at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// registerMetrics exposes HubStats and Go runtime gauges on /metrics.
+func registerMetrics(hub *ws.Hub) {
+	metrics.NewGaugeFunc("basketball_active_rooms", "Rooms being played.", func() float64 {
+		return float64(hub.Stats().ActiveRooms)
+	})
+	metrics.NewCounterFunc("basketball_connections_total", "WebSocket connections accepted.", func() float64 {
+		return float64(hub.Stats().TotalConnections)
+	})
+	metrics.NewGaugeFunc("basketball_queued_players", "Players waiting for a match.", func() float64 {
+		return float64(hub.Stats().WaitingPlayers)
+	}, "queue", "regular")
+	metrics.NewGaugeFunc("basketball_queued_players", "Players waiting for a match.", func() float64 {
+		return float64(hub.Stats().TournamentQueueSize)
+	}, "queue", "tournament")
+	metrics.NewGaugeFunc("basketball_private_rooms_waiting", "Private room hosts waiting for a friend.", func() float64 {
+		return float64(hub.Stats().PrivateRooms)
+	})
+
+	metrics.NewGaugeFunc("go_goroutines", "Number of goroutines.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	// runtime/metrics rather than ReadMemStats, which stops the world.
+	metrics.NewGaugeFunc("go_memstats_heap_alloc_bytes", "Bytes of allocated heap objects.", func() float64 {
+		sample := []rtmetrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+		rtmetrics.Read(sample)
+		return float64(sample[0].Value.Uint64())
+	})
+}
+
+// dataDir returns DATA_DIR, where tournament results and replays are kept (default "data").
+func dataDir() string {
+	if dir := os.Getenv("DATA_DIR"); dir != "" {
+		return dir
+	}
+	return "data"
+}
+
+// envDuration reads a duration such as "30s" from the environment.
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		fatal("bad duration", "name", name, "err", err)
+	}
+	return d
+}
+
+// loadPolicy reads how the engine degrades under load. ENGINE_DEGRADE is a
+// comma-separated list of "halfrate" (send state at 30 Hz from saturated
+// workers) and "refuse" (turn new rooms away while all workers are
+// saturated); empty means neither. ENGINE_SATURATION is the fraction of the
+// tick budget past which a worker counts as saturated.
+func loadPolicy() game.LoadPolicy {
+	var p game.LoadPolicy
+	for _, v := range strings.Split(os.Getenv("ENGINE_DEGRADE"), ",") {
+		switch strings.TrimSpace(v) {
+		case "":
+		case "halfrate":
+			p.HalfRateBroadcast = true
+		case "refuse":
+			p.RefuseNewRooms = true
+		default:
+			fatal("ENGINE_DEGRADE: want halfrate and/or refuse", "value", v)
+		}
+	}
+	if v := os.Getenv("ENGINE_SATURATION"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 1 {
+			fatal("ENGINE_SATURATION: want a fraction of the tick, e.g. 0.8", "value", v)
+		}
+		p.SaturatedAt = f
+	}
+	slog.Info("engine load policy", "halfRate", p.HalfRateBroadcast, "refuse", p.RefuseNewRooms)
+	return p
+}
+
+// openReplayStore opens DATA_DIR/replays, or returns nil with REPLAYS=off.
+// REPLAY_KEEP (newest N, default 1000) and REPLAY_MAX_AGE (default 7 days)
+// bound what is kept; 0 lifts either limit.
+func openReplayStore() (*game.ReplayStore, error) {
+	if os.Getenv("REPLAYS") == "off" {
+		slog.Info("replays disabled by REPLAYS=off")
+		return nil, nil
+	}
+	keep := game.ReplayRetention{
+		MaxCount: 1000,
+		MaxAge:   envDuration("REPLAY_MAX_AGE", 7*24*time.Hour),
+	}
+	if v := os.Getenv("REPLAY_KEEP"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			fatal("REPLAY_KEEP: want a number of replays", "value", v)
+		}
+		keep.MaxCount = n
+	}
+	slog.Info("replays", "keep", keep.MaxCount, "maxAge", keep.MaxAge)
+	return game.NewReplayStore(filepath.Join(dataDir(), "replays"), keep)
+}
+
+// openTournamentStore picks the tournament persistence backend from
+// TOURNAMENT_STORE: "file" (default), "sqlite" or "memory".
+func openTournamentStore() (game.TournamentStore, error) {
+	dataDir := dataDir()
+	switch kind := os.Getenv("TOURNAMENT_STORE"); kind {
+	case "", "file":
+		slog.Info("tournament store: file journal", "dir", dataDir)
+		return game.OpenFileStore(dataDir, 0)
+	case "sqlite":
+		if err := os.MkdirAll(dataDir, 0o755); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dataDir, "tournament.db")
+		slog.Info("tournament store: sqlite", "path", path)
+		return game.OpenSQLStore(path)
+	case "memory":
+		slog.Warn("tournament store: in-memory only (results are lost on restart)")
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown TOURNAMENT_STORE %q", kind)
 	}
-	log.Println("server stopped")
 }
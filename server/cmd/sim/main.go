@@ -0,0 +1,292 @@
+// Command sim plays bot-vs-bot games headless and reports how they went:
+// points per possession, shooting and 3-point rates, steal and block
+// success, shot-clock violations. Games are played under a rules preset
+// whose fields can be overridden to see what a change does before shipping it.
+//
+//	go run ./cmd/sim -games 5000 -bots hard,normal
+//	go run ./cmd/sim -rules quick -override steals.json -set BlockRange=60
+//
+// An override file is a JSON object of game.Rules fields, e.g.
+// {"StealChance": 0.35, "ShotAccuracyThree": 0.3}. -list prints them all.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vladimirvolkov/basketball/server/internal/game"
+)
+
+// stats accumulates over any number of games.
+type stats struct {
+	games, draws int
+	wins         [2]int
+	points       [2]int
+	ticks        int
+
+	possessions   int
+	shots         int // attempts that left the hand, blocked ones excluded
+	made          int
+	threeAttempts int
+	threesMade    int
+	blocks        int
+	stealTries    int
+	steals        int
+	shotClock     int
+}
+
+func (s *stats) add(o *stats) {
+	s.games += o.games
+	s.draws += o.draws
+	for i := range s.wins {
+		s.wins[i] += o.wins[i]
+		s.points[i] += o.points[i]
+	}
+	s.ticks += o.ticks
+	s.possessions += o.possessions
+	s.shots += o.shots
+	s.made += o.made
+	s.threeAttempts += o.threeAttempts
+	s.threesMade += o.threesMade
+	s.blocks += o.blocks
+	s.stealTries += o.stealTries
+	s.steals += o.steals
+	s.shotClock += o.shotClock
+}
+
+// play runs one game to the final whistle and adds it to s.
+func (s *stats) play(levels [2]game.BotDifficulty, seed int64, rules game.Rules) {
+	m := game.NewSimMatch(levels, seed, rules)
+	lastOwner := int8(-1)
+	var cooldown [2]uint8
+	for {
+		events, ok := m.Step()
+		if !ok {
+			break
+		}
+		st := m.State()
+		s.ticks++
+
+		// A possession starts whenever the other player ends up holding
+		// the ball: after a basket, a steal, a turnover or a rebound.
+		if o := st.Ball.Owner; o >= 0 && o != lastOwner {
+			s.possessions++
+			lastOwner = o
+		}
+		// Every steal attempt, hit or miss, restarts the cooldown.
+		for i, p := range st.Players {
+			if p.StealCooldown > cooldown[i] {
+				s.stealTries++
+			}
+			cooldown[i] = p.StealCooldown
+		}
+
+		for _, e := range events {
+			switch e.Kind {
+			case game.EventShot:
+				s.shots++
+				if isThree(int(e.Player), st.Ball.ShotOriginX, rules.ThreePointRadius) {
+					s.threeAttempts++
+				}
+			case game.EventScore:
+				s.made++
+				if e.Value == 3 {
+					s.threesMade++
+				}
+			case game.EventBlock:
+				s.blocks++
+			case game.EventSteal:
+				s.steals++
+			case game.EventShotClock:
+				s.shotClock++
+			}
+		}
+	}
+
+	st := m.State()
+	s.games++
+	s.points[0] += int(st.Score[0])
+	s.points[1] += int(st.Score[1])
+	if st.Winner < 0 {
+		s.draws++
+	} else {
+		s.wins[st.Winner]++
+	}
+}
+
+// isThree mirrors the room's scoring rule: shots from beyond the arc of the
+// hoop being attacked count three.
+func isThree(player int, shotX, radius float32) bool {
+	if player == 0 {
+		return shotX < game.HoopRightX-radius
+	}
+	return shotX > game.HoopLeftX+radius
+}
+
+func pct(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(d)
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+func (s *stats) print(w io.Writer, levels [2]game.BotDifficulty, rules game.Rules) {
+	games := max(s.games, 1)
+	fmt.Fprintf(w, "%d games (%s rules), %s vs %s, %.0f s simulated\n\n", s.games, rules.Name, levels[0], levels[1], float64(s.ticks)/float64(rules.TickRate))
+	fmt.Fprintf(w, "%-24s %8.1f%% / %.1f%% / %.1f%% draws\n", "wins p0 / p1", pct(s.wins[0], games), pct(s.wins[1], games), pct(s.draws, games))
+	fmt.Fprintf(w, "%-24s %8.1f - %.1f\n", "avg score", float64(s.points[0])/float64(games), float64(s.points[1])/float64(games))
+	fmt.Fprintf(w, "%-24s %8.1f\n", "possessions / game", ratio(s.possessions, games))
+	fmt.Fprintf(w, "%-24s %8.3f\n", "points / possession", ratio(s.points[0]+s.points[1], s.possessions))
+	fmt.Fprintf(w, "%-24s %8.1f\n", "shots / game", ratio(s.shots, games))
+	fmt.Fprintf(w, "%-24s %8.1f%%\n", "field goal %", pct(s.made, s.shots))
+	fmt.Fprintf(w, "%-24s %8.1f%%\n", "3PA rate (of shots)", pct(s.threeAttempts, s.shots))
+	fmt.Fprintf(w, "%-24s %8.1f%%\n", "3P %", pct(s.threesMade, s.threeAttempts))
+	fmt.Fprintf(w, "%-24s %8.1f%%\n", "3s of baskets made", pct(s.threesMade, s.made))
+	fmt.Fprintf(w, "%-24s %8.1f%%  (%d of %d attempts)\n", "blocked shots", pct(s.blocks, s.shots+s.blocks), s.blocks, s.shots+s.blocks)
+	fmt.Fprintf(w, "%-24s %8.1f%%  (%d of %d attempts)\n", "steal success", pct(s.steals, s.stealTries), s.steals, s.stealTries)
+	fmt.Fprintf(w, "%-24s %8.2f\n", "shot-clock viol. / game", ratio(s.shotClock, games))
+}
+
+// multiFlag collects a flag given more than once.
+type multiFlag []string
+
+func (m *multiFlag) String() string     { return strings.Join(*m, ",") }
+func (m *multiFlag) Set(v string) error { *m = append(*m, v); return nil }
+
+func loadOverrides(rules *game.Rules, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := rules.Apply(data); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func parseSet(rules *game.Rules, kv string) error {
+	name, value, ok := strings.Cut(kv, "=")
+	if !ok {
+		return fmt.Errorf("-set %q: want Name=value", kv)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("-set %q: %w", kv, err)
+	}
+	data, _ := json.Marshal(map[string]float64{name: v})
+	if err := rules.Apply(data); err != nil {
+		return fmt.Errorf("-set %q: %w", kv, err)
+	}
+	return nil
+}
+
+func parseBots(s string) ([2]game.BotDifficulty, error) {
+	var levels [2]game.BotDifficulty
+	names := strings.Split(s, ",")
+	if len(names) == 1 {
+		names = append(names, names[0])
+	}
+	if len(names) != 2 {
+		return levels, fmt.Errorf("-bots %q: want one or two difficulties", s)
+	}
+	for i, name := range names {
+		d, ok := game.ParseBotDifficulty(strings.TrimSpace(name))
+		if !ok {
+			return levels, fmt.Errorf("-bots: unknown difficulty %q", name)
+		}
+		levels[i] = d
+	}
+	return levels, nil
+}
+
+func main() {
+	games := flag.Int("games", 1000, "number of games to play")
+	seed := flag.Int64("seed", 1, "seed of the first game; game i uses seed+i")
+	bots := flag.String("bots", "normal", "difficulty of both bots, or p0,p1 (easy, normal, hard)")
+	workers := flag.Int("workers", runtime.NumCPU(), "games played in parallel")
+	preset := flag.String("rules", "", "rules preset to start from (default: the rules file's default, else classic)")
+	rulesFile := flag.String("rules-file", "", "rules file with extra presets, as for the server's RULES_FILE")
+	list := flag.Bool("list", false, "print the rules in effect and exit")
+	var overrides, sets multiFlag
+	flag.Var(&overrides, "override", "JSON file of rules overrides (repeatable)")
+	flag.Var(&sets, "set", "override one rule, Name=value (repeatable, applied after files)")
+	flag.Parse()
+
+	levels, err := parseBots(*bots)
+	if err != nil {
+		log.Fatal(err)
+	}
+	book := game.DefaultRuleBook()
+	if *rulesFile != "" {
+		if book, err = game.LoadRuleBook(*rulesFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	rules, ok := book.Preset(*preset)
+	if !ok {
+		log.Fatalf("unknown rules preset %q (have %s)", *preset, strings.Join(book.Names(), ", "))
+	}
+	for _, path := range overrides {
+		if err := loadOverrides(&rules, path); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, kv := range sets {
+		if err := parseSet(&rules, kv); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *list {
+		out, _ := json.MarshalIndent(rules, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+
+	slog.SetDefault(slog.New(slog.DiscardHandler)) // rooms log every game's start and end
+
+	start := time.Now()
+	var (
+		total stats
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		next  = make(chan int64)
+	)
+	for range max(*workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var s stats
+			for sd := range next {
+				s.play(levels, sd, rules)
+			}
+			mu.Lock()
+			total.add(&s)
+			mu.Unlock()
+		}()
+	}
+	for i := range *games {
+		next <- *seed + int64(i)
+	}
+	close(next)
+	wg.Wait()
+
+	total.print(os.Stdout, levels, rules)
+	fmt.Printf("\n(%.1fs)\n", time.Since(start).Seconds())
+}
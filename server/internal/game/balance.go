@@ -0,0 +1,217 @@
+package game
+
+// Worker load balancing.
+//
+// Each room's tick is timed and kept as a moving average (Room.tickCost), and
+// a worker's load is the sum over its rooms. New rooms go to the worker with
+// the lowest load. Games end at different times, so workers drift apart;
+// every rebalanceInterval the busiest worker hands rooms to the lightest until
+// they are within rebalanceSlack of each other.
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/vladimirvolkov/basketball/server/internal/metrics"
+)
+
+const (
+	// costSmoothing weights a new tick measurement 1/costSmoothing in the
+	// moving averages.
+	costSmoothing = 16
+
+	// defaultRoomCost is assumed for a new room before any have been measured.
+	defaultRoomCost = int64(20 * time.Microsecond)
+
+	rebalanceInterval = 5 * time.Second
+	// rebalanceSlack is the load gap, as a fraction of the busiest worker's,
+	// left alone. rebalanceFloor is the smallest gap worth a move at all.
+	rebalanceSlack = 0.2
+	rebalanceFloor = int64(100 * time.Microsecond)
+	// rebalanceMaxMoves bounds the rooms moved per round.
+	rebalanceMaxMoves = 8
+)
+
+var ErrNoSuchWorker = errors.New("no such worker")
+
+var migrations = metrics.NewCounter("basketball_room_migrations_total", "Rooms moved between engine workers.")
+
+// WorkerLoad is one worker's share of the engine, for monitoring.
+type WorkerLoad struct {
+	Worker     int     `json:"worker"`
+	Rooms      int     `json:"rooms"`
+	CostMicros float64 `json:"costMicros"` // summed per-room tick cost
+	TickMicros float64 `json:"tickMicros"` // moving average of the whole tick
+	Overruns   uint64  `json:"overruns"`
+	Saturated  bool    `json:"saturated"`
+}
+
+// Load reports every worker's rooms and measured tick cost.
+func (e *Engine) Load() []WorkerLoad {
+	out := make([]WorkerLoad, len(e.workers))
+	for i, w := range e.workers {
+		out[i] = WorkerLoad{
+			Worker:     w.id,
+			Rooms:      int(w.nRooms.Load()),
+			CostMicros: float64(w.cost.Load()) / 1e3,
+			TickMicros: float64(w.tickNanos.Load()) / 1e3,
+			Overruns:   w.overruns.Value(),
+			Saturated:  w.saturated.Load(),
+		}
+	}
+	return out
+}
+
+// leastLoaded returns the worker with the lowest cost, or the fewest rooms
+// among equals.
+func (e *Engine) leastLoaded() *gameWorker {
+	best := e.workers[0]
+	for _, w := range e.workers[1:] {
+		c, bc := w.cost.Load(), best.cost.Load()
+		if c < bc || c == bc && w.nRooms.Load() < best.nRooms.Load() {
+			best = w
+		}
+	}
+	return best
+}
+
+// meanRoomCost estimates what one more room will cost from the rooms
+// already running.
+func (e *Engine) meanRoomCost() int64 {
+	var cost, rooms int64
+	for _, w := range e.workers {
+		cost += w.cost.Load()
+		rooms += int64(w.nRooms.Load())
+	}
+	if rooms == 0 || cost == 0 {
+		return defaultRoomCost
+	}
+	return cost / rooms
+}
+
+// MigrateRoom moves a live room to worker to. The room is taken off its
+// worker between two ticks and appended to the other's, so it may skip or
+// repeat one tick as the two workers' clocks differ; players won't notice.
+func (e *Engine) MigrateRoom(roomID string, to int) error {
+	if to < 0 || to >= len(e.workers) {
+		return ErrNoSuchWorker
+	}
+	v, ok := e.rooms.Load(roomID)
+	if !ok {
+		return ErrRoomNotFound
+	}
+	e.placeMu.Lock()
+	defer e.placeMu.Unlock()
+	r := v.(*Room)
+	for _, from := range e.workers {
+		if from == e.workers[to] {
+			continue
+		}
+		if e.move(r, from, e.workers[to]) {
+			return nil
+		}
+	}
+	if !slices.Contains(e.workers[to].snapshot(), r) {
+		return ErrRoomNotFound // finished in the meantime
+	}
+	return nil
+}
+
+// move takes r off from and gives it to to, reporting whether from had it.
+// The caller holds placeMu.
+func (e *Engine) move(r *Room, from, to *gameWorker) bool {
+	from.mu.Lock()
+	i := slices.Index(from.rooms, r)
+	if i < 0 {
+		from.mu.Unlock()
+		return false
+	}
+	from.rooms = slices.Delete(from.rooms, i, i+1)
+	from.nRooms.Add(-1)
+	cost := r.tickCost
+	from.cost.Add(-cost)
+	from.mu.Unlock()
+
+	to.add(r)
+	migrations.Inc()
+	r.log.Debug("room migrated", "from", from.id, "to", to.id, "costMicros", cost/1e3)
+	return true
+}
+
+// snapshot copies the worker's room list.
+func (w *gameWorker) snapshot() []*Room {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.rooms)
+}
+
+func (e *Engine) balanceLoop(ctx context.Context) {
+	if len(e.workers) < 2 {
+		return
+	}
+	t := time.NewTicker(rebalanceInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if n := e.rebalance(); n > 0 {
+				slog.Info("rebalanced engine workers", "moved", n)
+			}
+		}
+	}
+}
+
+// rebalance moves rooms from the busiest worker to the lightest while the gap
+// between them is worth closing, and returns how many it moved.
+func (e *Engine) rebalance() int {
+	e.placeMu.Lock()
+	defer e.placeMu.Unlock()
+
+	moved := 0
+	for moved < rebalanceMaxMoves {
+		busy, light := e.workers[0], e.workers[0]
+		for _, w := range e.workers[1:] {
+			if w.cost.Load() > busy.cost.Load() {
+				busy = w
+			}
+			if w.cost.Load() < light.cost.Load() {
+				light = w
+			}
+		}
+		bc := busy.cost.Load()
+		gap := bc - light.cost.Load()
+		if gap < rebalanceFloor || float64(gap) < rebalanceSlack*float64(bc) {
+			return moved
+		}
+
+		// The room closest to half the gap evens the two out best; one
+		// costing the whole gap or more would only swap which is busier.
+		// tickCost is only written under busy.mu, so read it there.
+		busy.mu.Lock()
+		var pick *Room
+		var pickDist int64
+		for _, r := range busy.rooms {
+			if r.tickCost >= gap {
+				continue
+			}
+			d := r.tickCost - gap/2
+			if d < 0 {
+				d = -d
+			}
+			if pick == nil || d < pickDist {
+				pick, pickDist = r, d
+			}
+		}
+		busy.mu.Unlock()
+		if pick == nil || !e.move(pick, busy, light) {
+			return moved
+		}
+		moved++
+	}
+	return moved
+}
@@ -1,11 +1,19 @@
 package game
 
 import (
-	"log"
 	"math"
 	"math/rand"
 )
 
+// Ball timers, in seconds; counted in ticks at the room's rate.
+const (
+	pickupAfterShot    = 0.5  // before anyone can pick up a shot
+	pickupAfterLoose   = 0.25 // after a block or a steal knocks the ball loose
+	pickupAfterDeflect = 0.13 // after a shot glances off a player
+	shooterClearance   = 0.5  // a shot can't hit its own shooter for this long
+	stolenFromDelay    = 0.5  // the player stolen from can't pick the ball up
+)
+
 func NewBall() BallState {
 	return BallState{
 		X:          CourtWidth / 2,
@@ -25,7 +33,10 @@ func clampF(val, min, max float32) float32 {
 	return val
 }
 
-func StepBall(b *BallState, players *[2]PlayerState) {
+// StepBall advances the ball one tick. It returns the player whose body
+// deflected a shot in flight, or -1.
+func StepBall(b *BallState, players *[2]PlayerState, rules *Rules) (deflectedBy int) {
+	deflectedBy = -1
 	if b.Owner >= 0 {
 		// Ball follows the holder
 		p := &players[b.Owner]
@@ -49,16 +60,17 @@ func StepBall(b *BallState, players *[2]PlayerState) {
 
 	if b.InFlight || b.Owner == -1 {
 		// Gravity
-		b.VY += Gravity * DT
+		dt := rules.dt()
+		b.VY += rules.Gravity * dt
 
 		// Integrate
-		b.X += b.VX * DT
-		b.Y += b.VY * DT
+		b.X += b.VX * dt
+		b.Y += b.VY * dt
 
 		// Floor bounce
 		if b.Y+BallRadius >= FloorY {
 			b.Y = FloorY - BallRadius
-			b.VY = -b.VY * RestitutionFloor
+			b.VY = -b.VY * rules.RestitutionFloor
 			b.VX *= 0.95 // friction
 
 			if float32(math.Abs(float64(b.VY))) < 20 {
@@ -85,7 +97,7 @@ func StepBall(b *BallState, players *[2]PlayerState) {
 
 		// Ball-player interception (AABB vs circle)
 		if b.InFlight {
-			CheckBallPlayerCollision(b, players)
+			deflectedBy = CheckBallPlayerCollision(b, players, rules)
 		}
 	}
 
@@ -115,15 +127,16 @@ func StepBall(b *BallState, players *[2]PlayerState) {
 			}
 		}
 	}
+	return
 }
 
-// shotAccuracy returns the probability (0.15..0.6) that a shot hits the hoop,
-// based on the shooter's distance from the opponent's hoop.
-// Under the hoop (dist ~0): 0.6
-// At the 3-point line (dist = ThreePointRadius): 0.25
-// At center court (max range): 0.15
+// shotAccuracy returns the probability (0.15..0.6 by default) that a shot
+// hits the hoop, based on the shooter's distance from the opponent's hoop.
+// Under the hoop (dist ~0): ShotAccuracyClose
+// At the 3-point line (dist = ThreePointRadius): ShotAccuracyThree
+// At center court (max range): ShotAccuracyFar
 // Linear interpolation between zones.
-func shotAccuracy(playerX float32, playerIdx int8) float64 {
+func shotAccuracy(playerX float32, playerIdx int8, rules *Rules) float64 {
 	var hoopX float32
 	if playerIdx == 0 {
 		hoopX = HoopRightX
@@ -132,32 +145,34 @@ func shotAccuracy(playerX float32, playerIdx int8) float64 {
 	}
 
 	dist := math.Abs(float64(playerX - hoopX))
-	threeP := float64(ThreePointRadius)
+	threeP := float64(rules.ThreePointRadius)
 
 	if dist <= threeP {
-		// Inside 3-point line: lerp 0.6 (under hoop) → 0.25 (at 3pt line)
+		// Inside 3-point line: lerp close (under hoop) → three (at 3pt line)
 		t := dist / threeP // 0 at hoop, 1 at 3pt line
-		return 0.6 - t*0.35
+		return rules.ShotAccuracyClose - t*(rules.ShotAccuracyClose-rules.ShotAccuracyThree)
 	}
 
-	// Beyond 3-point line: lerp 0.25 → 0.15 over remaining court distance
+	// Beyond 3-point line: lerp three → far over remaining court distance
 	maxDist := float64(CourtWidth) - float64(hoopX)
 	if playerIdx == 1 {
 		maxDist = float64(hoopX)
 	}
 	remaining := maxDist - threeP
 	if remaining < 1 {
-		return 0.15
+		return rules.ShotAccuracyFar
 	}
 	t := (dist - threeP) / remaining // 0 at 3pt line, 1 at far wall
 	t = math.Min(1, math.Max(0, t))
-	return 0.25 - t*0.1
+	return rules.ShotAccuracyThree - t*(rules.ShotAccuracyThree-rules.ShotAccuracyFar)
 }
 
 // ShootBall — server auto-calculates angle/force to hit opponent's hoop.
 // playerIdx: 0 shoots at right hoop, 1 shoots at left hoop.
 // Shot accuracy depends on distance: guaranteed on opponent's half, probabilistic on own half.
-func ShootBall(b *BallState, p *PlayerState, playerIdx int8) {
+// All randomness comes from rng so a room's games can be replayed from its seed.
+// Reports whether the shot was aimed to go in.
+func ShootBall(b *BallState, p *PlayerState, playerIdx int8, rules *Rules, rng *rand.Rand) (hit bool) {
 	// Determine target hoop
 	var hoopX float32
 	if playerIdx == 0 {
@@ -168,21 +183,20 @@ func ShootBall(b *BallState, p *PlayerState, playerIdx int8) {
 	hoopY := HoopY
 
 	// Accuracy check — miss means offset target
-	accuracy := shotAccuracy(p.X, playerIdx)
-	hit := rand.Float64() < accuracy
+	accuracy := shotAccuracy(p.X, playerIdx, rules)
+	hit = rng.Float64() < accuracy
 
 	targetX := hoopX
 	targetY := hoopY
 	if !hit {
 		// Offset target so ball misses (±35..65px horizontal, ±10..25px vertical)
-		offsetX := float32(35 + rand.Float64()*30)
-		if rand.Intn(2) == 0 {
+		offsetX := float32(35 + rng.Float64()*30)
+		if rng.Intn(2) == 0 {
 			offsetX = -offsetX
 		}
-		offsetY := float32(-25 + rand.Float64()*35) // -25 to +10
+		offsetY := float32(-25 + rng.Float64()*35) // -25 to +10
 		targetX += offsetX
 		targetY += offsetY
-		log.Printf("MISS: accuracy=%.2f, offsetX=%.1f offsetY=%.1f", accuracy, offsetX, offsetY)
 	}
 
 	// Ball starting position (same as StepBall follow logic)
@@ -199,18 +213,18 @@ func ShootBall(b *BallState, p *PlayerState, playerIdx int8) {
 	if D > 1 {
 		// Minimum force: v_min² = g * (H + sqrt(H² + D²))
 		rangeHyp := math.Sqrt(H*H + D*D)
-		vMinSq := float64(Gravity) * (H + rangeHyp)
-		vMin := MinShootForce
+		vMinSq := float64(rules.Gravity) * (H + rangeHyp)
+		vMin := rules.MinShootForce
 		if vMinSq > 0 {
 			vMin = float32(math.Sqrt(vMinSq))
 		}
 
 		// Comfortable arc: v_min * 1.15
-		force = float64(clampF(vMin*1.15, MinShootForce, MaxShootForce))
+		force = float64(clampF(vMin*1.15, rules.MinShootForce, rules.MaxShootForce))
 
 		// Solve for launch angle: c*u² - D*u + (c + H) = 0 where c = g*D²/(2*v²), u = tan(θ)
 		v := force
-		c := float64(Gravity) * D * D / (2 * v * v)
+		c := float64(rules.Gravity) * D * D / (2 * v * v)
 		discriminant := D*D - 4*c*(c+H)
 
 		if discriminant >= 0 {
@@ -235,7 +249,7 @@ func ShootBall(b *BallState, p *PlayerState, playerIdx int8) {
 	} else {
 		// Directly above — shoot straight up
 		angle = math.Pi / 2
-		force = float64(clampF(float32(math.Abs(dy)*2), MinShootForce, MaxShootForce))
+		force = float64(clampF(float32(math.Abs(dy)*2), rules.MinShootForce, rules.MaxShootForce))
 	}
 
 	// Clamp angle to upward arc only
@@ -243,27 +257,25 @@ func ShootBall(b *BallState, p *PlayerState, playerIdx int8) {
 
 	b.VX = float32(force * math.Cos(angle))
 	b.VY = -float32(force * math.Sin(angle))
-	log.Printf("SHOOT: playerIdx=%d hit=%v accuracy=%.2f angle=%.3f force=%.1f → VX=%.1f VY=%.1f (player %.1f → hoop %.1f)",
-		playerIdx, hit, accuracy, angle, force, b.VX, b.VY, p.X, hoopX)
 	b.X = startX
 	b.Y = startY
 	b.Owner = -1
 	b.InFlight = true
-	b.PickupCooldown = 30 // ~0.5 seconds before ball can be picked up
+	b.PickupCooldown = uint8(rules.ticks(pickupAfterShot))
 	b.ShooterIdx = playerIdx
 	b.ShotAgeTicks = 0
 	b.ShotOriginX = p.X // record for 3-point detection
 	p.HasBall = false
 	p.Anim = AnimShoot
+	return hit
 }
 
 // CheckBallPlayerCollision — AABB (player body) vs Circle (ball) collision.
-// Deflects ball off defender's body during flight.
-// Shooter can't collide with own shot for first 30 ticks.
-func CheckBallPlayerCollision(b *BallState, players *[2]PlayerState) {
+// Deflects ball off defender's body during flight, returning the player it
+// hit or -1. Shooter can't collide with own shot for shooterClearance.
+func CheckBallPlayerCollision(b *BallState, players *[2]PlayerState, rules *Rules) int {
 	for i := range players {
-		// Skip shooter for first 30 ticks
-		if b.ShooterIdx == int8(i) && b.ShotAgeTicks < 30 {
+		if b.ShooterIdx == int8(i) && int(b.ShotAgeTicks) < rules.ticks(shooterClearance) {
 			continue
 		}
 
@@ -302,22 +314,23 @@ func CheckBallPlayerCollision(b *BallState, players *[2]PlayerState) {
 
 			// Reflect velocity along normal with deflection multiplier
 			dot := b.VX*nx + b.VY*ny
-			b.VX = (b.VX - 2*dot*nx) * DeflectSpeedMult
-			b.VY = (b.VY - 2*dot*ny) * DeflectSpeedMult
+			b.VX = (b.VX - 2*dot*nx) * rules.DeflectSpeedMult
+			b.VY = (b.VY - 2*dot*ny) * rules.DeflectSpeedMult
 
 			// Reset shooter (ball is now deflected, anyone can pick it up)
 			b.ShooterIdx = -1
-			b.PickupCooldown = 8 // short cooldown after deflection (reduced from 15)
-
-			log.Printf("INTERCEPT: player %d deflected ball at (%.1f,%.1f) → VX=%.1f VY=%.1f", i, b.X, b.Y, b.VX, b.VY)
-			return
+			b.PickupCooldown = uint8(rules.ticks(pickupAfterDeflect))
+			return i
 		}
 	}
+	return -1
 }
 
 // TryBlockShot checks if a blocker can block a shooter's attempt.
 // Requirements: blocker in jump (not grounded), within BlockRange, blocker.Y <= shooter.Y + 10
-func TryBlockShot(b *BallState, shooter *PlayerState, shooterIdx int8, blocker *PlayerState) bool {
+// Blocks are deterministic, so unlike shots and steals they take no RNG.
+// The room passes the blocker as the shooter saw it (see lagcomp.go).
+func TryBlockShot(b *BallState, shooter *PlayerState, shooterIdx int8, blocker *PlayerState, rules *Rules) bool {
 	if blocker.Grounded {
 		return false
 	}
@@ -326,7 +339,7 @@ func TryBlockShot(b *BallState, shooter *PlayerState, shooterIdx int8, blocker *
 	dx := shooter.X - blocker.X
 	dy := shooter.Y - blocker.Y
 	dist := float32(math.Sqrt(float64(dx*dx + dy*dy)))
-	if dist > BlockRange {
+	if dist > rules.BlockRange {
 		return false
 	}
 
@@ -351,41 +364,41 @@ func TryBlockShot(b *BallState, shooter *PlayerState, shooterIdx int8, blocker *
 	b.Y = shooter.Y
 	b.Owner = -1
 	b.InFlight = true
-	b.PickupCooldown = 15
+	b.PickupCooldown = uint8(rules.ticks(pickupAfterLoose))
 	b.ShooterIdx = -1
 	b.ShotAgeTicks = 0
 	shooter.HasBall = false
 	blocker.Anim = AnimBlock
-
-	log.Printf("BLOCK: shooter %d blocked by defender at (%.1f,%.1f)", shooterIdx, blocker.X, blocker.Y)
 	return true
 }
 
 // TrySteal attempts to steal the ball from a holder.
 // Returns true if the attempt was made (for cooldown activation), regardless of success.
-// On success: ball is knocked free in a random direction.
-func TrySteal(b *BallState, stealer *PlayerState, stealerIdx int8, holder *PlayerState, holderIdx int8) bool {
+// On success: ball is knocked free in a random direction drawn from rng.
+// The reach is judged against seen, the holder as the stealer saw them (see
+// lagcomp.go); pass holder itself to judge it against the present.
+func TrySteal(b *BallState, stealer *PlayerState, stealerIdx int8, holder *PlayerState, holderIdx int8, seen *PlayerState, rules *Rules, rng *rand.Rand) bool {
 	// Distance check
-	dx := stealer.X - holder.X
-	dy := stealer.Y - holder.Y
+	dx := stealer.X - seen.X
+	dy := stealer.Y - seen.Y
 	dist := float32(math.Sqrt(float64(dx*dx + dy*dy)))
-	if dist > StealRange {
+	if dist > rules.StealRange {
 		return false // too far — no attempt
 	}
 
 	// Attempt made — check success
-	if rand.Float64() < StealChance {
+	if rng.Float64() < rules.StealChance {
 		// Success! Knock ball free
 		b.Owner = -1
 		holder.HasBall = false
 		b.InFlight = true
 		b.ShooterIdx = -1
 		b.ShotAgeTicks = 0
-		b.PickupCooldown = 15 // cooldown before anyone can pick up
+		b.PickupCooldown = uint8(rules.ticks(pickupAfterLoose))
 
 		// Ball flies away from stealer in a random-ish direction
 		dirX := float32(150)
-		if rand.Intn(2) == 0 {
+		if rng.Intn(2) == 0 {
 			dirX = -dirX
 		}
 		b.VX = dirX
@@ -395,12 +408,8 @@ func TrySteal(b *BallState, stealer *PlayerState, stealerIdx int8, holder *Playe
 		b.X = holder.X
 		b.Y = holder.Y
 
-		// Holder can't pick up ball for 30 ticks (~0.5s) — gives stealer a chance
-		holder.PickupDelay = 30
-
-		log.Printf("STEAL SUCCESS: player %d stole from player %d at (%.1f,%.1f)", stealerIdx, holderIdx, stealer.X, stealer.Y)
-	} else {
-		log.Printf("STEAL FAIL: player %d tried to steal from player %d", stealerIdx, holderIdx)
+		// Holder can't pick the ball straight back up — gives stealer a chance
+		holder.PickupDelay = uint8(rules.ticks(stolenFromDelay))
 	}
 
 	return true // attempt was made (activate cooldown)
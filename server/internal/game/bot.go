@@ -0,0 +1,226 @@
+package game
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Server-side AI opponent.
+//
+// A Bot fills a seat that has no connection. Every playing tick it looks at
+// the game state as it was profile.reaction seconds ago — it reacts late,
+// like a person watching the screen — and produces a PlayerInput that goes
+// through the same input queue and rules as a human's. Its chances are set
+// per 60 Hz tick and scaled to the room's rate, so it plays the same at any
+// rate.
+
+type BotDifficulty uint8
+
+const (
+	BotEasy BotDifficulty = iota
+	BotNormal
+	BotHard
+)
+
+var botDifficultyNames = [...]string{
+	BotEasy:   "easy",
+	BotNormal: "normal",
+	BotHard:   "hard",
+}
+
+func (d BotDifficulty) String() string {
+	if int(d) < len(botDifficultyNames) {
+		return botDifficultyNames[d]
+	}
+	return "unknown"
+}
+
+// ParseBotDifficulty accepts "easy", "normal" or "hard".
+func ParseBotDifficulty(s string) (BotDifficulty, bool) {
+	for d, name := range botDifficultyNames {
+		if s == name {
+			return BotDifficulty(d), true
+		}
+	}
+	return BotNormal, false
+}
+
+type botProfile struct {
+	name       string
+	reaction   float64 // seconds between something happening and the bot reacting
+	shootRange float32 // furthest from the hoop it will shoot
+	openGap    float32 // backs off a defender closer than this before shooting; 0 shoots over it
+	block      bool    // jumps to contest a shot it is close to
+	stealRate  float64 // chance per tick to reach for the ball when in range
+	hesitate   float64 // chance per tick to freeze for a reaction's length
+}
+
+var botProfiles = [...]botProfile{
+	BotEasy:   {name: "Bot Easy", reaction: 0.5, shootRange: 140, stealRate: 0.02, hesitate: 0.02},
+	BotNormal: {name: "Bot Normal", reaction: 0.25, shootRange: 200, openGap: 36, block: true, stealRate: 0.05, hesitate: 0.005},
+	BotHard:   {name: "Bot Hard", reaction: 1.0 / 6, shootRange: 200, openGap: 44, block: true, stealRate: 0.04},
+}
+
+type Bot struct {
+	idx     int
+	level   BotDifficulty
+	profile botProfile
+	rng     *rand.Rand // the bot's own; the room's RNG stays untouched
+
+	seen   []GameState // ring of recent states, for the reaction delay; sized on the first Input
+	ticks  int         // states seen so far
+	frozen int         // ticks left hesitating
+	seq    uint32
+}
+
+// NewBot returns a bot for seat idx. seed makes its choices repeatable.
+func NewBot(idx int, level BotDifficulty, seed int64) *Bot {
+	if int(level) >= len(botProfiles) {
+		level = BotNormal
+	}
+	p := botProfiles[level]
+	return &Bot{
+		idx:     idx,
+		level:   level,
+		profile: p,
+		rng:     rand.New(rand.NewSource(seed + int64(idx) + 1)),
+	}
+}
+
+// Name is the bot's nickname in GameStart.
+func (b *Bot) Name() string { return b.profile.name }
+
+// Level returns the bot's difficulty.
+func (b *Bot) Level() BotDifficulty { return b.level }
+
+// Input decides the bot's input for the current tick of s, played under rules.
+func (b *Bot) Input(s *GameState, rules *Rules) PlayerInput {
+	if b.seen == nil {
+		b.seen = make([]GameState, rules.ticks(b.profile.reaction)+1)
+	}
+	b.seen[b.ticks%len(b.seen)] = *s
+	b.ticks++
+	view := b.seen[0]
+	if b.ticks >= len(b.seen) {
+		view = b.seen[b.ticks%len(b.seen)] // oldest
+	}
+	view.Players[b.idx] = s.Players[b.idx] // it always knows where it is itself
+
+	var in PlayerInput
+	switch {
+	case b.frozen > 0:
+		b.frozen--
+	case b.rng.Float64() < perTick(b.profile.hesitate, rules):
+		b.frozen = len(b.seen) - 1
+	default:
+		in = b.decide(&view, rules)
+	}
+	b.seq++
+	in.Seq = b.seq
+	in.Tick = s.Tick
+	return in
+}
+
+// perTick converts p, a chance per 60 Hz tick, to the chance per tick at
+// rules' rate that happens as often per second.
+func perTick(p float64, rules *Rules) float64 {
+	return 1 - math.Pow(1-p, DefaultTickRate/float64(rules.TickRate))
+}
+
+func (b *Bot) decide(s *GameState, rules *Rules) PlayerInput {
+	me, opp := &s.Players[b.idx], &s.Players[1-b.idx]
+	switch {
+	case me.HasBall:
+		return b.attack(s, me, opp)
+	case opp.HasBall:
+		return b.defend(me, opp, rules)
+	default:
+		return b.chase(s, me)
+	}
+}
+
+// attack drives toward the hoop and shoots once inside shootRange, or
+// anywhere when the shot clock is about to run out. With openGap set it
+// first gets clear of a defender on top of it, who would deflect the shot
+// and is close enough to steal.
+func (b *Bot) attack(s *GameState, me, opp *PlayerState) PlayerInput {
+	var in PlayerInput
+	hoopX := b.targetHoopX()
+	dist := absF(hoopX - me.X)
+	if dist > b.profile.shootRange && s.ShotClock > 3 {
+		in.MoveX = toward(me.X, hoopX, 0)
+		return in
+	}
+	// Only back out toward half court; backing toward the baseline ends up
+	// pinned against the wall.
+	out := toward(hoopX, CourtWidth/2, 0)
+	if absF(opp.X-me.X) < b.profile.openGap && s.ShotClock > 3 && -toward(me.X, opp.X, 0) == out {
+		in.MoveX = out
+		return in
+	}
+	in.Shoot = true
+	return in
+}
+
+// defend stays between the ball carrier and the hoop it attacks, reaching
+// for steals and jumping at shots.
+func (b *Bot) defend(me, opp *PlayerState, rules *Rules) PlayerInput {
+	var in PlayerInput
+	ownHoopX := b.ownHoopX()
+	guardX := opp.X + float32(toward(opp.X, ownHoopX, 0))*PlayerWidth
+	in.MoveX = toward(me.X, guardX, 6)
+
+	gap := absF(me.X - opp.X)
+	if gap <= rules.StealRange && me.StealCooldown == 0 && b.rng.Float64() < perTick(b.profile.stealRate, rules) {
+		in.Shoot = true
+	}
+	if b.profile.block && !opp.Grounded && gap < rules.BlockRange && me.Grounded {
+		in.Jump = true
+	}
+	return in
+}
+
+// chase goes after a loose ball or a rebound.
+func (b *Bot) chase(s *GameState, me *PlayerState) PlayerInput {
+	var in PlayerInput
+	ball := &s.Ball
+	in.MoveX = toward(me.X, ball.X, 8)
+	if me.Grounded && ball.Y < me.Y-PlayerHeight/2 && absF(ball.X-me.X) < 40 {
+		in.Jump = true
+	}
+	return in
+}
+
+// targetHoopX is the hoop the bot scores on: player 0 shoots right, 1 left.
+func (b *Bot) targetHoopX() float32 {
+	if b.idx == 0 {
+		return HoopRightX
+	}
+	return HoopLeftX
+}
+
+func (b *Bot) ownHoopX() float32 {
+	if b.idx == 0 {
+		return HoopLeftX
+	}
+	return HoopRightX
+}
+
+// toward returns the MoveX that heads from x to target, or 0 within
+// deadzone.
+func toward(x, target, deadzone float32) int8 {
+	switch {
+	case target-x > deadzone:
+		return 1
+	case x-target > deadzone:
+		return -1
+	}
+	return 0
+}
+
+func absF(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,46 @@
+package game
+
+import "testing"
+
+// playSim plays one bot-vs-bot game and returns the final score.
+func playSim(levels [2]BotDifficulty, seed int64) [2]uint8 {
+	m := NewSimMatch(levels, seed, ClassicRules())
+	for {
+		if _, ok := m.Step(); !ok {
+			return m.State().Score
+		}
+	}
+}
+
+func TestBotLevelsRank(t *testing.T) {
+	const games = 20
+	pairs := [][2]BotDifficulty{{BotNormal, BotEasy}, {BotHard, BotNormal}}
+	for _, p := range pairs {
+		wins := 0
+		for seed := range int64(games) {
+			// Alternate seats, as seat 0 wins simultaneous pickups.
+			levels, better := p, 0
+			if seed%2 == 1 {
+				levels, better = [2]BotDifficulty{p[1], p[0]}, 1
+			}
+			s := playSim(levels, seed)
+			if s[better] > s[1-better] {
+				wins++
+			}
+		}
+		if wins < games*3/4 {
+			t.Errorf("%v beat %v in %d of %d games", p[0], p[1], wins, games)
+		}
+	}
+}
+
+func TestHardBotsScore(t *testing.T) {
+	var points int
+	for seed := range int64(10) {
+		s := playSim([2]BotDifficulty{BotHard, BotHard}, seed)
+		points += int(s[0]) + int(s[1])
+	}
+	if points < 10*10 {
+		t.Fatalf("hard vs hard scored %d points in 10 games; they should play a real game", points)
+	}
+}
@@ -0,0 +1,360 @@
+package game
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/vladimirvolkov/basketball/server/internal/ws"
+)
+
+// Delta-compressed state snapshots.
+//
+// Binary-codec clients that acknowledge ticks (ws.MsgStateAck) get
+// MsgGameStateDelta frames carrying only the fields that changed since the
+// tick they last acknowledged. Everyone else — JSON clients, clients that
+// never ack, clients whose ack fell out of the history window — gets the full
+// MsgGameState keyframe. A keyframe also goes to every recipient once a
+// second (keyframeInterval) and whenever a client sends
+// ws.MsgRequestKeyframe.
+//
+// The browser client speaks JSON, so it always gets keyframes; deltas are for
+// binary clients (see ws.Codec). ApplyDelta is what such a client has to port.
+
+// deltaHistory is how many past ticks a room keeps as delta bases (~1s at
+// 60 Hz).
+const deltaHistory = 64
+
+// keyframeInterval is the ticks between the keyframes everyone gets: a
+// second's worth.
+func (r *Room) keyframeInterval() int {
+	return r.rules.TickRate
+}
+
+// Top-level field bits of a delta mask.
+const (
+	deltaPhase uint16 = 1 << iota
+	deltaPhaseTimer
+	deltaPlayer0
+	deltaPlayer1
+	deltaBall
+	deltaScore
+	deltaShotClock
+	deltaGameClock
+	deltaWinner
+	deltaInputSeq
+)
+
+// Per-player and per-ball field bits.
+const (
+	deltaX uint8 = 1 << iota
+	deltaY
+	deltaVX
+	deltaVY
+	deltaFacing
+	deltaAnim
+	deltaFlags
+	deltaStealCd
+)
+
+// Ball sections reuse the position bits plus these two.
+const (
+	deltaOwner     = deltaFacing
+	deltaBallFlags = deltaAnim
+)
+
+// stateHistory keeps recent states for use as delta bases. Only touched from
+// the room's tick.
+type stateHistory struct {
+	states [deltaHistory]GameState
+}
+
+func (h *stateHistory) push(s *GameState) {
+	h.states[s.Tick%deltaHistory] = *s
+}
+
+// get returns the stored state for tick, or nil if it has been overwritten.
+func (h *stateHistory) get(tick uint32) *GameState {
+	s := &h.states[tick%deltaHistory]
+	if tick == 0 || s.Tick != tick {
+		return nil
+	}
+	return s
+}
+
+// stateDelta is the MsgGameStateDelta payload. Binary only.
+type stateDelta struct {
+	base, cur *GameState
+}
+
+// AppendBinary implements encoding.BinaryAppender.
+//
+//	base:u32 mask:u16 [phase:u8] [phaseTimer:f32] [player0] [player1] [ball]
+//	[score:2×u8] [shotClock:f32] [gameClock:f32] [winner:i8] [inputSeq:2×u32]
+//
+// Each player/ball section is a u8 field mask followed by the masked fields in
+// the same order and sizes as the full GameState binary form.
+func (d stateDelta) AppendBinary(b []byte) ([]byte, error) {
+	base, cur := d.base, d.cur
+	b = binary.LittleEndian.AppendUint32(b, base.Tick)
+	maskAt := len(b)
+	b = append(b, 0, 0)
+
+	var mask uint16
+	if cur.Phase != base.Phase {
+		mask |= deltaPhase
+		b = append(b, byte(cur.Phase))
+	}
+	if cur.PhaseTimer != base.PhaseTimer {
+		mask |= deltaPhaseTimer
+		b = ws.AppendF32(b, cur.PhaseTimer)
+	}
+	for i := range cur.Players {
+		if cur.Players[i] != base.Players[i] {
+			mask |= deltaPlayer0 << i
+			b = appendPlayerDelta(b, &base.Players[i], &cur.Players[i])
+		}
+	}
+	if cur.Ball != base.Ball {
+		mask |= deltaBall
+		b = appendBallDelta(b, &base.Ball, &cur.Ball)
+	}
+	if cur.Score != base.Score {
+		mask |= deltaScore
+		b = append(b, cur.Score[0], cur.Score[1])
+	}
+	if cur.ShotClock != base.ShotClock {
+		mask |= deltaShotClock
+		b = ws.AppendF32(b, cur.ShotClock)
+	}
+	if cur.GameClock != base.GameClock {
+		mask |= deltaGameClock
+		b = ws.AppendF32(b, cur.GameClock)
+	}
+	if cur.Winner != base.Winner {
+		mask |= deltaWinner
+		b = append(b, byte(cur.Winner))
+	}
+	if cur.InputSeq != base.InputSeq {
+		mask |= deltaInputSeq
+		b = binary.LittleEndian.AppendUint32(b, cur.InputSeq[0])
+		b = binary.LittleEndian.AppendUint32(b, cur.InputSeq[1])
+	}
+	binary.LittleEndian.PutUint16(b[maskAt:], mask)
+	return b, nil
+}
+
+func appendPlayerDelta(b []byte, base, cur *PlayerState) []byte {
+	maskAt := len(b)
+	b = append(b, 0)
+	var m uint8
+	if cur.X != base.X {
+		m |= deltaX
+		b = ws.AppendF32(b, cur.X)
+	}
+	if cur.Y != base.Y {
+		m |= deltaY
+		b = ws.AppendF32(b, cur.Y)
+	}
+	if cur.VX != base.VX {
+		m |= deltaVX
+		b = ws.AppendF32(b, cur.VX)
+	}
+	if cur.VY != base.VY {
+		m |= deltaVY
+		b = ws.AppendF32(b, cur.VY)
+	}
+	if cur.Facing != base.Facing {
+		m |= deltaFacing
+		b = append(b, byte(cur.Facing))
+	}
+	if cur.Anim != base.Anim {
+		m |= deltaAnim
+		b = append(b, byte(cur.Anim))
+	}
+	if cur.Grounded != base.Grounded || cur.HasBall != base.HasBall {
+		m |= deltaFlags
+		var flags byte
+		if cur.Grounded {
+			flags |= playerFlagGrounded
+		}
+		if cur.HasBall {
+			flags |= playerFlagHasBall
+		}
+		b = append(b, flags)
+	}
+	if cur.StealCooldown != base.StealCooldown {
+		m |= deltaStealCd
+		b = append(b, cur.StealCooldown)
+	}
+	b[maskAt] = m
+	return b
+}
+
+func appendBallDelta(b []byte, base, cur *BallState) []byte {
+	maskAt := len(b)
+	b = append(b, 0)
+	var m uint8
+	if cur.X != base.X {
+		m |= deltaX
+		b = ws.AppendF32(b, cur.X)
+	}
+	if cur.Y != base.Y {
+		m |= deltaY
+		b = ws.AppendF32(b, cur.Y)
+	}
+	if cur.VX != base.VX {
+		m |= deltaVX
+		b = ws.AppendF32(b, cur.VX)
+	}
+	if cur.VY != base.VY {
+		m |= deltaVY
+		b = ws.AppendF32(b, cur.VY)
+	}
+	if cur.Owner != base.Owner {
+		m |= deltaOwner
+		b = append(b, byte(cur.Owner))
+	}
+	if cur.InFlight != base.InFlight {
+		m |= deltaBallFlags
+		var flags byte
+		if cur.InFlight {
+			flags |= ballFlagInFlight
+		}
+		b = append(b, flags)
+	}
+	b[maskAt] = m
+	return b
+}
+
+// ErrDeltaBase is returned by ApplyDelta when the delta wasn't built on base.
+var ErrDeltaBase = errors.New("delta base tick mismatch")
+
+// ApplyDelta rebuilds the full state for tick from base and a
+// MsgGameStateDelta payload. It is the reference for client decoders and is
+// checked against full states over a whole game in delta_test.go.
+func ApplyDelta(base GameState, tick uint32, payload []byte) (GameState, error) {
+	r := ws.NewBinaryReader(payload)
+	if r.U32() != base.Tick {
+		return GameState{}, ErrDeltaBase
+	}
+	s := base
+	s.Tick = tick
+	mask := r.U16()
+	if mask&deltaPhase != 0 {
+		s.Phase = GamePhase(r.U8())
+	}
+	if mask&deltaPhaseTimer != 0 {
+		s.PhaseTimer = r.F32()
+	}
+	for i := range s.Players {
+		if mask&(deltaPlayer0<<i) != 0 {
+			p := &s.Players[i]
+			m := r.U8()
+			if m&deltaX != 0 {
+				p.X = r.F32()
+			}
+			if m&deltaY != 0 {
+				p.Y = r.F32()
+			}
+			if m&deltaVX != 0 {
+				p.VX = r.F32()
+			}
+			if m&deltaVY != 0 {
+				p.VY = r.F32()
+			}
+			if m&deltaFacing != 0 {
+				p.Facing = r.I8()
+			}
+			if m&deltaAnim != 0 {
+				p.Anim = AnimState(r.U8())
+			}
+			if m&deltaFlags != 0 {
+				flags := r.U8()
+				p.Grounded = flags&playerFlagGrounded != 0
+				p.HasBall = flags&playerFlagHasBall != 0
+			}
+			if m&deltaStealCd != 0 {
+				p.StealCooldown = r.U8()
+			}
+		}
+	}
+	if mask&deltaBall != 0 {
+		bs := &s.Ball
+		m := r.U8()
+		if m&deltaX != 0 {
+			bs.X = r.F32()
+		}
+		if m&deltaY != 0 {
+			bs.Y = r.F32()
+		}
+		if m&deltaVX != 0 {
+			bs.VX = r.F32()
+		}
+		if m&deltaVY != 0 {
+			bs.VY = r.F32()
+		}
+		if m&deltaOwner != 0 {
+			bs.Owner = r.I8()
+		}
+		if m&deltaBallFlags != 0 {
+			bs.InFlight = r.U8()&ballFlagInFlight != 0
+		}
+	}
+	if mask&deltaScore != 0 {
+		s.Score[0], s.Score[1] = r.U8(), r.U8()
+	}
+	if mask&deltaShotClock != 0 {
+		s.ShotClock = r.F32()
+	}
+	if mask&deltaGameClock != 0 {
+		s.GameClock = r.F32()
+	}
+	if mask&deltaWinner != 0 {
+		s.Winner = r.I8()
+	}
+	if mask&deltaInputSeq != 0 {
+		s.InputSeq[0], s.InputSeq[1] = r.U32(), r.U32()
+	}
+	return s, r.Err()
+}
+
+// sendState sends the current state to one recipient: a delta against the
+// tick it last acknowledged when possible, otherwise the shared keyframe.
+// deltas caches encoded deltas by base tick so recipients that acked the
+// same tick share bytes.
+func (r *Room) sendState(ep PlayerEndpoint, key *ws.Broadcast, keyframe bool, deltas *[]encodedDelta) {
+	c, ok := ep.(StreamEndpoint)
+	if !ok {
+		ep.SendShared(key)
+		return
+	}
+	wantKey := c.TakeKeyframeRequest()
+	baseTick, canDelta := c.DeltaBase()
+	if keyframe || wantKey || !canDelta {
+		c.SendShared(key)
+		return
+	}
+	base := r.history.get(baseTick)
+	if base == nil || baseTick >= r.state.Tick {
+		c.SendShared(key)
+		return
+	}
+	for _, d := range *deltas {
+		if d.base == baseTick {
+			c.SendRaw(d.data)
+			return
+		}
+	}
+	data, err := ws.EncodeBinary(ws.NewMessage(ws.MsgGameStateDelta, r.state.Tick, stateDelta{base: base, cur: &r.state}))
+	if err != nil {
+		c.SendShared(key)
+		return
+	}
+	*deltas = append(*deltas, encodedDelta{base: baseTick, data: data})
+	c.SendRaw(data)
+}
+
+type encodedDelta struct {
+	base uint32
+	data []byte
+}
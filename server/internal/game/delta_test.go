@@ -0,0 +1,89 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+// onWire returns s as a client holds it: only the fields the binary form
+// carries.
+func onWire(t *testing.T, s GameState) GameState {
+	t.Helper()
+	b, _ := s.AppendBinary(nil)
+	var out GameState
+	if err := out.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// TestDeltaRoundTrip rebuilds every tick of a full bot game from deltas
+// against bases at several distances back, the way a client acking late
+// would, and checks each against the keyframe it stands in for.
+func TestDeltaRoundTrip(t *testing.T) {
+	_, states := recordBotGame(t, 11, ClassicRules())
+	for i := range states {
+		// Vary what the delta carries beyond the bots' input, which has none.
+		states[i].InputSeq = [2]uint32{uint32(i / 3), uint32(i / 5)}
+	}
+
+	var applied, bytes, full int
+	for i, cur := range states {
+		want := onWire(t, cur)
+		for _, back := range []int{1, 2, 7, deltaHistory - 1} {
+			if i < back {
+				break
+			}
+			base := &states[i-back]
+			payload, err := stateDelta{base: base, cur: &cur}.AppendBinary(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ApplyDelta(onWire(t, *base), cur.Tick, payload)
+			if err != nil {
+				t.Fatalf("tick %d from %d: %v", cur.Tick, base.Tick, err)
+			}
+			if got != want {
+				t.Fatalf("tick %d from %d: delta rebuilt a different state\nwant %+v\ngot  %+v", cur.Tick, base.Tick, want, got)
+			}
+			if back == 1 {
+				key, _ := cur.AppendBinary(nil)
+				bytes, full = bytes+len(payload), full+len(key)
+			}
+			applied++
+		}
+	}
+	if states[len(states)-1].Phase != PhaseGameOver {
+		t.Fatal("game didn't finish")
+	}
+	t.Logf("%d deltas over %d ticks; tick-to-tick deltas are %.0f%% of keyframe size",
+		applied, len(states), 100*float64(bytes)/float64(full))
+}
+
+func TestDeltaWrongBase(t *testing.T) {
+	_, states := recordBotGame(t, 11, ClassicRules())
+	payload, _ := stateDelta{base: &states[10], cur: &states[11]}.AppendBinary(nil)
+	if _, err := ApplyDelta(states[9], states[11].Tick, payload); !errors.Is(err, ErrDeltaBase) {
+		t.Fatalf("applied to the wrong base: err %v, want ErrDeltaBase", err)
+	}
+	if _, err := ApplyDelta(states[10], states[11].Tick, payload[:len(payload)-1]); err == nil {
+		t.Fatal("truncated delta applied without error")
+	}
+}
+
+func TestStateHistoryWindow(t *testing.T) {
+	var h stateHistory
+	for tick := uint32(1); tick <= 3*deltaHistory; tick++ {
+		h.push(&GameState{Tick: tick})
+	}
+	last := uint32(3 * deltaHistory)
+	if s := h.get(last - deltaHistory + 1); s == nil || s.Tick != last-deltaHistory+1 {
+		t.Fatalf("oldest tick in the window is gone: %v", s)
+	}
+	if s := h.get(last - deltaHistory); s != nil {
+		t.Fatalf("tick %d outlived the window", last-deltaHistory)
+	}
+	if h.get(0) != nil {
+		t.Fatal("tick 0 is never a delta base")
+	}
+}
@@ -0,0 +1,185 @@
+package game
+
+import (
+	"sync"
+
+	"github.com/vladimirvolkov/basketball/server/internal/ws"
+)
+
+// Player endpoints.
+//
+// A room talks to its players and spectators through PlayerEndpoint rather
+// than a websocket, so it can be driven entirely in-process. WSEndpoint
+// adapts a *ws.Conn; MemEndpoint is a pair of channels for tools and
+// simulations that play matches without a network.
+
+// PlayerEndpoint is one seat's (or one spectator's) connection to a room.
+type PlayerEndpoint interface {
+	ID() string
+	// Name is the nickname shown in GameStart.
+	Name() string
+	// Send queues msg without blocking; it may be dropped if the endpoint
+	// is not keeping up.
+	Send(msg ws.Message)
+	// SendShared queues a message going to many recipients, letting the
+	// endpoint reuse an encoding another recipient already paid for.
+	SendShared(b *ws.Broadcast)
+	// Messages returns what the player sends. It is closed when the player
+	// goes away.
+	Messages() <-chan ws.Message
+	Close()
+}
+
+// StreamEndpoint is a PlayerEndpoint whose client keeps its own copy of the
+// state: it acknowledges ticks, so it can be sent deltas against them, and
+// can ask for a fresh keyframe. Endpoints without it get every state whole.
+type StreamEndpoint interface {
+	PlayerEndpoint
+	// ResetStream forgets acknowledged ticks and asks for a keyframe.
+	ResetStream()
+	// TakeKeyframeRequest reports (and clears) a pending keyframe request.
+	TakeKeyframeRequest() bool
+	// DeltaBase returns the last acknowledged tick, or false if the endpoint
+	// can't decode deltas.
+	DeltaBase() (tick uint32, ok bool)
+	// SendRaw queues a binary-encoded message.
+	SendRaw(data []byte)
+}
+
+// seatBinder is implemented by endpoints that outlive the room. The room
+// notes each seat's origin when it is created, and a connection resuming the
+// seat takes it over along with the nickname.
+type seatBinder interface {
+	origin() seatOrigin
+	bindSeat(nickname string, o seatOrigin)
+}
+
+// seatOrigin is how a player came to its seat: the matchmaking mode and what
+// it asked for there. Play Again after the game goes back to the same place.
+type seatOrigin struct {
+	mode     string // "", "tournament", "private" or "bot"
+	botLevel string
+	rules    string
+}
+
+// ── WebSocket ──
+
+// WSEndpoint is a PlayerEndpoint over a client's websocket.
+type WSEndpoint struct {
+	Conn *ws.Conn
+}
+
+func NewWSEndpoint(c *ws.Conn) *WSEndpoint {
+	return &WSEndpoint{Conn: c}
+}
+
+func (w *WSEndpoint) ID() string                  { return w.Conn.ID }
+func (w *WSEndpoint) Name() string                { return w.Conn.Nickname }
+func (w *WSEndpoint) Send(msg ws.Message)         { w.Conn.Send(msg) }
+func (w *WSEndpoint) SendShared(b *ws.Broadcast)  { b.SendTo(w.Conn) }
+func (w *WSEndpoint) Messages() <-chan ws.Message { return w.Conn.Messages() }
+func (w *WSEndpoint) Close()                      { w.Conn.Close() }
+func (w *WSEndpoint) ResetStream()                { w.Conn.ResetStream() }
+func (w *WSEndpoint) TakeKeyframeRequest() bool   { return w.Conn.TakeKeyframeRequest() }
+func (w *WSEndpoint) SendRaw(data []byte)         { w.Conn.SendRaw(data) }
+
+// DeltaBase reports the client's acked tick; only the binary codec has a
+// delta message.
+func (w *WSEndpoint) DeltaBase() (uint32, bool) {
+	return w.Conn.AckedTick(), w.Conn.Codec == ws.CodecBinary
+}
+
+func (w *WSEndpoint) origin() seatOrigin {
+	return seatOrigin{mode: w.Conn.Mode, botLevel: w.Conn.BotLevel, rules: w.Conn.Rules}
+}
+
+// bindSeat gives a resuming connection the seat's nickname and origin, so
+// Play Again afterwards goes back to the right queue with the same settings.
+func (w *WSEndpoint) bindSeat(nickname string, o seatOrigin) {
+	w.Conn.SetNickname(nickname)
+	w.Conn.Mode, w.Conn.BotLevel, w.Conn.Rules = o.mode, o.botLevel, o.rules
+}
+
+// ── In-process ──
+
+// MemEndpoint is an in-process PlayerEndpoint. What the room sends is queued
+// on Outbox; Deliver hands the room a message as if the player had sent it.
+type MemEndpoint struct {
+	id   string
+	name string
+	out  chan ws.Message
+	in   chan ws.Message
+	done chan struct{}
+
+	inMu      sync.Mutex // orders Deliver against closing in
+	closeOnce sync.Once
+}
+
+// NewMemEndpoint returns an endpoint whose Outbox holds up to buffer
+// messages; further sends are dropped until it is drained, like a slow
+// client's.
+func NewMemEndpoint(id, name string, buffer int) *MemEndpoint {
+	return &MemEndpoint{
+		id:   id,
+		name: name,
+		out:  make(chan ws.Message, buffer),
+		in:   make(chan ws.Message, inputQueueCap),
+		done: make(chan struct{}),
+	}
+}
+
+func (m *MemEndpoint) ID() string                  { return m.id }
+func (m *MemEndpoint) Name() string                { return m.name }
+func (m *MemEndpoint) SendShared(b *ws.Broadcast)  { m.Send(b.Message()) }
+func (m *MemEndpoint) Messages() <-chan ws.Message { return m.in }
+
+func (m *MemEndpoint) Send(msg ws.Message) {
+	select {
+	case m.out <- msg:
+	default:
+	}
+}
+
+// Outbox returns the messages the room has sent. Payloads are unencoded;
+// read them with DecodePayload.
+func (m *MemEndpoint) Outbox() <-chan ws.Message {
+	return m.out
+}
+
+// Deliver passes msg to the room, blocking while its inbound buffer is full.
+// Returns false once the endpoint is closed.
+func (m *MemEndpoint) Deliver(msg ws.Message) bool {
+	m.inMu.Lock()
+	defer m.inMu.Unlock()
+	select {
+	case <-m.done:
+		return false
+	default:
+	}
+	select {
+	case m.in <- msg:
+		return true
+	case <-m.done:
+		return false
+	}
+}
+
+// SendInput delivers one tick of player input.
+func (m *MemEndpoint) SendInput(in PlayerInput) bool {
+	return m.Deliver(ws.NewMessage(ws.MsgPlayerInput, in.Tick, in))
+}
+
+// Close disconnects the player: the room sees Messages close.
+func (m *MemEndpoint) Close() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+		m.inMu.Lock()
+		close(m.in)
+		m.inMu.Unlock()
+	})
+}
+
+// Done is closed by Close.
+func (m *MemEndpoint) Done() <-chan struct{} {
+	return m.done
+}
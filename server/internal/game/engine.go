@@ -2,57 +2,171 @@ package game
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"runtime"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/vladimirvolkov/basketball/server/internal/logging"
+	"github.com/vladimirvolkov/basketball/server/internal/metrics"
 )
 
 // Engine distributes game rooms across CPU-pinned worker goroutines.
 // Instead of each room running its own goroutine with its own timer (100+
 // goroutines competing for scheduling), the Engine uses exactly NumCPU workers,
 // each pinned to an OS thread via LockOSThread, processing rooms in parallel
-// batches at a single synchronized 60 Hz tick.
+// batches at a single synchronized tick rate (SetTickRate). A room simulating
+// slower than the engine skips the worker ticks it doesn't need.
+//
+// Rooms go to the worker with the least measured work and are moved between
+// workers when finished games leave them uneven (see balance.go).
 type Engine struct {
 	workers []*gameWorker
-	next    atomic.Uint64
+	placeMu sync.Mutex // serializes placement and migration
+	rooms   *sync.Map  // room ID → *Room, for lookups outside the tick
+	policy  LoadPolicy // see overload.go
+
+	stop    context.CancelFunc
+	running sync.WaitGroup // workers
 }
 
 type gameWorker struct {
-	mu    sync.Mutex
-	rooms []*Room
+	id     int
+	rate   int           // ticks per second
+	budget time.Duration // time between ticks
+	mu     sync.Mutex    // held for a whole tickAll
+	rooms  []*Room
+	index  *sync.Map // shared with Engine.rooms
+
+	// Load, readable without mu. cost is the sum of the rooms' tick costs as
+	// of the last tick, plus an estimate for each room placed since.
+	nRooms    atomic.Int32
+	cost      atomic.Int64 // nanoseconds
+	tickNanos atomic.Int64 // moving average of tickAll's wall time
+	saturated atomic.Bool
+
+	policy     LoadPolicy
+	overrunLog logging.Sampler
+	skipLog    logging.Sampler
+
+	tickTime     *metrics.Histogram // wall time of each tickAll
+	overruns     *metrics.Counter   // ticks that took longer than budget
+	skippedTicks *metrics.Counter
 }
 
-// NewEngine creates a game engine with one worker per CPU core.
+// tickBuckets span 0.1 ms to 51 ms; a tick has 16.7 ms at 60 Hz.
+var tickBuckets = metrics.ExpBuckets(0.0001, 2, 10)
+
+// NewEngine creates a game engine with one worker per CPU core. Each worker's
+// tick time, overruns, room count and load are registered in
+// metrics.Default, so a process should only create one.
 func NewEngine(numWorkers int) *Engine {
 	if numWorkers <= 0 {
 		numWorkers = runtime.NumCPU()
 	}
 	e := &Engine{
 		workers: make([]*gameWorker, numWorkers),
+		rooms:   &sync.Map{},
 	}
 	for i := range e.workers {
-		e.workers[i] = &gameWorker{}
+		id := strconv.Itoa(i)
+		w := &gameWorker{
+			id:           i,
+			index:        e.rooms,
+			overrunLog:   logging.Sampler{Interval: 10 * time.Second},
+			skipLog:      logging.Sampler{Interval: 10 * time.Second},
+			tickTime:     metrics.NewHistogram("basketball_worker_tick_seconds", "Time a worker takes to tick all its rooms.", tickBuckets, "worker", id),
+			overruns:     metrics.NewCounter("basketball_worker_overruns_total", "Ticks that took a worker longer than the tick interval.", "worker", id),
+			skippedTicks: metrics.NewCounter("basketball_worker_skipped_ticks_total", "Ticks a worker dropped to catch up after a stall.", "worker", id),
+		}
+		metrics.NewGaugeFunc("basketball_worker_rooms", "Rooms assigned to a worker.", w.roomCount, "worker", id)
+		metrics.NewGaugeFunc("basketball_worker_load_seconds", "Summed per-room tick cost of a worker's rooms.", func() float64 {
+			return time.Duration(w.cost.Load()).Seconds()
+		}, "worker", id)
+		metrics.NewGaugeFunc("basketball_worker_saturated", "1 while a worker's average tick is over the saturation threshold.", func() float64 {
+			if w.saturated.Load() {
+				return 1
+			}
+			return 0
+		}, "worker", id)
+		e.workers[i] = w
 	}
-	log.Printf("game engine created with %d workers", numWorkers)
+	e.SetTickRate(DefaultTickRate)
+	slog.Info("game engine created", "workers", numWorkers)
 	return e
 }
 
-// Start launches all workers. Each worker pins itself to a dedicated OS thread.
+// SetTickRate sets how many times a second the workers tick. No room should
+// simulate faster (see RuleBook.MaxTickRate), or it takes its extra ticks in
+// bursts. Call before Start.
+func (e *Engine) SetTickRate(hz int) {
+	for _, w := range e.workers {
+		w.rate = hz
+		w.budget = time.Second / time.Duration(hz)
+	}
+}
+
+// Start launches all workers, each pinned to a dedicated OS thread, and the
+// loop that rebalances them.
 func (e *Engine) Start(ctx context.Context) {
+	ctx, e.stop = context.WithCancel(ctx)
 	for i, w := range e.workers {
-		go w.run(ctx, i)
+		e.running.Add(1)
+		go func() {
+			defer e.running.Done()
+			w.run(ctx, i)
+		}()
 	}
+	go e.balanceLoop(ctx)
 }
 
-// AddRoom assigns a room to the least-loaded worker (round-robin).
+// Stop halts the workers and waits for their last tick to finish. Rooms
+// still playing are not ticked again, so nothing they record comes after
+// Stop returns.
+func (e *Engine) Stop() {
+	if e.stop != nil {
+		e.stop()
+	}
+	e.running.Wait()
+}
+
+// AddRoom assigns a room to the least-loaded worker.
 func (e *Engine) AddRoom(r *Room) {
-	idx := e.next.Add(1) % uint64(len(e.workers))
-	w := e.workers[idx]
-	w.mu.Lock()
-	w.rooms = append(w.rooms, r)
-	w.mu.Unlock()
+	e.placeMu.Lock()
+	defer e.placeMu.Unlock()
+	w := e.leastLoaded()
+	r.tickCost = e.meanRoomCost() // until the room's own ticks are measured
+	e.rooms.Store(r.id, r)
+	w.add(r)
+}
+
+// Rooms returns a summary of every live room, sorted by ID for stable output.
+func (e *Engine) Rooms() []RoomInfo {
+	var out []RoomInfo
+	e.rooms.Range(func(_, v any) bool {
+		out = append(out, v.(*Room).Info())
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Spectate attaches conn as a read-only viewer of the live room with the given ID.
+func (e *Engine) Spectate(conn PlayerEndpoint, roomID string) error {
+	v, ok := e.rooms.Load(roomID)
+	if !ok {
+		return ErrRoomNotFound
+	}
+	if err := v.(*Room).AddSpectator(conn); err != nil {
+		return err
+	}
+	slog.Info("spectating", "conn", conn.ID(), "room", roomID)
+	return nil
 }
 
 func (w *gameWorker) run(ctx context.Context, id int) {
@@ -61,7 +175,7 @@ func (w *gameWorker) run(ctx context.Context, id int) {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 
-	const interval = time.Second / TickRate
+	interval := w.budget
 	next := time.Now().Add(interval)
 
 	for {
@@ -76,29 +190,58 @@ func (w *gameWorker) run(ctx context.Context, id int) {
 			time.Sleep(next.Sub(now))
 		}
 
+		start := time.Now()
 		w.tickAll()
+		took := time.Since(start)
+		w.tickTime.Observe(took.Seconds())
+		w.afterTick(took)
 		next = next.Add(interval)
 
 		// Skip ahead after a stall (GC, OS scheduling) to avoid burst
-		if time.Now().After(next.Add(2 * interval)) {
-			next = time.Now().Add(interval)
+		if now := time.Now(); now.After(next.Add(2 * interval)) {
+			behind := now.Sub(next)
+			w.skipped(int(behind/interval)+1, behind)
+			next = now.Add(interval)
 		}
 	}
 }
 
+func (w *gameWorker) roomCount() float64 {
+	return float64(w.nRooms.Load())
+}
+
+// add appends r, counting its current cost estimate.
+func (w *gameWorker) add(r *Room) {
+	w.mu.Lock()
+	w.rooms = append(w.rooms, r)
+	w.nRooms.Add(1)
+	w.cost.Add(r.tickCost)
+	w.mu.Unlock()
+}
+
 func (w *gameWorker) tickAll() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	// Tick all rooms; remove finished ones in-place.
+	// Tick all rooms, timing each; remove finished ones in-place.
 	alive := w.rooms[:0]
+	var cost int64
+	halfRate := w.policy.HalfRateBroadcast && w.saturated.Load()
 	for _, r := range w.rooms {
-		if r.TickExternal() {
+		r.halfRate = halfRate
+		start := time.Now()
+		if r.TickExternal(w.rate) {
+			r.lastTick = int64(time.Since(start))
+			r.tickCost += (r.lastTick - r.tickCost) / costSmoothing
+			cost += r.tickCost
 			alive = append(alive, r)
 		} else {
+			w.index.Delete(r.id)
 			close(r.done)
 		}
 	}
+	w.cost.Store(cost)
+	w.nRooms.Store(int32(len(alive)))
 	// Clear tail references so GC can collect removed rooms
 	for i := len(alive); i < len(w.rooms); i++ {
 		w.rooms[i] = nil
@@ -0,0 +1,113 @@
+package game
+
+import (
+	"math"
+	"sync"
+)
+
+// Recent form: how casual players have been doing lately, for pairing the
+// regular queue.
+//
+// Casual games are unrated, so each nickname keeps its last formGames
+// results instead, in memory only. A player's win rate over them, pulled
+// towards even by one phantom win and one phantom loss so a single game
+// doesn't make anyone a champion, becomes a rating on the Glicko scale: the
+// rating that would expect that win rate against an average (DefaultRating)
+// player. Players with tournament games are paired by their tournament
+// rating instead.
+
+// formGames is how many recent casual results a nickname's form covers.
+const formGames = 20
+
+// RecentForm tracks casual results per nickname. Safe for concurrent use.
+type RecentForm struct {
+	tournament *Tournament // nil: casual form for everyone
+
+	mu    sync.Mutex
+	games map[string]*formRing
+}
+
+// formRing holds a nickname's last formGames scores (1 win, 0.5 draw, 0 loss).
+type formRing struct {
+	scores [formGames]float64
+	next   int
+	n      int
+}
+
+func (f *formRing) add(score float64) {
+	f.scores[f.next] = score
+	f.next = (f.next + 1) % formGames
+	f.n = min(f.n+1, formGames)
+}
+
+// winRate is the ring's mean score with one win and one loss added.
+func (f *formRing) winRate() float64 {
+	sum := 1.0
+	for _, s := range f.scores[:f.n] {
+		sum += s
+	}
+	return sum / float64(f.n+2)
+}
+
+// NewRecentForm returns an empty form. Players known to t are rated from the
+// tournament instead.
+func NewRecentForm(t *Tournament) *RecentForm {
+	return &RecentForm{tournament: t, games: make(map[string]*formRing)}
+}
+
+// Record adds a casual game between nick1 and nick2 to both players' form.
+// winner is 0 or 1, or -1 for a draw.
+func (f *RecentForm) Record(nick1, nick2 string, winner int) {
+	score := 0.5
+	switch winner {
+	case 0:
+		score = 1
+	case 1:
+		score = 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ring(nick1).add(score)
+	f.ring(nick2).add(1 - score)
+}
+
+// ring returns nickname's results, creating them. Requires f.mu.
+func (f *RecentForm) ring(nickname string) *formRing {
+	r, ok := f.games[nickname]
+	if !ok {
+		r = &formRing{}
+		f.games[nickname] = r
+	}
+	return r
+}
+
+// SkillRating returns the rating the regular queue pairs nickname by: its
+// tournament rating if it has played a tournament game, otherwise its casual
+// form. A newcomer gets DefaultRating.
+func (f *RecentForm) SkillRating(nickname string) float64 {
+	if f.tournament != nil {
+		if r, ok := f.tournament.ratingOf(nickname); ok {
+			return r.Rating
+		}
+	}
+	f.mu.Lock()
+	w := 0.5
+	if r, ok := f.games[nickname]; ok {
+		w = r.winRate()
+	}
+	f.mu.Unlock()
+	return DefaultRating + 400*math.Log10(w/(1-w))
+}
+
+// SetRecentForm makes a casual room record its result in f. Call before
+// Start.
+func (r *Room) SetRecentForm(f *RecentForm) {
+	r.form = f
+}
+
+// recordForm adds the finished game to both players' form.
+func (r *Room) recordForm() {
+	if r.form != nil {
+		r.form.Record(r.nicknames[0], r.nicknames[1], int(r.state.Winner))
+	}
+}
@@ -0,0 +1,41 @@
+package game
+
+import "testing"
+
+func TestRecentFormRatesByWinRate(t *testing.T) {
+	f := NewRecentForm(nil)
+	if got := f.SkillRating("Newcomer"); got != DefaultRating {
+		t.Fatalf("newcomer rated %v, want %v", got, DefaultRating)
+	}
+	for range 3 {
+		f.Record("Alice", "Bob", 0)
+	}
+	f.Record("Bob", "Carol", -1)
+	alice, bob, carol := f.SkillRating("Alice"), f.SkillRating("Bob"), f.SkillRating("Carol")
+	if !(alice > DefaultRating && carol == DefaultRating && bob < DefaultRating) {
+		t.Fatalf("Alice %v, Carol %v, Bob %v: want the winner above even, the drawn player even, the loser below", alice, carol, bob)
+	}
+
+	// Only the last formGames results count.
+	for range formGames {
+		f.Record("Bob", "Alice", 0)
+	}
+	if f.SkillRating("Bob") <= f.SkillRating("Alice") {
+		t.Fatal("Bob's old losses still count against him")
+	}
+}
+
+func TestRecentFormPrefersTournamentRating(t *testing.T) {
+	tour, err := NewTournament(&memStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tour.Close()
+	tour.RecordResult("Alice", "Bob", 11, 2)
+	f := NewRecentForm(tour)
+	f.Record("Bob", "Alice", 0) // a casual upset
+
+	if got, want := f.SkillRating("Alice"), tour.GetStats("Alice").Rating; got != want {
+		t.Fatalf("Alice rated %v, want her tournament rating %v", got, want)
+	}
+}
@@ -38,7 +38,7 @@ var (
 
 const rimRadius = float32(5)
 
-func CheckBallHoop(b *BallState, h *Hoop, prevY float32) bool {
+func CheckBallHoop(b *BallState, h *Hoop, prevY float32, rules *Rules) bool {
 	// ── 1. Check scoring FIRST — before collisions modify position.
 	// A clean shot through the hoop must score before rim physics
 	// can accidentally push the ball out of the scoring zone.
@@ -49,16 +49,16 @@ func CheckBallHoop(b *BallState, h *Hoop, prevY float32) bool {
 	}
 
 	// ── 2. Rim collision (circle vs circle at each rim endpoint)
-	checkRimPoint(b, h.RimLeftX, h.RimY)
-	checkRimPoint(b, h.RimRightX, h.RimY)
+	checkRimPoint(b, h.RimLeftX, h.RimY, rules)
+	checkRimPoint(b, h.RimRightX, h.RimY, rules)
 
 	// ── 3. Backboard collision
-	checkBackboard(b, h)
+	checkBackboard(b, h, rules)
 
 	return false
 }
 
-func checkRimPoint(b *BallState, rimX, rimY float32) {
+func checkRimPoint(b *BallState, rimX, rimY float32, rules *Rules) {
 	dx := b.X - rimX
 	dy := b.Y - rimY
 	distSq := dx*dx + dy*dy
@@ -95,12 +95,12 @@ func checkRimPoint(b *BallState, rimX, rimY float32) {
 		b.VY -= 2 * dot * ny
 
 		// Apply restitution
-		b.VX *= RestitutionRim
-		b.VY *= RestitutionRim
+		b.VX *= rules.RestitutionRim
+		b.VY *= rules.RestitutionRim
 	}
 }
 
-func checkBackboard(b *BallState, h *Hoop) {
+func checkBackboard(b *BallState, h *Hoop, rules *Rules) {
 	// Skip if ball is not in the Y range of the backboard
 	if b.Y+BallRadius <= h.BackboardTopY || b.Y-BallRadius >= h.BackboardBottomY {
 		return
@@ -116,14 +116,14 @@ func checkBackboard(b *BallState, h *Hoop) {
 		// Without the velocity check, balls behind the backboard teleport through.
 		if b.VX < 0 && b.X-BallRadius < bbRight && b.X > h.BackboardX {
 			b.X = bbRight + BallRadius
-			b.VX = float32(math.Abs(float64(b.VX))) * RestitutionBackboard
+			b.VX = float32(math.Abs(float64(b.VX))) * rules.RestitutionBackboard
 		}
 	} else {
 		bbLeft := h.BackboardX - bbHalf
 		// Ball must be moving right (toward backboard) and overlapping the left face.
 		if b.VX > 0 && b.X+BallRadius > bbLeft && b.X < h.BackboardX {
 			b.X = bbLeft - BallRadius
-			b.VX = -float32(math.Abs(float64(b.VX))) * RestitutionBackboard
+			b.VX = -float32(math.Abs(float64(b.VX))) * rules.RestitutionBackboard
 		}
 	}
 }
@@ -0,0 +1,84 @@
+package game
+
+// Input queueing.
+//
+// Clients number their inputs with an increasing Seq. Every input is queued
+// and consumed in order, one per playing tick, so a jump or shot pressed twice
+// between ticks is never overwritten. The last consumed Seq per player is
+// echoed in GameState.InputSeq so clients can drop acknowledged inputs from
+// their prediction buffer and replay the rest on top of the server state.
+
+const (
+	// inputQueueCap bounds queued inputs per player; beyond it new inputs fold
+	// into the newest queued one instead of growing the queue.
+	inputQueueCap = 16
+	// maxInputBacklog is how many inputs may wait after a tick. Anything
+	// beyond is merged into the current tick so a burst (or a countdown's
+	// worth of presses) doesn't add permanent latency.
+	maxInputBacklog = 3
+)
+
+// inputQueue is a per-player FIFO of pending inputs. Guarded by Room.inputMu.
+type inputQueue struct {
+	buf     [inputQueueCap]PlayerInput
+	head, n int
+	lastIn  uint32      // highest Seq accepted into the queue
+	held    PlayerInput // last consumed input; movement persists between inputs
+}
+
+// push queues an input. Sequenced inputs that are not newer than the last
+// accepted one are duplicates or stale retransmits and are dropped. Inputs
+// with Seq 0 come from clients that don't number their inputs and are always
+// accepted.
+func (q *inputQueue) push(in PlayerInput) {
+	if in.Seq != 0 {
+		if in.Seq <= q.lastIn {
+			return
+		}
+		q.lastIn = in.Seq
+	}
+	if q.n == inputQueueCap {
+		tail := &q.buf[(q.head+q.n-1)%inputQueueCap]
+		*tail = mergeInputs(*tail, in)
+		return
+	}
+	q.buf[(q.head+q.n)%inputQueueCap] = in
+	q.n++
+}
+
+func (q *inputQueue) popFront() PlayerInput {
+	in := q.buf[q.head]
+	q.head = (q.head + 1) % inputQueueCap
+	q.n--
+	return in
+}
+
+// next returns the input to apply this tick. With nothing queued the player
+// keeps moving as before but jump/shoot are not repeated.
+func (q *inputQueue) next() PlayerInput {
+	if q.n == 0 {
+		in := q.held
+		in.Jump = false
+		in.Shoot = false
+		return in
+	}
+	in := q.popFront()
+	for q.n > maxInputBacklog {
+		in = mergeInputs(in, q.popFront())
+	}
+	q.held = in
+	return in
+}
+
+// mergeInputs folds b into a: one-shot actions accumulate, the newest
+// movement and sequence win.
+func mergeInputs(a, b PlayerInput) PlayerInput {
+	a.MoveX = b.MoveX
+	a.Jump = a.Jump || b.Jump
+	a.Shoot = a.Shoot || b.Shoot
+	a.Tick = b.Tick
+	if b.Seq != 0 {
+		a.Seq = b.Seq
+	}
+	return a
+}
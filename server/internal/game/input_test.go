@@ -0,0 +1,126 @@
+package game
+
+import "testing"
+
+func TestInputQueueDropsStaleSeq(t *testing.T) {
+	var q inputQueue
+	for _, seq := range []uint32{1, 2, 2, 1, 3} {
+		q.push(PlayerInput{MoveX: int8(seq % 2), Seq: seq})
+	}
+	for _, want := range []uint32{1, 2, 3} {
+		if in := q.next(); in.Seq != want {
+			t.Fatalf("got seq %d, want %d", in.Seq, want)
+		}
+	}
+	if q.n != 0 {
+		t.Fatalf("%d inputs left; duplicates and retransmits were queued", q.n)
+	}
+}
+
+func TestInputQueueKeepsOrder(t *testing.T) {
+	var q inputQueue
+	moves := []int8{1, -1, 0}
+	for i, m := range moves {
+		q.push(PlayerInput{MoveX: m, Jump: i == 1, Seq: uint32(i + 1)})
+	}
+	for i, m := range moves {
+		in := q.next()
+		if in.MoveX != m || in.Jump != (i == 1) {
+			t.Fatalf("tick %d: got %+v, want input %d", i, in, i+1)
+		}
+	}
+}
+
+func TestInputQueueUnsequenced(t *testing.T) {
+	var q inputQueue
+	q.push(PlayerInput{Seq: 4})
+	q.push(PlayerInput{MoveX: 1}) // seq 0: a client that doesn't number inputs
+	q.push(PlayerInput{MoveX: -1})
+	if q.n != 3 {
+		t.Fatalf("queued %d inputs, want 3", q.n)
+	}
+}
+
+// Ticks with no new input keep the player moving, but a jump or shot fires
+// once, not on every tick until the next input.
+func TestInputQueueHoldsMovementNotActions(t *testing.T) {
+	var q inputQueue
+	q.push(PlayerInput{MoveX: 1, Jump: true, Shoot: true, Seq: 1})
+	if in := q.next(); !in.Jump || !in.Shoot {
+		t.Fatalf("first tick lost the press: %+v", in)
+	}
+	for range 3 {
+		if in := q.next(); in.MoveX != 1 || in.Jump || in.Shoot {
+			t.Fatalf("idle tick: got %+v, want movement held and no repeat press", in)
+		}
+	}
+}
+
+func TestInputQueueMergesBacklog(t *testing.T) {
+	var q inputQueue
+	// Six inputs arrive before a tick: the backlog beyond maxInputBacklog
+	// folds into this tick's input, keeping its presses and the newest move.
+	for seq := uint32(1); seq <= 6; seq++ {
+		q.push(PlayerInput{MoveX: int8(seq%3) - 1, Jump: seq == 2, Shoot: seq == 3, Seq: seq})
+	}
+	in := q.next()
+	if !in.Jump || !in.Shoot || in.MoveX != -1 || in.Seq != 3 {
+		t.Fatalf("merged input %+v, want jump+shoot with input 3's move and seq", in)
+	}
+	for _, want := range []uint32{4, 5, 6} {
+		if in := q.next(); in.Seq != want || in.Jump || in.Shoot {
+			t.Fatalf("got %+v, want plain input %d", in, want)
+		}
+	}
+}
+
+func TestInputQueueFullFoldsIntoTail(t *testing.T) {
+	var q inputQueue
+	for seq := uint32(1); seq <= inputQueueCap; seq++ {
+		q.push(PlayerInput{Seq: seq})
+	}
+	q.push(PlayerInput{MoveX: 1, Jump: true, Seq: inputQueueCap + 1})
+	q.push(PlayerInput{MoveX: -1, Shoot: true, Seq: inputQueueCap + 2})
+	if q.n != inputQueueCap {
+		t.Fatalf("queue grew to %d", q.n)
+	}
+	var last PlayerInput
+	for q.n > 0 {
+		last = q.next()
+	}
+	if !last.Jump || !last.Shoot || last.MoveX != -1 || last.Seq != inputQueueCap+2 {
+		t.Fatalf("overflow lost input: last %+v", last)
+	}
+}
+
+func TestMergeInputsKeepsSeq(t *testing.T) {
+	got := mergeInputs(PlayerInput{Seq: 7, Jump: true}, PlayerInput{MoveX: 1, Tick: 40})
+	if got.Seq != 7 || !got.Jump || got.MoveX != 1 || got.Tick != 40 {
+		t.Fatalf("got %+v: an unsequenced input must not reset the seq", got)
+	}
+}
+
+// TestRoomEchoesInputSeq checks inputs queued during the countdown wait for
+// play and that the consumed seq is echoed in the state.
+func TestRoomEchoesInputSeq(t *testing.T) {
+	r := newRoom([2]string{"A", "B"}, 1)
+	r.timeouts = RoomTimeouts{}
+	r.inputs[0].push(PlayerInput{MoveX: 1, Seq: 1})
+	r.inputs[0].push(PlayerInput{MoveX: 1, Seq: 2})
+	for !r.state.Phase.Live() {
+		r.tick()
+	}
+	if r.inputs[0].n != 2 {
+		t.Fatalf("countdown consumed input: %d left of 2", r.inputs[0].n)
+	}
+	r.tick()
+	r.inputs[0].push(PlayerInput{MoveX: 1, Seq: 2}) // retransmit
+	r.tick()
+	if got := r.state.InputSeq[0]; got != 2 {
+		t.Fatalf("InputSeq[0] = %d after two ticks, want 2", got)
+	}
+	r.tick()
+	if got := r.state.InputSeq[0]; got != 2 || r.state.Players[0].VX <= 0 {
+		t.Fatalf("idle tick: seq %d vx %v, want the last seq echoed and movement held", got, r.state.Players[0].VX)
+	}
+}
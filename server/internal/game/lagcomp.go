@@ -0,0 +1,49 @@
+package game
+
+// Lag compensation.
+//
+// A client shows its own player where it predicts it to be, but the
+// opponent as the server last sent it, a round trip old. Each input carries
+// the tick the client was displaying (PlayerInput.Tick), and a shot or steal
+// is judged against the opponent as it was on that tick: a steal reaches for
+// the holder where the stealer saw them, and a shot is blocked only by a
+// blocker the shooter could see. Everything else — where the ball goes, who
+// ends up with it — happens in the present.
+//
+// The rewind is worked out when the input is taken, capped at maxRewind, and
+// recorded with the input, so replays judge the same plays the same way. The
+// past states come from the room's stateHistory, which step() fills.
+
+// maxRewind is the furthest back, in ticks, an input is judged (250 ms at
+// 60 Hz). It fits the four bits a replay keeps for it.
+const maxRewind = 15
+
+// setRewind stamps in with how far behind the current state the client was,
+// from the tick it says it was displaying.
+func (r *Room) setRewind(in *PlayerInput) {
+	in.rewind = 0
+	if in.Tick == 0 || in.Tick >= r.state.Tick {
+		return
+	}
+	in.rewind = uint8(min(r.state.Tick-in.Tick, maxRewind))
+}
+
+// seenOpponent returns player i's opponent as player i's client showed it
+// when in was sent. Called from step() before the tick's physics, when the
+// latest stored state is the one this tick started from. Without a rewind,
+// or when the rewound state is gone or from another play, it is the
+// opponent as they are now.
+func (r *Room) seenOpponent(i int, in PlayerInput) *PlayerState {
+	cur := &r.state.Players[1-i]
+	if in.rewind == 0 {
+		return cur
+	}
+	past := r.history.get(r.state.Tick - 1 - uint32(in.rewind))
+	if past == nil || !past.Phase.Live() {
+		return cur
+	}
+	seen := *cur
+	then := &past.Players[1-i]
+	seen.X, seen.Y, seen.Grounded = then.X, then.Y, then.Grounded
+	return &seen
+}
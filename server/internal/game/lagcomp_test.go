@@ -0,0 +1,67 @@
+package game
+
+import "testing"
+
+// stealSetup returns a live room in which player 1 has the ball right next
+// to player 0 on the returned tick, then has moved out of reach.
+func stealSetup(t *testing.T) (*Room, uint32) {
+	t.Helper()
+	r := newRoom([2]string{"A", "B"}, 1)
+	r.timeouts = RoomTimeouts{}
+	for !r.state.Phase.Live() {
+		r.step([2]PlayerInput{})
+	}
+	s := &r.state
+	s.Ball.Owner, s.Ball.InFlight = 1, false
+	s.Players[1].HasBall = true
+	s.Players[0].X, s.Players[1].X = 400, 420
+	r.step([2]PlayerInput{})
+	seen := s.Tick
+
+	s.Players[1].X = 400 + 2*r.rules.StealRange
+	for range 5 {
+		r.step([2]PlayerInput{})
+	}
+	return r, seen
+}
+
+func TestStealJudgedWhereStealerSawHolder(t *testing.T) {
+	r, seen := stealSetup(t)
+	in := PlayerInput{Shoot: true, Tick: seen}
+	r.setRewind(&in)
+	r.step([2]PlayerInput{in, {}})
+	if r.state.Players[0].StealCooldown == 0 {
+		t.Fatal("no steal attempt at a holder who was in reach on the tick the stealer saw")
+	}
+
+	// The same press judged against the present is out of reach.
+	r, _ = stealSetup(t)
+	r.step([2]PlayerInput{{Shoot: true}, {}})
+	if r.state.Players[0].StealCooldown != 0 {
+		t.Fatal("steal attempted at a holder out of reach")
+	}
+}
+
+func TestRewindCapped(t *testing.T) {
+	r := newRoom([2]string{"A", "B"}, 1)
+	r.state.Tick = 100
+	for _, c := range []struct {
+		tick   uint32
+		rewind uint8
+	}{{0, 0}, {100, 0}, {120, 0}, {98, 2}, {85, 15}, {10, maxRewind}} {
+		in := PlayerInput{Tick: c.tick}
+		r.setRewind(&in)
+		if in.rewind != c.rewind {
+			t.Errorf("input from tick %d at tick 100: rewind %d, want %d", c.tick, in.rewind, c.rewind)
+		}
+	}
+}
+
+func TestReplayInputKeepsRewind(t *testing.T) {
+	for rewind := range uint8(maxRewind + 1) {
+		in := PlayerInput{MoveX: -1, Shoot: true, rewind: rewind}
+		if got := unpackInput(packInput(in)); got != in {
+			t.Fatalf("packed %+v, unpacked %+v", in, got)
+		}
+	}
+}
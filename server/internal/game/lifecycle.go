@@ -0,0 +1,186 @@
+package game
+
+import (
+	"time"
+
+	"github.com/vladimirvolkov/basketball/server/internal/ws"
+)
+
+// RoomTimeouts bound how long a room may sit without a game being played.
+type RoomTimeouts struct {
+	// GameOver is how long the final score stays up waiting for both players
+	// to press Play Again before the room is torn down.
+	GameOver time.Duration
+	// Idle ends a game in which neither player has sent input for this long.
+	// Zero disables it.
+	Idle time.Duration
+	// Reconnect is the grace window a dropped player has to resume its seat
+	// before forfeiting. The game is paused meanwhile. Zero forfeits on the
+	// first disconnect.
+	Reconnect time.Duration
+}
+
+var DefaultRoomTimeouts = RoomTimeouts{
+	GameOver:  20 * time.Second,
+	Idle:      60 * time.Second,
+	Reconnect: 15 * time.Second,
+}
+
+// PlayerEnd says what should happen to a player's connection once the room
+// is done. The room's owner reads it via Ends after Done closes.
+type PlayerEnd uint8
+
+const (
+	EndLeft    PlayerEnd = iota // disconnected — nothing to do
+	EndLobby                    // still connected; Play Again will requeue it
+	EndRequeue                  // asked to play again, the opponent didn't
+	EndRematch                  // both asked — same pair, fresh room
+	EndExpired                  // sat on the game-over screen past the timeout — close it
+	EndIdle                     // no input from either player — close it
+)
+
+// SetTimeouts overrides DefaultRoomTimeouts. Call before Start.
+func (r *Room) SetTimeouts(t RoomTimeouts) {
+	r.timeouts = t
+}
+
+// Players returns the room's two endpoints; a bot's seat is nil.
+func (r *Room) Players() [2]PlayerEndpoint {
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+	return r.conns
+}
+
+// Conns returns the websocket behind each seat, nil for bots and in-process
+// endpoints.
+func (r *Room) Conns() [2]*ws.Conn {
+	var conns [2]*ws.Conn
+	for i, c := range r.Players() {
+		if w, ok := c.(*WSEndpoint); ok {
+			conns[i] = w.Conn
+		}
+	}
+	return conns
+}
+
+// Bot returns the room's AI player, or nil if both seats are human.
+func (r *Room) Bot() *Bot {
+	for _, b := range r.bots {
+		if b != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+// Ends reports what to do with each player's connection. Only meaningful
+// after Done has closed.
+func (r *Room) Ends() [2]PlayerEnd {
+	r.endMu.Lock()
+	defer r.endMu.Unlock()
+	return r.ends
+}
+
+// requestRematch handles MsgJoinQueue from a player on the game-over screen.
+func (r *Room) requestRematch(playerIdx int) {
+	if !r.over.Load() {
+		return
+	}
+	r.endMu.Lock()
+	defer r.endMu.Unlock()
+	if r.ended || r.rematch[playerIdx] {
+		return
+	}
+	r.rematch[playerIdx] = true
+	other := 1 - playerIdx
+	if r.rematch[other] || r.bots[other] != nil { // bots always accept
+		r.log.Info("rematch accepted")
+		r.endLocked([2]PlayerEnd{EndRematch, EndRematch})
+		return
+	}
+	if r.isAway(other) {
+		// Opponent forfeited and never came back; don't wait on them.
+		var ends [2]PlayerEnd
+		ends[playerIdx] = EndRequeue
+		r.endLocked(ends)
+		return
+	}
+	r.sendTo(other, ws.NewMessage(ws.MsgRematchOffer, 0, ws.RematchOfferPayload{
+		PlayerIndex: uint8(playerIdx),
+	}))
+}
+
+// playerLeft ends the room after a disconnect. A remaining player who already
+// asked for a rematch goes straight back to matchmaking; otherwise it waits
+// in the lobby for its own Play Again.
+func (r *Room) playerLeft(playerIdx int) {
+	r.endMu.Lock()
+	defer r.endMu.Unlock()
+	if r.ended {
+		return
+	}
+	var ends [2]PlayerEnd
+	other := 1 - playerIdx
+	switch {
+	case r.isAway(other):
+		ends[other] = EndLeft
+	case r.rematch[other]:
+		ends[other] = EndRequeue
+	default:
+		ends[other] = EndLobby
+	}
+	r.endLocked(ends)
+}
+
+// tickGameOver counts down the game-over screen. Called from tick().
+func (r *Room) tickGameOver() {
+	r.overTicks++
+	if r.overTicks < r.rules.ticks(r.timeouts.GameOver.Seconds()) {
+		return
+	}
+	r.endMu.Lock()
+	defer r.endMu.Unlock()
+	if r.ended {
+		return
+	}
+	var ends [2]PlayerEnd
+	for i := range ends {
+		switch {
+		case r.isAway(i):
+			ends[i] = EndLeft
+		case r.rematch[i]:
+			ends[i] = EndRequeue
+		default:
+			ends[i] = EndExpired
+		}
+	}
+	r.log.Info("game-over timeout")
+	r.endLocked(ends)
+}
+
+// tickIdle ends the room once neither player has sent input for
+// timeouts.Idle. Called from tick() outside PhaseGameOver.
+func (r *Room) tickIdle() {
+	if r.inputSeen.Swap(false) {
+		r.idleTicks = 0
+		return
+	}
+	r.idleTicks++
+	if r.timeouts.Idle <= 0 || r.idleTicks < r.rules.ticks(r.timeouts.Idle.Seconds()) {
+		return
+	}
+	r.endMu.Lock()
+	defer r.endMu.Unlock()
+	if r.ended {
+		return
+	}
+	r.log.Info("idle, closing", "after", r.timeouts.Idle)
+	r.endLocked([2]PlayerEnd{EndIdle, EndIdle})
+}
+
+// endLocked records the outcome and stops the room. Requires endMu.
+func (r *Room) endLocked(ends [2]PlayerEnd) {
+	r.ended = true
+	r.ends = ends
+	r.cancel()
+}
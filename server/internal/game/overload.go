@@ -0,0 +1,116 @@
+package game
+
+// Overrun detection and load shedding.
+//
+// A worker overruns when ticking its rooms takes longer than its tick budget,
+// the time between engine ticks (16.7 ms at 60 Hz); after a long enough
+// stall it skips ticks altogether. Both are counted per worker, and overruns
+// are logged with the rooms that cost the most. A worker whose average tick
+// stays above LoadPolicy.SaturatedAt of the budget is saturated until it
+// drops back below three quarters of that, and while saturated the policy
+// may halve its rooms' snapshot rate or, when every worker is saturated,
+// have the hub refuse new rooms.
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// slowRoomsLogged is how many of the costliest rooms an overrun log names.
+const slowRoomsLogged = 3
+
+// LoadPolicy says how the engine degrades when workers can't keep up.
+type LoadPolicy struct {
+	// SaturatedAt is the fraction of the tick budget a worker's average
+	// tick must exceed to count as saturated. 0 means 0.8.
+	SaturatedAt float64
+	// HalfRateBroadcast caps a saturated worker's rooms' snapshot rate at
+	// every other tick (30 Hz for a 60 Hz room). They still simulate every
+	// tick.
+	HalfRateBroadcast bool
+	// RefuseNewRooms turns new rooms away while every worker is saturated.
+	RefuseNewRooms bool
+}
+
+func (p LoadPolicy) saturatedAt(budget time.Duration) int64 {
+	f := p.SaturatedAt
+	if f <= 0 {
+		f = 0.8
+	}
+	return int64(f * float64(budget))
+}
+
+// SetLoadPolicy sets how the engine degrades under load. Call before Start.
+func (e *Engine) SetLoadPolicy(p LoadPolicy) {
+	e.policy = p
+	for _, w := range e.workers {
+		w.policy = p
+	}
+}
+
+// RefuseNewRooms reports whether the policy turns new rooms away right now.
+func (e *Engine) RefuseNewRooms() bool {
+	return e.policy.RefuseNewRooms && e.Saturated()
+}
+
+// Saturated reports whether every worker is saturated.
+func (e *Engine) Saturated() bool {
+	for _, w := range e.workers {
+		if !w.saturated.Load() {
+			return false
+		}
+	}
+	return true
+}
+
+// afterTick does the worker's load bookkeeping once a tick that took took is
+// done.
+func (w *gameWorker) afterTick(took time.Duration) {
+	avg := w.tickNanos.Load()
+	avg += (int64(took) - avg) / costSmoothing
+	w.tickNanos.Store(avg)
+
+	if took > w.budget {
+		w.overruns.Inc()
+		if n, ok := w.overrunLog.Allow(); ok {
+			slog.Warn("worker tick overran", "worker", w.id, "took", took.Round(time.Microsecond),
+				"rooms", w.nRooms.Load(), "slowest", w.slowRooms(slowRoomsLogged), "suppressed", n)
+		}
+	}
+
+	limit := w.policy.saturatedAt(w.budget)
+	switch sat := w.saturated.Load(); {
+	case !sat && avg > limit:
+		w.saturated.Store(true)
+		slog.Warn("worker saturated", "worker", w.id, "avgTick", time.Duration(avg).Round(time.Microsecond),
+			"rooms", w.nRooms.Load(), "halfRate", w.policy.HalfRateBroadcast)
+	case sat && avg < limit*3/4:
+		w.saturated.Store(false)
+		slog.Info("worker recovered", "worker", w.id, "avgTick", time.Duration(avg).Round(time.Microsecond))
+	}
+}
+
+// skipped counts ticks dropped to catch up after a stall.
+func (w *gameWorker) skipped(n int, behind time.Duration) {
+	w.skippedTicks.Add(uint64(n))
+	if s, ok := w.skipLog.Allow(); ok {
+		slog.Warn("worker fell behind, skipping ticks", "worker", w.id, "behind", behind.Round(time.Microsecond),
+			"skipped", n, "suppressed", s)
+	}
+}
+
+// slowRooms names the n rooms whose last tick took longest, as "id (time)".
+func (w *gameWorker) slowRooms(n int) []string {
+	w.mu.Lock()
+	rooms := slices.Clone(w.rooms)
+	slices.SortFunc(rooms, func(a, b *Room) int { return cmp.Compare(b.lastTick, a.lastTick) })
+	out := make([]string, 0, n)
+	for _, r := range rooms[:min(n, len(rooms))] {
+		out = append(out, fmt.Sprintf("%s (%s)", r.id, time.Duration(r.lastTick).Round(time.Microsecond)))
+	}
+	w.mu.Unlock()
+	return out
+}
@@ -10,14 +10,14 @@ func NewPlayer(x, y float32, facing int8) PlayerState {
 	}
 }
 
-func ApplyInput(p *PlayerState, input PlayerInput) {
+func ApplyInput(p *PlayerState, input PlayerInput, rules *Rules) {
 	// Defender (no ball) moves faster; air control is reduced
-	speed := PlayerSpeedWithBall
+	speed := rules.PlayerSpeedWithBall
 	if !p.HasBall {
-		speed = DefenderSpeedBoost
+		speed = rules.DefenderSpeedBoost
 	}
 	if !p.Grounded {
-		speed *= AirControlMult
+		speed *= rules.AirControlMult
 	}
 	p.VX = float32(input.MoveX) * speed
 
@@ -27,21 +27,22 @@ func ApplyInput(p *PlayerState, input PlayerInput) {
 
 	if input.Jump && p.Grounded {
 		if p.HasBall {
-			p.VY = JumpVelocity
+			p.VY = rules.JumpVelocity
 		} else {
-			p.VY = DefenderJumpVelocity
+			p.VY = rules.DefenderJumpVelocity
 		}
 		p.Grounded = false
 	}
 }
 
-func StepPlayer(p *PlayerState) {
+func StepPlayer(p *PlayerState, rules *Rules) {
+	dt := rules.dt()
 	if !p.Grounded {
-		p.VY += Gravity * DT
+		p.VY += rules.Gravity * dt
 	}
 
-	p.X += p.VX * DT
-	p.Y += p.VY * DT
+	p.X += p.VX * dt
+	p.Y += p.VY * dt
 
 	// Floor collision
 	feetY := p.Y + PlayerHeight/2
@@ -0,0 +1,118 @@
+package game
+
+import "math"
+
+// Glicko-2 ratings for tournament players.
+//
+// Every game is its own rating period: both players are updated from their
+// pre-game ratings as soon as the result is recorded. See
+// http://www.glicko.net/glicko/glicko2.pdf for the algorithm.
+
+const (
+	DefaultRating     = 1500.0
+	DefaultRatingDev  = 350.0 // a new player's rating is very uncertain
+	DefaultVolatility = 0.06
+
+	glickoScale = 173.7178
+	glickoTau   = 0.5 // constrains volatility change per game
+	glickoEps   = 1e-6
+)
+
+// Rating is a Glicko-2 rating in the familiar 1500-centred scale.
+type Rating struct {
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+}
+
+// rating returns the player's rating, filling in defaults for players
+// recorded before ratings existed.
+func (s *PlayerStats) rating() Rating {
+	if s.RatingDeviation == 0 {
+		return Rating{DefaultRating, DefaultRatingDev, DefaultVolatility}
+	}
+	return Rating{s.Rating, s.RatingDeviation, s.Volatility}
+}
+
+func (s *PlayerStats) setRating(r Rating) {
+	s.Rating, s.RatingDeviation, s.Volatility = r.Rating, r.Deviation, r.Volatility
+}
+
+// updateRating returns p's new rating after one game against opp.
+// score is 1 for a win, 0.5 for a draw, 0 for a loss.
+func updateRating(p, opp Rating, score float64) Rating {
+	return ratePeriod(p, []ratedGame{{opp, score}})
+}
+
+// ratedGame is one result in a rating period, from the rated player's side.
+type ratedGame struct {
+	opp   Rating
+	score float64
+}
+
+// ratePeriod returns p's new rating after a rating period with games, the
+// paper's steps 2-8. The tournament rates every game on its own.
+func ratePeriod(p Rating, games []ratedGame) Rating {
+	mu := (p.Rating - DefaultRating) / glickoScale
+	phi := p.Deviation / glickoScale
+
+	var vInv, sum float64
+	for _, gm := range games {
+		muJ := (gm.opp.Rating - DefaultRating) / glickoScale
+		phiJ := gm.opp.Deviation / glickoScale
+		g := 1 / math.Sqrt(1+3*phiJ*phiJ/(math.Pi*math.Pi))
+		e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+		vInv += g * g * e * (1 - e)
+		sum += g * (gm.score - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+
+	sigma := newVolatility(phi, p.Volatility, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phiNew := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	muNew := mu + phiNew*phiNew*sum
+
+	return Rating{
+		Rating:     glickoScale*muNew + DefaultRating,
+		Deviation:  math.Min(glickoScale*phiNew, DefaultRatingDev),
+		Volatility: sigma,
+	}
+}
+
+// newVolatility solves for the updated volatility with the Illinois
+// algorithm (step 5 of the paper).
+func newVolatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(glickoTau*glickoTau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEps {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
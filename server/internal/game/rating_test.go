@@ -0,0 +1,49 @@
+package game
+
+import (
+	"math"
+	"testing"
+)
+
+// TestRatePeriodMatchesPaper runs the worked example from Glickman's
+// Glicko-2 paper: a 1500/200 player beats a 1400/30 and loses to a 1550/100
+// and a 1700/300 in one rating period.
+func TestRatePeriodMatchesPaper(t *testing.T) {
+	p := Rating{1500, 200, 0.06}
+	got := ratePeriod(p, []ratedGame{
+		{Rating{1400, 30, 0.06}, 1},
+		{Rating{1550, 100, 0.06}, 0},
+		{Rating{1700, 300, 0.06}, 0},
+	})
+	if math.Abs(got.Rating-1464.06) > 0.01 || math.Abs(got.Deviation-151.52) > 0.01 ||
+		math.Abs(got.Volatility-0.05999) > 0.00001 {
+		t.Fatalf("got %.2f/%.2f/%.5f, want 1464.06/151.52/0.05999", got.Rating, got.Deviation, got.Volatility)
+	}
+}
+
+func TestRecordIsSymmetric(t *testing.T) {
+	tour, err := NewTournament(&memStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tour.Close()
+	change := tour.RecordResult("Alice", "Bob", 11, 7)
+	if change[0] <= 0 || math.Abs(change[0]+change[1]) > 1e-9 {
+		t.Fatalf("change %v, want equal and opposite with Alice gaining", change)
+	}
+	alice, bob := tour.GetStats("Alice"), tour.GetStats("Bob")
+	if math.Abs(alice.Rating-DefaultRating-(DefaultRating-bob.Rating)) > 1e-9 ||
+		alice.RatingDeviation != bob.RatingDeviation || alice.Volatility != bob.Volatility {
+		t.Fatalf("Alice %+v and Bob %+v aren't mirror images", alice, bob)
+	}
+
+	// The same game recorded from the loser's side.
+	mirror, err := NewTournament(&memStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mirror.Close()
+	if got := mirror.RecordResult("Bob", "Alice", 7, 11); got != [2]float64{change[1], change[0]} {
+		t.Fatalf("from Bob's side %v, want %v reversed", got, change)
+	}
+}
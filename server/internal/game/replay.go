@@ -0,0 +1,583 @@
+package game
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vladimirvolkov/basketball/server/internal/ws"
+)
+
+// ReplayEventKind identifies a key moment recorded alongside the inputs.
+type ReplayEventKind uint8
+
+const (
+	EventShot ReplayEventKind = iota + 1
+	EventBlock
+	EventSteal
+	EventScore
+	EventShotClock
+	EventGameOver
+	EventForfeit // Player is the one who left
+)
+
+// ReplayEvent is a key moment in a match. Events are informational (seek
+// points, highlights); playback re-simulates them from the inputs.
+type ReplayEvent struct {
+	Tick   uint32          `json:"tick"`
+	Kind   ReplayEventKind `json:"kind"`
+	Player int8            `json:"player"`          // acting player, -1 if none
+	Value  uint8           `json:"value,omitempty"` // points for EventScore
+}
+
+// Replay is everything needed to re-simulate a match: the seed plus the
+// inputs consumed on every tick. Inputs[i] is what step() saw on tick i+1.
+type Replay struct {
+	ID         string           `json:"id"`
+	Seed       int64            `json:"seed"`
+	Names      [2]string        `json:"names"`
+	Tournament bool             `json:"isTournament,omitempty"`
+	Rules      Rules            `json:"rules"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	Inputs     [][2]PlayerInput `json:"-"`
+	Events     []ReplayEvent    `json:"events"`
+	Ticks      int              `json:"ticks"`
+}
+
+// replayRecorder collects inputs and events while a room plays.
+// Only touched from the room's tick, so it needs no lock.
+type replayRecorder struct {
+	inputs [][2]PlayerInput
+	events []ReplayEvent
+}
+
+func (rec *replayRecorder) recordInputs(inputs [2]PlayerInput) {
+	// Tick stamps and sequence numbers aren't part of the simulation; drop
+	// them so runs compress.
+	for i := range inputs {
+		inputs[i].Tick, inputs[i].Seq = 0, 0
+	}
+	rec.inputs = append(rec.inputs, inputs)
+}
+
+// EnableReplay makes the room record its inputs and save a replay to store
+// when the game ends. Must be called before Start.
+func (r *Room) EnableReplay(store *ReplayStore) {
+	r.recorder = &replayRecorder{inputs: make([][2]PlayerInput, 0, r.rules.ticks(float64(r.rules.GameDuration+CountdownSecs)))}
+	r.replays = store
+}
+
+func (r *Room) recordEvent(kind ReplayEventKind, player int, value uint8) {
+	r.urgent = true
+	if r.recorder == nil {
+		return
+	}
+	r.recorder.events = append(r.recorder.events, ReplayEvent{
+		Tick:   r.state.Tick,
+		Kind:   kind,
+		Player: int8(player),
+		Value:  value,
+	})
+}
+
+// saveReplay hands the finished recording to the store off the tick thread.
+func (r *Room) saveReplay() {
+	if r.recorder == nil || r.replays == nil {
+		return
+	}
+	rep := &Replay{
+		ID:         r.id,
+		Seed:       r.seed,
+		Names:      r.nicknames,
+		Tournament: r.tournament != nil,
+		Rules:      r.rules,
+		CreatedAt:  time.Now(),
+		Inputs:     r.recorder.inputs,
+		Events:     r.recorder.events,
+		Ticks:      len(r.recorder.inputs),
+	}
+	r.recorder = nil
+	store := r.replays
+	go func() {
+		if err := store.Save(rep); err != nil {
+			slog.Error("replay save failed", "replay", rep.ID, "err", err)
+		}
+	}()
+}
+
+// newRoomID returns a random 16-hex-char ID.
+func newRoomID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ── Playback ──
+
+// newReplayRoom builds a connectionless room that re-simulates rep.
+func newReplayRoom(rep *Replay) *Room {
+	r := newRoom(rep.Names, rep.Seed)
+	r.id = rep.ID
+	r.log = slog.With("replay", rep.ID, "nick0", rep.Names[0], "nick1", rep.Names[1])
+	r.SetRules(rep.Rules)
+	return r
+}
+
+// forfeitedBy returns the player who forfeited the match, if one did.
+func (rep *Replay) forfeitedBy() (int, bool) {
+	for _, e := range rep.Events {
+		if e.Kind == EventForfeit {
+			return int(e.Player), true
+		}
+	}
+	return 0, false
+}
+
+// Simulate re-runs the match tick by tick, calling fn with the state after
+// each tick. Returning false from fn stops the simulation early. A forfeit
+// ends the game after the last input, as it did live, and fn sees the state
+// once more.
+func (rep *Replay) Simulate(fn func(s *GameState) bool) {
+	r := newReplayRoom(rep)
+	for _, inputs := range rep.Inputs {
+		r.step(inputs)
+		if !fn(&r.state) {
+			return
+		}
+	}
+	if leaver, ok := rep.forfeitedBy(); ok {
+		r.endByForfeit(leaver)
+		fn(&r.state)
+	}
+}
+
+// StreamReplay plays rep back to conn in real time: a GameStart with both
+// names, then one MsgReplayFrame per tick at the recorded rate. The re-simulated room sends
+// MsgScored and MsgGameOver itself, so the normal client renderer and
+// overlays work unchanged. Returns when the viewer disconnects.
+func StreamReplay(ctx context.Context, conn PlayerEndpoint, rep *Replay) {
+	// Drain reads so close frames are processed and we notice the viewer leaving.
+	viewerGone := make(chan struct{})
+	msgs := conn.Messages()
+	go func() {
+		for range msgs {
+		}
+		close(viewerGone)
+	}()
+
+	start := ws.NewMessage(ws.MsgGameStart, 0, ws.GameStartPayload{
+		PlayerIndex:  0,
+		Names:        rep.Names,
+		IsTournament: rep.Tournament,
+		Seed:         rep.Seed,
+		Rules:        rep.Rules.Name,
+		TickRate:     uint8(rep.Rules.TickRate),
+	})
+	conn.Send(start)
+
+	r := newReplayRoom(rep)
+	r.conns = [2]PlayerEndpoint{conn} // scored/gameOver messages reach the viewer
+
+	ticker := time.NewTicker(time.Second / time.Duration(rep.Rules.TickRate))
+	defer ticker.Stop()
+	for _, inputs := range rep.Inputs {
+		select {
+		case <-viewerGone:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		r.step(inputs)
+		conn.Send(ws.NewMessage(ws.MsgReplayFrame, r.state.Tick, r.state))
+	}
+	if leaver, ok := rep.forfeitedBy(); ok {
+		r.endByForfeit(leaver)
+		conn.Send(ws.NewMessage(ws.MsgReplayFrame, r.state.Tick, r.state))
+	}
+
+	// Leave the final frame on screen until the viewer closes.
+	select {
+	case <-viewerGone:
+	case <-ctx.Done():
+	}
+}
+
+// ── Storage ──
+
+// replayIDRe guards file names derived from user-supplied IDs.
+var replayIDRe = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// ErrReplayNotFound is returned by ReplayStore.Load for unknown IDs.
+var ErrReplayNotFound = errors.New("replay not found")
+
+// ReplayRetention bounds what a ReplayStore keeps. Zero fields are
+// unlimited.
+type ReplayRetention struct {
+	MaxCount int           // newest replays kept
+	MaxAge   time.Duration // replays older than this are deleted
+}
+
+// ReplayStore keeps one compact binary file per replay in a directory and
+// deletes the oldest beyond its retention after every save.
+type ReplayStore struct {
+	dir     string
+	keep    ReplayRetention
+	pruneMu sync.Mutex // one prune at a time; saves run concurrently
+}
+
+// NewReplayStore opens dir, pruning it to keep right away.
+func NewReplayStore(dir string, keep ReplayRetention) (*ReplayStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create replay dir: %w", err)
+	}
+	rs := &ReplayStore{dir: dir, keep: keep}
+	if err := rs.prune(time.Now()); err != nil {
+		return nil, fmt.Errorf("prune replays: %w", err)
+	}
+	return rs, nil
+}
+
+func (rs *ReplayStore) path(id string) string {
+	return filepath.Join(rs.dir, id+".bbr")
+}
+
+// Save writes rep atomically (tmp + rename).
+func (rs *ReplayStore) Save(rep *Replay) error {
+	var buf bytes.Buffer
+	if err := rep.MarshalTo(&buf); err != nil {
+		return err
+	}
+	tmp := rs.path(rep.ID) + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, rs.path(rep.ID)); err != nil {
+		return err
+	}
+	return rs.prune(time.Now())
+}
+
+// prune deletes replays older than MaxAge and all but the newest MaxCount.
+func (rs *ReplayStore) prune(now time.Time) error {
+	if rs.keep == (ReplayRetention{}) {
+		return nil
+	}
+	rs.pruneMu.Lock()
+	defer rs.pruneMu.Unlock()
+
+	entries, err := os.ReadDir(rs.dir)
+	if err != nil {
+		return err
+	}
+	type file struct {
+		name string
+		mod  time.Time
+	}
+	var files []file
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), ".bbr") {
+			if info, err := e.Info(); err == nil {
+				files = append(files, file{e.Name(), info.ModTime()})
+			}
+		}
+	}
+	// Newest first.
+	sort.Slice(files, func(i, j int) bool { return files[i].mod.After(files[j].mod) })
+	removed := 0
+	for i, f := range files {
+		tooMany := rs.keep.MaxCount > 0 && i >= rs.keep.MaxCount
+		tooOld := rs.keep.MaxAge > 0 && now.Sub(f.mod) > rs.keep.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(filepath.Join(rs.dir, f.name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed++
+	}
+	if removed > 0 {
+		slog.Debug("replays pruned", "removed", removed, "kept", len(files)-removed)
+	}
+	return nil
+}
+
+// Load reads a replay by ID.
+func (rs *ReplayStore) Load(id string) (*Replay, error) {
+	if !replayIDRe.MatchString(id) {
+		return nil, ErrReplayNotFound
+	}
+	f, err := os.Open(rs.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrReplayNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rep, err := ReadReplay(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("replay %s: %w", id, err)
+	}
+	rep.ID = id
+	return rep, nil
+}
+
+// ── Binary format ──
+//
+//	"BBRP" version:u8 seed:varint createdAt:varint(unix ms) flags:u8
+//	name0 name1 (uvarint len + bytes)
+//	rules (uvarint len + JSON)                         version 2+; classic before
+//	runCount:uvarint { length:uvarint p0:u8 p1:u8 }   run-length encoded inputs
+//	eventCount:uvarint { dTick:uvarint kind:u8 player:u8 value:u8 }
+//
+// Each input packs into one byte: bits 0-1 moveX+1, bit 2 jump, bit 3 shoot,
+// and from version 3 bits 4-7 its lag-compensation rewind (see lagcomp.go).
+// Players hold the same input for many ticks, so a two-minute match is a few KB.
+//
+// The rules carry the rate the match was simulated at (tickRate), which
+// playback re-simulates at. Before version 4 rooms ran at 60 Hz and the rules
+// counted the steal cooldown in ticks (stealCooldownTicks).
+
+var replayMagic = [4]byte{'B', 'B', 'R', 'P'}
+
+const (
+	replayVersion        = 4
+	replayFlagTournament = 1 << 0
+)
+
+func packInput(in PlayerInput) byte {
+	b := byte(in.MoveX + 1)
+	if in.Jump {
+		b |= 1 << 2
+	}
+	if in.Shoot {
+		b |= 1 << 3
+	}
+	return b | in.rewind<<4
+}
+
+func unpackInput(b byte) PlayerInput {
+	return PlayerInput{
+		MoveX: int8(b&3) - 1,
+		Jump:  b&(1<<2) != 0,
+		Shoot: b&(1<<3) != 0,
+
+		rewind: b >> 4,
+	}
+}
+
+// MarshalTo writes rep in the compact binary replay format.
+func (rep *Replay) MarshalTo(w io.Writer) error {
+	var buf []byte
+	buf = append(buf, replayMagic[:]...)
+	buf = append(buf, replayVersion)
+	buf = binary.AppendVarint(buf, rep.Seed)
+	buf = binary.AppendVarint(buf, rep.CreatedAt.UnixMilli())
+	var flags byte
+	if rep.Tournament {
+		flags |= replayFlagTournament
+	}
+	buf = append(buf, flags)
+	for _, name := range rep.Names {
+		buf = binary.AppendUvarint(buf, uint64(len(name)))
+		buf = append(buf, name...)
+	}
+	rules, err := json.Marshal(rep.Rules)
+	if err != nil {
+		return err
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(rules)))
+	buf = append(buf, rules...)
+
+	// Collect runs of identical input pairs
+	type run struct {
+		n      uint64
+		p0, p1 byte
+	}
+	var runs []run
+	for _, in := range rep.Inputs {
+		p0, p1 := packInput(in[0]), packInput(in[1])
+		if k := len(runs) - 1; k >= 0 && runs[k].p0 == p0 && runs[k].p1 == p1 {
+			runs[k].n++
+			continue
+		}
+		runs = append(runs, run{n: 1, p0: p0, p1: p1})
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(runs)))
+	for _, r := range runs {
+		buf = binary.AppendUvarint(buf, r.n)
+		buf = append(buf, r.p0, r.p1)
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(rep.Events)))
+	var prev uint32
+	for _, e := range rep.Events {
+		buf = binary.AppendUvarint(buf, uint64(e.Tick-prev))
+		buf = append(buf, byte(e.Kind), byte(e.Player), e.Value)
+		prev = e.Tick
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// maxReplayTicks bounds decoding so a corrupt file can't allocate unbounded memory.
+const maxReplayTicks = 60 * 60 * MaxTickRate
+
+// replayReader keeps the first read error so decoding reads straight through
+// and checks once per section.
+type replayReader struct {
+	r   io.ByteReader
+	err error
+}
+
+func (rr *replayReader) byte() byte {
+	if rr.err != nil {
+		return 0
+	}
+	b, err := rr.r.ReadByte()
+	rr.err = err
+	return b
+}
+
+func (rr *replayReader) uvarint() uint64 {
+	if rr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(rr.r)
+	rr.err = err
+	return v
+}
+
+func (rr *replayReader) varint() int64 {
+	if rr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(rr.r)
+	rr.err = err
+	return v
+}
+
+// upgradeRules fills in rules recorded before version 4: a 60 Hz room with
+// the steal cooldown in ticks.
+func upgradeRules(data []byte, r *Rules) error {
+	var old struct {
+		StealCooldownTicks *uint8 `json:"stealCooldownTicks"`
+	}
+	if err := json.Unmarshal(data, &old); err != nil {
+		return err
+	}
+	r.TickRate = DefaultTickRate
+	if old.StealCooldownTicks != nil {
+		r.StealCooldownSecs = float64(*old.StealCooldownTicks) / DefaultTickRate
+	}
+	return nil
+}
+
+// ReadReplay decodes a replay written by MarshalTo. The ID is not stored in
+// the file; callers set it from the file name.
+func ReadReplay(r io.ByteReader) (*Replay, error) {
+	rr := &replayReader{r: r}
+	rep := &Replay{}
+
+	var magic [4]byte
+	for i := range magic {
+		magic[i] = rr.byte()
+	}
+	if rr.err == nil && magic != replayMagic {
+		return nil, errors.New("not a replay file")
+	}
+	version := rr.byte()
+	if rr.err == nil && (version < 1 || version > replayVersion) {
+		return nil, fmt.Errorf("unsupported replay version %d", version)
+	}
+	rep.Seed = rr.varint()
+	rep.CreatedAt = time.UnixMilli(rr.varint())
+	rep.Tournament = rr.byte()&replayFlagTournament != 0
+	for i := range rep.Names {
+		n := rr.uvarint()
+		if n > 64 {
+			return nil, errors.New("name too long")
+		}
+		name := make([]byte, n)
+		for j := range name {
+			name[j] = rr.byte()
+		}
+		rep.Names[i] = string(name)
+	}
+	rep.Rules = ClassicRules()
+	if version >= 2 {
+		n := rr.uvarint()
+		if n > 4096 {
+			return nil, errors.New("rules too long")
+		}
+		rules := make([]byte, n)
+		for i := range rules {
+			rules[i] = rr.byte()
+		}
+		if rr.err == nil {
+			if err := json.Unmarshal(rules, &rep.Rules); err != nil {
+				return nil, fmt.Errorf("rules: %w", err)
+			}
+			if version < 4 {
+				if err := upgradeRules(rules, &rep.Rules); err != nil {
+					return nil, fmt.Errorf("rules: %w", err)
+				}
+			}
+		}
+	}
+	if rep.Rules.TickRate < MinTickRate || rep.Rules.TickRate > MaxTickRate {
+		return nil, fmt.Errorf("unsupported tick rate %d", rep.Rules.TickRate)
+	}
+	if rr.err != nil {
+		return nil, rr.err
+	}
+
+	for runs := rr.uvarint(); runs > 0 && rr.err == nil; runs-- {
+		n := rr.uvarint()
+		pair := [2]PlayerInput{unpackInput(rr.byte()), unpackInput(rr.byte())}
+		if uint64(len(rep.Inputs))+n > maxReplayTicks {
+			return nil, errors.New("replay too long")
+		}
+		for ; n > 0; n-- {
+			rep.Inputs = append(rep.Inputs, pair)
+		}
+	}
+	if rr.err != nil {
+		return nil, rr.err
+	}
+	rep.Ticks = len(rep.Inputs)
+
+	var tick uint32
+	for events := rr.uvarint(); events > 0 && rr.err == nil; events-- {
+		if len(rep.Events) >= maxReplayTicks {
+			return nil, errors.New("too many events")
+		}
+		tick += uint32(rr.uvarint())
+		rep.Events = append(rep.Events, ReplayEvent{
+			Tick:   tick,
+			Kind:   ReplayEventKind(rr.byte()),
+			Player: int8(rr.byte()),
+			Value:  rr.byte(),
+		})
+	}
+	if rr.err != nil {
+		return nil, rr.err
+	}
+	return rep, nil
+}
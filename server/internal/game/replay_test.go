@@ -0,0 +1,191 @@
+package game
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// recordingBotRoom returns a bot-vs-bot room with recording on.
+func recordingBotRoom(seed int64, rules Rules) *Room {
+	bots := [2]*Bot{NewBot(0, BotNormal, seed), NewBot(1, BotHard, seed)}
+	r := newRoom([2]string{bots[0].Name(), bots[1].Name()}, seed)
+	r.SetRules(rules)
+	r.bots = bots
+	r.timeouts = RoomTimeouts{}
+	r.EnableReplay(nil) // keep the recording here rather than saving it
+	return r
+}
+
+// recorded returns what r has recorded so far as a replay.
+func recorded(r *Room) *Replay {
+	return &Replay{
+		ID:        newRoomID(),
+		Seed:      r.seed,
+		Names:     r.nicknames,
+		Rules:     r.rules,
+		CreatedAt: time.Now(),
+		Inputs:    r.recorder.inputs,
+		Events:    r.recorder.events,
+		Ticks:     len(r.recorder.inputs),
+	}
+}
+
+// liveState is r's state without the network bookkeeping a replay leaves out.
+func liveState(r *Room) GameState {
+	s := r.state
+	s.InputSeq = [2]uint32{}
+	return s
+}
+
+// recordBotGame plays a full bot-vs-bot game with recording on and returns
+// the replay and the state after every tick.
+func recordBotGame(t *testing.T, seed int64, rules Rules) (*Replay, []GameState) {
+	t.Helper()
+	r := recordingBotRoom(seed, rules)
+	var states []GameState
+	for r.state.Phase != PhaseGameOver {
+		r.tick()
+		states = append(states, liveState(r))
+	}
+	return recorded(r), states
+}
+
+// saveAndLoad puts rep through the on-disk format, as /replays serves it.
+func saveAndLoad(t *testing.T, rep *Replay) *Replay {
+	t.Helper()
+	store, err := NewReplayStore(t.TempDir(), ReplayRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(rep); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.Load(rep.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded
+}
+
+func TestReplayReproducesLiveGame(t *testing.T) {
+	for _, hz := range []int{DefaultTickRate, 120} {
+		rules, _ := DefaultRuleBook().Preset("quick")
+		rules.TickRate = hz
+		rep, live := recordBotGame(t, 5, rules)
+		if live[len(live)-1].Score == [2]uint8{} {
+			t.Fatalf("%d Hz: nobody scored; the game exercises too little to be a useful check", hz)
+		}
+
+		loaded := saveAndLoad(t, rep)
+		if loaded.Rules.TickRate != hz {
+			t.Fatalf("replay recorded at %d Hz loads at %d Hz", hz, loaded.Rules.TickRate)
+		}
+		i := 0
+		loaded.Simulate(func(s *GameState) bool {
+			if i >= len(live) {
+				t.Fatalf("%d Hz: replay runs past the live game's %d ticks", hz, len(live))
+			}
+			if *s != live[i] {
+				t.Fatalf("%d Hz: tick %d differs\nlive   %+v\nreplay %+v", hz, s.Tick, live[i], *s)
+			}
+			i++
+			return true
+		})
+		if i != len(live) {
+			t.Fatalf("%d Hz: replay ran %d ticks, live game %d", hz, i, len(live))
+		}
+	}
+}
+
+func TestReadReplayUpgradesVersion3(t *testing.T) {
+	// A version 3 header: rules without a tick rate and with the steal
+	// cooldown in ticks, then no inputs and no events.
+	buf := append(replayMagic[:], 3)
+	buf = binary.AppendVarint(buf, 1)
+	buf = binary.AppendVarint(buf, 0)
+	buf = append(buf, 0)
+	for _, name := range []string{"A", "B"} {
+		buf = binary.AppendUvarint(buf, uint64(len(name)))
+		buf = append(buf, name...)
+	}
+	rules := `{"name":"classic","stealCooldownTicks":15}`
+	buf = binary.AppendUvarint(buf, uint64(len(rules)))
+	buf = append(buf, rules...)
+	buf = append(buf, 0, 0)
+
+	rep, err := ReadReplay(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Rules.TickRate != DefaultTickRate || rep.Rules.StealCooldownSecs != 0.25 {
+		t.Fatalf("read %d Hz with a %vs steal cooldown, want 60 Hz and 0.25s", rep.Rules.TickRate, rep.Rules.StealCooldownSecs)
+	}
+}
+
+func TestReplayReproducesForfeit(t *testing.T) {
+	rules, _ := DefaultRuleBook().Preset("quick")
+	r := recordingBotRoom(5, rules)
+	for r.state.Score == [2]uint8{} {
+		r.tick()
+	}
+	// The leader drops and doesn't come back: a forfeit the inputs alone
+	// would never reach.
+	leaver := 0
+	if r.state.Score[1] > r.state.Score[0] {
+		leaver = 1
+	}
+	r.suspend(leaver)
+	r.tick()
+	if r.state.Phase != PhaseGameOver || r.state.Winner != int8(1-leaver) {
+		t.Fatalf("phase %v winner %d, want player %d to forfeit", r.state.Phase, r.state.Winner, leaver)
+	}
+	live := liveState(r)
+
+	var final GameState
+	saveAndLoad(t, recorded(r)).Simulate(func(s *GameState) bool {
+		final = *s
+		return true
+	})
+	if final != live {
+		t.Fatalf("replay ends differently\nlive   %+v\nreplay %+v", live, final)
+	}
+}
+
+func TestReplayRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	// Five replays a day apart, the oldest first.
+	for i := range 5 {
+		path := filepath.Join(dir, newRoomID()+".bbr")
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mod := now.Add(-time.Duration(4-i) * 24 * time.Hour)
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func() int {
+		m, _ := filepath.Glob(filepath.Join(dir, "*.bbr"))
+		return len(m)
+	}
+
+	rs, err := NewReplayStore(dir, ReplayRetention{MaxAge: 60 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 3 {
+		t.Fatalf("MaxAge kept %d replays, want 3", n)
+	}
+	rs.keep = ReplayRetention{MaxCount: 2}
+	if err := rs.prune(now); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("MaxCount kept %d replays, want 2", n)
+	}
+}
@@ -0,0 +1,217 @@
+package game
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+
+	"github.com/vladimirvolkov/basketball/server/internal/ws"
+)
+
+// Reconnect-and-resume.
+//
+// Each player gets a resume token in GameStart. If the connection drops
+// mid-game the room pauses (no simulation, no input consumed) for
+// timeouts.Reconnect; a new connection presenting the token via
+// /ws?resume=<token> takes the same seat and play continues. If the window
+// runs out the absent player forfeits. If the other player drops too, nobody
+// is left to wait for: the one who dropped first forfeits on the next tick
+// and the room ends.
+
+// ErrResumeInvalid is returned for unknown tokens and for rooms that are
+// already over.
+var ErrResumeInvalid = errors.New("invalid or expired resume token")
+
+func newResumeToken() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// noteOrigins records how each seated player came to the room.
+func (r *Room) noteOrigins() {
+	for i, c := range r.conns {
+		if b, ok := c.(seatBinder); ok {
+			r.origins[i] = b.origin()
+		}
+	}
+}
+
+// seatFor returns the player index token belongs to, or -1.
+func (r *Room) seatFor(token string) int {
+	for i, t := range r.tokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *Room) isAway(playerIdx int) bool {
+	return r.away.Load()&(1<<playerIdx) != 0
+}
+
+// suspend starts the grace window for a dropped player; with resuming
+// disabled the window is zero and the next tick forfeits. If the opponent is
+// already away, the next tick settles the game against whoever left first.
+// Returns false when the game is already over and the room should just end.
+func (r *Room) suspend(playerIdx int) bool {
+	if r.over.Load() {
+		return false
+	}
+	r.endMu.Lock()
+	defer r.endMu.Unlock()
+	if r.ended {
+		return false
+	}
+	other := 1 - playerIdx
+	if r.isAway(other) {
+		r.away.Or(1 << playerIdx)
+		r.log.Info("both players dropped", "first", other)
+		return true
+	}
+	r.away.Or(1 << playerIdx)
+	r.firstAway = playerIdx
+	if r.timeouts.Reconnect <= 0 {
+		return true
+	}
+	r.log.Info("player dropped, holding seat", "player", playerIdx, "grace", r.timeouts.Reconnect)
+	r.sendTo(other, ws.NewMessage(ws.MsgMatchPaused, 0, ws.MatchPausedPayload{
+		PlayerIndex: uint8(playerIdx),
+		Grace:       float32(r.timeouts.Reconnect.Seconds()),
+	}))
+	return true
+}
+
+// Resume rebinds conn to the seat token was issued for. It also takes over a
+// seat whose old connection hasn't been noticed as dead yet.
+func (r *Room) Resume(conn PlayerEndpoint, token string) error {
+	idx := r.seatFor(token)
+	if idx < 0 {
+		return ErrResumeInvalid
+	}
+	r.endMu.Lock()
+	defer r.endMu.Unlock()
+	if r.ended || r.over.Load() {
+		return ErrResumeInvalid
+	}
+
+	if b, ok := conn.(seatBinder); ok {
+		b.bindSeat(r.nicknames[idx], r.origins[idx])
+	}
+	r.connMu.Lock()
+	old := r.conns[idx]
+	r.conns[idx] = conn
+	r.connMu.Unlock()
+	if old != conn {
+		go old.Close() // its read loop sees it no longer owns the seat
+	}
+	// The reloaded client numbers its inputs from 1 again and starts from
+	// standing still.
+	r.inputMu.Lock()
+	r.inputs[idx] = inputQueue{}
+	r.inputMu.Unlock()
+
+	if s, ok := conn.(StreamEndpoint); ok {
+		s.ResetStream()
+	}
+	conn.Send(r.gameStart(idx))
+	r.readers.Add(1) // under endMu, before the room can end and wait on readers
+	go r.readLoop(r.ctx, conn, idx)
+
+	if r.isAway(idx) {
+		r.away.And(^uint32(1 << idx))
+		if r.isAway(1 - idx) {
+			r.firstAway = 1 - idx
+		}
+		r.sendTo(1-idx, ws.NewMessage(ws.MsgMatchResumed, 0, ws.MatchResumedPayload{PlayerIndex: uint8(idx)}))
+	}
+	r.log.Info("player resumed", "player", idx, "conn", conn.ID())
+	return nil
+}
+
+// tickPaused runs instead of the simulation while a player is away.
+func (r *Room) tickPaused() {
+	r.resendState() // a resumed client asks for a keyframe
+	r.pauseTicks++
+	const bothAway = 1<<0 | 1<<1
+	waiting := r.pauseTicks < r.rules.ticks(r.timeouts.Reconnect.Seconds())
+	if waiting && r.away.Load() != bothAway {
+		return
+	}
+
+	r.endMu.Lock()
+	away := r.away.Load()
+	if r.ended || away == 0 || waiting && away != bothAway {
+		r.endMu.Unlock()
+		return
+	}
+	r.over.Store(true) // no resuming from here on
+	leaver := r.firstAway
+	r.endMu.Unlock()
+
+	r.forfeit(leaver)
+	if away == bothAway {
+		// Nobody is left to look at the result.
+		r.endMu.Lock()
+		if !r.ended {
+			r.endLocked([2]PlayerEnd{EndLeft, EndLeft})
+		}
+		r.endMu.Unlock()
+	}
+}
+
+// forfeit ends the game in favour of the player who stayed.
+func (r *Room) forfeit(leaver int) {
+	s := &r.state
+	winner := 1 - leaver
+	r.log.Info("player did not return, forfeit", "player", leaver)
+	r.endByForfeit(leaver)
+	r.saveReplay()
+
+	if r.tournament != nil {
+		change := r.tournament.RecordForfeit(r.nicknames[winner], r.nicknames[leaver], s.Score[winner], s.Score[leaver])
+		var byPlayer RatingChange
+		byPlayer[winner], byPlayer[leaver] = change[0], change[1]
+		r.sendTournamentResults(byPlayer)
+	}
+	r.recordForm()
+
+	r.publishSummary()
+	r.broadcastState()
+}
+
+// endByForfeit is the part of a forfeit that changes the game: it happens
+// outside step(), so replays record it as EventForfeit and play it back
+// through here.
+func (r *Room) endByForfeit(leaver int) {
+	s := &r.state
+	s.Phase = PhaseGameOver
+	s.PhaseTimer = 0
+	s.Winner = int8(1 - leaver)
+	r.broadcast(ws.NewMessage(ws.MsgGameOver, s.Tick, ws.GameOverPayload{
+		Winner:  s.Winner,
+		Score:   s.Score,
+		Forfeit: true,
+		Reason:  ws.GameOverForfeit,
+	}))
+	r.recordEvent(EventForfeit, leaver, 0)
+}
+
+// Resume rebinds conn to a dropped player's seat in whichever live room
+// issued token.
+func (e *Engine) Resume(conn PlayerEndpoint, token string) error {
+	var room *Room
+	e.rooms.Range(func(_, v any) bool {
+		if r := v.(*Room); r.seatFor(token) >= 0 {
+			room = r
+			return false
+		}
+		return true
+	})
+	if room == nil {
+		return ErrResumeInvalid
+	}
+	return room.Resume(conn, token)
+}
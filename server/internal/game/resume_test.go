@@ -0,0 +1,209 @@
+package game
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vladimirvolkov/basketball/server/internal/ws"
+)
+
+// waitFor polls cond until it holds, for things a room's read loops do in
+// the background.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// startTournamentGame starts a rated room between two in-memory players and
+// ticks it into play. The caller drives it with r.tick().
+func startTournamentGame(t *testing.T) (*Room, *Tournament, [2]*MemEndpoint) {
+	t.Helper()
+	store, err := OpenFileStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tour, err := NewTournament(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tour.Close() })
+	eps := [2]*MemEndpoint{NewMemEndpoint("a", "Alice", 1024), NewMemEndpoint("b", "Bob", 1024)}
+	r := NewTournamentRoom(eps[0], eps[1], tour)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	r.Start(ctx)
+	for !r.state.Phase.Live() {
+		r.tick()
+	}
+	return r, tour, eps
+}
+
+func TestBothDroppedFirstForfeits(t *testing.T) {
+	r, tour, eps := startTournamentGame(t)
+
+	eps[1].Close() // Bob drops and is still in his grace window...
+	waitFor(t, "Bob's seat held", func() bool { return r.isAway(1) })
+	r.tick()
+	eps[0].Close() // ...when Alice drops too
+	waitFor(t, "Alice's seat held", func() bool { return r.isAway(0) })
+	r.tick()
+
+	if r.state.Phase != PhaseGameOver || r.state.Winner != 0 {
+		t.Fatalf("phase %v winner %d, want Bob to forfeit to Alice", r.state.Phase, r.state.Winner)
+	}
+	if ends := r.Ends(); ends != [2]PlayerEnd{EndLeft, EndLeft} {
+		t.Fatalf("ends %v, want both left", ends)
+	}
+	select {
+	case <-r.ctx.Done():
+	default:
+		t.Fatal("room still running with both players gone")
+	}
+	if bob := tour.GetStats("Bob"); bob.Forfeits != 1 || bob.Losses != 1 {
+		t.Fatalf("Bob %+v, want one forfeit loss", bob)
+	}
+	if alice := tour.GetStats("Alice"); alice.Wins != 1 {
+		t.Fatalf("Alice %+v, want the win", alice)
+	}
+}
+
+func TestResumeHandsForfeitToWhoeverIsStillAway(t *testing.T) {
+	r, tour, eps := startTournamentGame(t)
+
+	eps[1].Close()
+	waitFor(t, "Bob's seat held", func() bool { return r.isAway(1) })
+	eps[0].Close()
+	waitFor(t, "Alice's seat held", func() bool { return r.isAway(0) })
+	// Bob makes it back before the room notices both are gone.
+	bob := NewMemEndpoint("b2", "Bob", 1024)
+	if err := r.Resume(bob, r.tokens[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	r.tick()
+	if r.over.Load() {
+		t.Fatal("game ended before Alice's grace window ran out")
+	}
+	for !r.over.Load() {
+		r.tick()
+	}
+	if r.state.Winner != 1 {
+		t.Fatalf("winner %d, want Alice, still away, to forfeit to Bob", r.state.Winner)
+	}
+	if alice := tour.GetStats("Alice"); alice.Forfeits != 1 {
+		t.Fatalf("Alice %+v, want one forfeit", alice)
+	}
+}
+
+func TestResumeTakesFreshInputs(t *testing.T) {
+	r, _, eps := startTournamentGame(t)
+	queued := func() bool {
+		r.inputMu.Lock()
+		defer r.inputMu.Unlock()
+		return r.inputs[1].n > 0
+	}
+
+	eps[1].SendInput(PlayerInput{Seq: 40, MoveX: 1})
+	waitFor(t, "Bob's input", queued)
+	r.tick()
+	eps[1].Close()
+	waitFor(t, "Bob's seat held", func() bool { return r.isAway(1) })
+
+	bob := NewMemEndpoint("b2", "Bob", 1024)
+	if err := r.Resume(bob, r.tokens[1]); err != nil {
+		t.Fatal(err)
+	}
+	x := r.state.Players[1].X
+	r.tick()
+	if r.state.Players[1].X != x {
+		t.Fatal("Bob kept running on his old connection's input")
+	}
+	// The reloaded page counts its inputs from 1 again.
+	bob.SendInput(PlayerInput{Seq: 1, MoveX: -1})
+	waitFor(t, "the resumed input", queued)
+	r.tick()
+	if r.state.InputSeq[1] != 1 || r.state.Players[1].VX >= 0 {
+		t.Fatalf("seq %d vx %v, want the resumed input applied", r.state.InputSeq[1], r.state.Players[1].VX)
+	}
+}
+
+// originEndpoint is a MemEndpoint that, like a websocket, outlives the room
+// and carries the matchmaking it came from.
+type originEndpoint struct {
+	*MemEndpoint
+	o seatOrigin
+}
+
+func (e *originEndpoint) origin() seatOrigin { return e.o }
+func (e *originEndpoint) bindSeat(nickname string, o seatOrigin) {
+	e.name, e.o = nickname, o
+}
+
+func TestResumeRestoresSeatOrigin(t *testing.T) {
+	host := &originEndpoint{NewMemEndpoint("a", "Alice", 1024), seatOrigin{mode: "private", rules: "quick"}}
+	guest := &originEndpoint{NewMemEndpoint("b", "Bob", 1024), seatOrigin{mode: "private"}}
+	r := NewSeededRoom(host, guest, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx)
+
+	host.Close()
+	waitFor(t, "Alice's seat held", func() bool { return r.isAway(0) })
+	back := &originEndpoint{MemEndpoint: NewMemEndpoint("a2", "", 1024)} // a fresh connection knows nothing
+	if err := r.Resume(back, r.tokens[0]); err != nil {
+		t.Fatal(err)
+	}
+	if back.name != "Alice" || back.o != host.o {
+		t.Fatalf("resumed as %q %+v, want Alice %+v", back.name, back.o, host.o)
+	}
+
+	bot := &originEndpoint{NewMemEndpoint("c", "Carol", 1024), seatOrigin{mode: "bot", botLevel: "hard"}}
+	br := NewBotRoom(bot, BotHard)
+	if br.origins[0] != bot.o {
+		t.Fatalf("bot room noted %+v, want %+v", br.origins[0], bot.o)
+	}
+}
+
+func TestWSEndpointTakesSeatOrigin(t *testing.T) {
+	c := ws.NewConn(nil, "c1", "127.0.0.1", nil)
+	c.Mode, c.BotLevel, c.Rules = "bot", "easy", "quick"
+	o := NewWSEndpoint(c).origin()
+
+	resumed := ws.NewConn(nil, "c2", "127.0.0.1", nil)
+	resumed.Mode = "" // a reconnect that didn't say where it came from
+	NewWSEndpoint(resumed).bindSeat("Alice", o)
+	if resumed.Nickname != "Alice" || resumed.Mode != "bot" || resumed.BotLevel != "easy" || resumed.Rules != "quick" {
+		t.Fatalf("resumed conn has nick %q mode %q level %q rules %q", resumed.Nickname, resumed.Mode, resumed.BotLevel, resumed.Rules)
+	}
+}
+
+func TestReleasedWaitsForReadLoops(t *testing.T) {
+	r, _, _ := startTournamentGame(t)
+	bob := NewMemEndpoint("b2", "Bob", 1024)
+	if err := r.Resume(bob, r.tokens[1]); err != nil {
+		t.Fatal(err)
+	}
+	r.readers.Add(1) // a read loop still handling its last message
+	r.endMu.Lock()
+	r.endLocked([2]PlayerEnd{EndRematch, EndRematch})
+	r.endMu.Unlock()
+
+	select {
+	case <-r.Released():
+		t.Fatal("released while a read loop was still running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	r.readers.Done()
+	select {
+	case <-r.Released():
+	case <-time.After(2 * time.Second):
+		t.Fatal("not released after the read loops exited")
+	}
+}
@@ -2,32 +2,103 @@ package game
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/vladimirvolkov/basketball/server/internal/logging"
 	"github.com/vladimirvolkov/basketball/server/internal/ws"
 )
 
 type Room struct {
-	conns      [2]*ws.Conn
+	connMu     sync.Mutex // guards conns: a resume swaps a seat's endpoint
+	conns      [2]PlayerEndpoint
+	bots       [2]*Bot       // AI players; a bot's seat has no endpoint
+	tokens     [2]string     // resume tokens, empty for replay rooms
+	origins    [2]seatOrigin // how each seat's player got here, for resuming connections
+	ctx        context.Context
 	nicknames  [2]string
+	rules      Rules // copied at creation; see SetRules
 	state      GameState
-	inputs     [2]PlayerInput
+	inputs     [2]inputQueue
 	inputMu    sync.Mutex
 	cancel     context.CancelFunc
 	done       chan struct{}
-	tournament *Tournament  // nil for regular games
-	finished   atomic.Bool  // set when room should be removed from engine
+	readers    sync.WaitGroup // running readLoops
+	released   chan struct{}  // closed once the room has stopped and its readLoops have exited
+	tournament *Tournament    // nil for regular games
+	form       *RecentForm    // casual results go here; nil unless SetRecentForm
+	finished   atomic.Bool    // set when room should be removed from engine
+	seed       int64
+	rng        *rand.Rand // room-owned RNG — only touched from tick(), never shared
+	id         string
+	log        *slog.Logger    // carries the room ID and both players
+	deflectLog logging.Sampler // a ball stuck against a player deflects every tick
+	recorder   *replayRecorder // nil unless EnableReplay was called
+	replays    *ReplayStore
+	spectators spectators
+	summaryMu  sync.Mutex
+	summary    roomSummary  // listing fields, copied out each tick
+	history    stateHistory // recent states, used as delta bases
+	snapRate   int          // most states per second sent, 0 every tick (see snaprate.go)
+	urgent     bool         // this tick recorded an event; send its state to everyone, only touched from tick()
+
+	timeouts  RoomTimeouts
+	over      atomic.Bool // game over — Play Again now means rematch
+	overTicks int         // ticks spent in PhaseGameOver, only touched from tick()
+	inputSeen atomic.Bool // a player sent input since the last tick
+	idleTicks int         // ticks without input, only touched from tick()
+	endMu     sync.Mutex
+	ended     bool
+	rematch   [2]bool // player pressed Play Again
+	ends      [2]PlayerEnd
+
+	// Set by the worker holding the room (see balance.go, overload.go).
+	tickCost int64 // moving average of tick time in ns
+	lastTick int64 // ns
+	tickDue  int   // gains TickRate per engine tick, a room tick costs the engine rate
+	halfRate bool  // send state at most every other tick
+
+	away       atomic.Uint32 // bit per player who dropped and may still resume
+	firstAway  int           // of the players away, the one who dropped first; guarded by endMu
+	pauseTicks int           // ticks paused waiting for a resume, only touched from tick()
 }
 
-func NewRoom(p1, p2 *ws.Conn) *Room {
+// NewSeed returns a random room seed. Seeds stay below 2^53 so they
+// survive a round-trip through JSON numbers in the browser.
+func NewSeed() int64 {
+	return rand.Int63n(1 << 53)
+}
+
+func NewRoom(p1, p2 PlayerEndpoint) *Room {
+	return NewSeededRoom(p1, p2, NewSeed())
+}
+
+// NewSeededRoom creates a room whose random events (shot misses, steals) are
+// drawn from a RNG seeded with seed. The same seed and the same input stream
+// always produce the same GameState sequence.
+func NewSeededRoom(p1, p2 PlayerEndpoint, seed int64) *Room {
+	r := newRoom([2]string{p1.Name(), p2.Name()}, seed)
+	r.conns = [2]PlayerEndpoint{p1, p2}
+	r.tokens = [2]string{newResumeToken(), newResumeToken()}
+	r.noteOrigins()
+	return r
+}
+
+// newRoom creates a room without endpoints (used directly by replay playback).
+func newRoom(nicknames [2]string, seed int64) *Room {
 	r := &Room{
-		conns:     [2]*ws.Conn{p1, p2},
-		nicknames: [2]string{p1.Nickname, p2.Nickname},
+		nicknames: nicknames,
+		seed:      seed,
+		rng:       rand.New(rand.NewSource(seed)),
+		id:        newRoomID(),
+		rules:     ClassicRules(),
+		timeouts:  DefaultRoomTimeouts,
 	}
+	r.log = slog.With("room", r.id, "nick0", nicknames[0], "nick1", nicknames[1])
 	r.state = GameState{
 		Phase:      PhaseCountdown,
 		PhaseTimer: CountdownSecs,
@@ -36,14 +107,27 @@ func NewRoom(p1, p2 *ws.Conn) *Room {
 			NewPlayer(720, FloorY-PlayerHeight/2, -1),
 		},
 		Ball:      NewBall(),
-		ShotClock: ShotClockSecs,
-		GameClock: GameDuration,
+		ShotClock: r.rules.ShotClockSecs,
+		GameClock: r.rules.GameDuration,
 		Winner:    -1,
 	}
+	r.publishSummary()
 	return r
 }
 
-func NewTournamentRoom(p1, p2 *ws.Conn, t *Tournament) *Room {
+// NewBotRoom seats p against a server-side bot.
+func NewBotRoom(p PlayerEndpoint, level BotDifficulty) *Room {
+	seed := NewSeed()
+	bot := NewBot(1, level, seed)
+	r := newRoom([2]string{p.Name(), bot.Name()}, seed)
+	r.conns = [2]PlayerEndpoint{p, nil}
+	r.tokens = [2]string{newResumeToken(), ""}
+	r.bots[1] = bot
+	r.noteOrigins()
+	return r
+}
+
+func NewTournamentRoom(p1, p2 PlayerEndpoint, t *Tournament) *Room {
 	r := NewRoom(p1, p2)
 	r.tournament = t
 	return r
@@ -53,20 +137,27 @@ func NewTournamentRoom(p1, p2 *ws.Conn, t *Tournament) *Room {
 // The game loop is driven externally by Engine via TickExternal().
 func (r *Room) Start(ctx context.Context) {
 	ctx, r.cancel = context.WithCancel(ctx)
+	r.ctx = ctx
 	r.done = make(chan struct{})
-
-	// Send GameStart to both players (includes both nicknames)
+	r.released = make(chan struct{})
 	for i, c := range r.conns {
-		msg, _ := ws.NewMessage(ws.MsgGameStart, 0, ws.GameStartPayload{
-			PlayerIndex:  uint8(i),
-			Names:        r.nicknames,
-			IsTournament: r.tournament != nil,
-		})
-		c.Send(msg)
+		if c != nil {
+			r.log = r.log.With(fmt.Sprintf("conn%d", i), c.ID())
+		}
 	}
+	r.log.Info("room started", "rules", r.rules.Name, "tournament", r.tournament != nil)
 
-	// Start read loops
+	// Send GameStart to both players (includes both nicknames) and start
+	// read loops. A bot's seat has no endpoint.
 	for i, c := range r.conns {
+		if c == nil {
+			continue
+		}
+		if s, ok := c.(StreamEndpoint); ok {
+			s.ResetStream() // a rematch reuses the connection; old acks are meaningless
+		}
+		c.Send(r.gameStart(i))
+		r.readers.Add(1)
 		go r.readLoop(ctx, c, i)
 	}
 
@@ -74,32 +165,69 @@ func (r *Room) Start(ctx context.Context) {
 	go func() {
 		<-ctx.Done()
 		r.finished.Store(true)
+		r.readers.Wait()
+		close(r.released)
 	}()
 }
 
-// TickExternal is called by the Engine at 60 Hz.
+// TickExternal is called by the Engine on every worker tick, engineRate
+// times a second, and steps the room as often as its own TickRate calls for.
 // Returns true if the room is still active, false when it should be removed.
-func (r *Room) TickExternal() bool {
+func (r *Room) TickExternal(engineRate int) bool {
 	if r.finished.Load() {
 		return false
 	}
-	r.tick()
+	for r.tickDue += r.rules.TickRate; r.tickDue >= engineRate; r.tickDue -= engineRate {
+		r.tick()
+	}
 	return true
 }
 
+// ID returns the room's unique ID. Replays are saved under the same ID.
+func (r *Room) ID() string {
+	return r.id
+}
+
+// Seed returns the seed of the room's RNG.
+func (r *Room) Seed() int64 {
+	return r.seed
+}
+
 // Done returns a channel that closes when the room is removed from the engine.
 func (r *Room) Done() <-chan struct{} {
 	return r.done
 }
 
-func (r *Room) readLoop(ctx context.Context, conn *ws.Conn, playerIdx int) {
-	msgs := conn.ReadLoop(ctx)
+// Released returns a channel that closes once the room has stopped and its
+// read loops have exited, so nothing in the room still reads the players'
+// connections. Hand the connections on only after it closes.
+func (r *Room) Released() <-chan struct{} {
+	return r.released
+}
+
+// gameStart builds the GameStart message for player i.
+func (r *Room) gameStart(i int) ws.Message {
+	return ws.NewMessage(ws.MsgGameStart, 0, ws.GameStartPayload{
+		PlayerIndex:  uint8(i),
+		Names:        r.nicknames,
+		IsTournament: r.tournament != nil,
+		Seed:         r.seed,
+		ResumeToken:  r.tokens[i],
+		Rules:        r.rules.Name,
+		SnapshotRate: r.snapshotRate(r.Players()[i]),
+		TickRate:     uint8(r.rules.TickRate),
+	})
+}
+
+func (r *Room) readLoop(ctx context.Context, conn PlayerEndpoint, playerIdx int) {
+	defer r.readers.Done()
+	msgs := conn.Messages()
 	for {
 		select {
 		case msg, ok := <-msgs:
 			if !ok {
-				log.Printf("player %d disconnected", playerIdx)
-				r.handleDisconnect(playerIdx)
+				r.log.Info("player disconnected", "player", playerIdx)
+				r.handleDisconnect(conn, playerIdx)
 				return
 			}
 			r.handleMessage(playerIdx, msg)
@@ -113,7 +241,7 @@ func (r *Room) handleMessage(playerIdx int, msg ws.Message) {
 	switch msg.Type {
 	case ws.MsgPlayerInput:
 		var input PlayerInput
-		if err := json.Unmarshal(msg.Payload, &input); err != nil {
+		if err := msg.DecodePayload(&input); err != nil {
 			return
 		}
 		// Clamp moveX to valid range [-1, 1]
@@ -123,74 +251,137 @@ func (r *Room) handleMessage(playerIdx int, msg ws.Message) {
 		if input.MoveX > 1 {
 			input.MoveX = 1
 		}
+		if input.Tick == 0 {
+			input.Tick = msg.Tick // the tick the client was showing, for lag compensation
+		}
 		r.inputMu.Lock()
-		r.inputs[playerIdx] = input
+		r.inputs[playerIdx].push(input)
 		r.inputMu.Unlock()
+		r.inputSeen.Store(true)
 
 	case ws.MsgPing:
 		var ping ws.PingPayload
-		if err := json.Unmarshal(msg.Payload, &ping); err != nil {
+		if err := msg.DecodePayload(&ping); err != nil {
 			return
 		}
-		pong, _ := ws.NewMessage(ws.MsgPong, r.state.Tick, ws.PongPayload{
+		pong := ws.NewMessage(ws.MsgPong, r.state.Tick, ws.PongPayload{
 			ClientTime: ping.ClientTime,
 			ServerTime: uint64(time.Now().UnixMilli()),
 		})
-		r.conns[playerIdx].Send(pong)
+		r.sendTo(playerIdx, pong)
 
 	case ws.MsgJoinQueue:
-		// "Play Again" request — not implemented yet at server level
+		// "Play Again" on the game-over screen
+		r.requestRematch(playerIdx)
 	}
 }
 
-func (r *Room) handleDisconnect(playerIdx int) {
-	other := 1 - playerIdx
-	msg, _ := ws.NewMessage(ws.MsgPlayerDisconnected, r.state.Tick, ws.PlayerDisconnectedPayload{
+func (r *Room) handleDisconnect(conn PlayerEndpoint, playerIdx int) {
+	if r.Players()[playerIdx] != conn {
+		return // seat already taken over by a resumed connection
+	}
+	if r.suspend(playerIdx) {
+		return
+	}
+	msg := ws.NewMessage(ws.MsgPlayerDisconnected, 0, ws.PlayerDisconnectedPayload{
 		PlayerIndex: uint8(playerIdx),
 	})
-	r.conns[other].Send(msg)
-	r.cancel()
+	r.sendTo(1-playerIdx, msg)
+	r.playerLeft(playerIdx)
 }
 
 func (r *Room) tick() {
+	if r.away.Load() != 0 && !r.over.Load() {
+		r.tickPaused()
+		return
+	}
+	r.pauseTicks = 0
+
+	// Only the playing phase consumes input; countdown/scored leave it queued.
+	var inputs [2]PlayerInput
+	if r.state.Phase.Live() {
+		r.botInputs()
+		inputs = r.takeInputs()
+		for i := range inputs {
+			r.setRewind(&inputs[i])
+		}
+		// Echo what was consumed so clients can reconcile their prediction.
+		// Set outside step(): it is network bookkeeping, not simulation.
+		r.state.InputSeq = [2]uint32{inputs[0].Seq, inputs[1].Seq}
+	}
+	wasOver := r.state.Phase == PhaseGameOver
+	r.step(inputs)
+	r.publishSummary()
+
+	// The final state went out on the tick the game ended; after that
+	// nothing changes, so only resend it to whoever asks.
+	if wasOver {
+		r.tickGameOver()
+		r.resendState()
+		return
+	}
+	r.tickIdle()
+	r.broadcastState()
+}
+
+// botInputs queues this tick's input for each bot seat, alongside where a
+// human's input would arrive.
+func (r *Room) botInputs() {
+	for i, b := range r.bots {
+		if b == nil {
+			continue
+		}
+		in := b.Input(&r.state, &r.rules)
+		r.inputMu.Lock()
+		r.inputs[i].push(in)
+		r.inputMu.Unlock()
+	}
+}
+
+// takeInputs consumes the next queued input for each player.
+func (r *Room) takeInputs() [2]PlayerInput {
+	r.inputMu.Lock()
+	inputs := [2]PlayerInput{r.inputs[0].next(), r.inputs[1].next()}
+	r.inputMu.Unlock()
+	return inputs
+}
+
+// step advances the simulation by one tick. Everything that changes game
+// state goes through here, so feeding it recorded inputs with the same seed
+// reproduces a match exactly — that's how replays are played back.
+func (r *Room) step(inputs [2]PlayerInput) {
 	s := &r.state
+	if r.recorder != nil && s.Phase != PhaseGameOver {
+		r.recorder.recordInputs(inputs)
+	}
 	s.Tick++
 
 	switch s.Phase {
 	case PhaseCountdown:
 		r.tickCountdown()
-	case PhasePlaying:
-		r.tickPlaying()
+	case PhasePlaying, PhaseGamePoint, PhaseWinByTwo, PhaseOvertime:
+		r.tickPlaying(inputs)
 	case PhaseScored:
 		r.tickScored()
 	case PhaseGameOver:
-		// No more updates; room stays alive for clients to see final state
+		// No more updates; the room lingers on the final score until a
+		// rematch or timeouts.GameOver (see tickGameOver)
 	}
-
-	r.broadcastState()
+	r.history.push(s) // lag compensation looks back through these
 }
 
 func (r *Room) tickCountdown() {
 	s := &r.state
-	s.PhaseTimer -= DT
+	s.PhaseTimer -= r.rules.dt()
 	if s.PhaseTimer <= 0 {
-		s.Phase = PhasePlaying
+		s.Phase = r.livePhase()
 		s.PhaseTimer = 0
 	}
 }
 
-func (r *Room) tickPlaying() {
+func (r *Room) tickPlaying(inputs [2]PlayerInput) {
 	s := &r.state
 
-	// Read inputs; consume one-shot actions (jump, shoot) but keep movement (moveX)
-	r.inputMu.Lock()
-	inputs := r.inputs
-	for i := range r.inputs {
-		r.inputs[i].Jump = false
-		r.inputs[i].Shoot = false
-	}
-	r.inputMu.Unlock()
-
 	// Decrement steal cooldown for both players
 	for i := range s.Players {
 		if s.Players[i].StealCooldown > 0 {
@@ -200,7 +391,7 @@ func (r *Room) tickPlaying() {
 
 	// Apply inputs
 	for i := range s.Players {
-		ApplyInput(&s.Players[i], inputs[i])
+		ApplyInput(&s.Players[i], inputs[i], &r.rules)
 
 		if inputs[i].Shoot {
 			if s.Players[i].HasBall {
@@ -215,21 +406,34 @@ func (r *Room) tickPlaying() {
 				}
 
 				if canShoot {
-					// Check for block by opponent
+					// Check for block by the opponent as the shooter saw it
 					otherIdx := 1 - i
 					blocker := &s.Players[otherIdx]
-					blocked := TryBlockShot(&s.Ball, &s.Players[i], int8(i), blocker)
-					if !blocked {
-						ShootBall(&s.Ball, &s.Players[i], int8(i))
+					blocked := TryBlockShot(&s.Ball, &s.Players[i], int8(i), r.seenOpponent(i, inputs[i]), &r.rules)
+					if blocked {
+						blocker.Anim = AnimBlock
+						r.log.Debug("block", "player", otherIdx, "x", blocker.X, "y", blocker.Y, "rewind", inputs[i].rewind)
+						r.recordEvent(EventBlock, otherIdx, 0)
+					} else {
+						x := s.Players[i].X
+						hit := ShootBall(&s.Ball, &s.Players[i], int8(i), &r.rules, r.rng)
+						r.log.Debug("shot", "player", i, "x", x, "accuracy", shotAccuracy(x, int8(i), &r.rules), "hit", hit)
+						r.recordEvent(EventShot, i, 0)
 					}
 				}
 			} else if s.Players[i].StealCooldown == 0 {
 				// No ball — attempt steal if opponent has ball
 				otherIdx := 1 - i
 				if s.Players[otherIdx].HasBall {
-					attempted := TrySteal(&s.Ball, &s.Players[i], int8(i), &s.Players[otherIdx], int8(otherIdx))
+					seen := r.seenOpponent(i, inputs[i])
+					attempted := TrySteal(&s.Ball, &s.Players[i], int8(i), &s.Players[otherIdx], int8(otherIdx), seen, &r.rules, r.rng)
 					if attempted {
-						s.Players[i].StealCooldown = StealCooldownTicks
+						s.Players[i].StealCooldown = uint8(r.rules.ticks(r.rules.StealCooldownSecs))
+						stolen := !s.Players[otherIdx].HasBall
+						r.log.Debug("steal", "player", i, "ok", stolen, "rewind", inputs[i].rewind)
+						if stolen {
+							r.recordEvent(EventSteal, i, 0)
+						}
 					}
 				}
 			}
@@ -238,45 +442,85 @@ func (r *Room) tickPlaying() {
 
 	// Step physics
 	for i := range s.Players {
-		StepPlayer(&s.Players[i])
+		StepPlayer(&s.Players[i], &r.rules)
 	}
 
 	prevBallY := s.Ball.Y
-	StepBall(&s.Ball, &s.Players)
+	if p := StepBall(&s.Ball, &s.Players, &r.rules); p >= 0 {
+		if n, ok := r.deflectLog.Allow(); ok {
+			r.log.Debug("deflection", "player", p, "x", s.Ball.X, "y", s.Ball.Y, "suppressed", n)
+		}
+	}
 
 	// Check scoring against both hoops
-	if CheckBallHoop(&s.Ball, &RightHoop, prevBallY) {
+	if CheckBallHoop(&s.Ball, &RightHoop, prevBallY, &r.rules) {
 		r.scored(0)
 		return
 	}
-	if CheckBallHoop(&s.Ball, &LeftHoop, prevBallY) {
+	if CheckBallHoop(&s.Ball, &LeftHoop, prevBallY, &r.rules) {
 		r.scored(1)
 		return
 	}
 
 	// Shot clock
-	s.ShotClock -= DT
+	s.ShotClock -= r.rules.dt()
 	if s.ShotClock <= 0 {
 		r.shotClockViolation()
 	}
 
-	// Game clock
-	s.GameClock -= DT
+	// Game clock; it stays at 0 through overtime
+	if s.Phase == PhaseOvertime {
+		return
+	}
+	s.GameClock -= r.rules.dt()
 	if s.GameClock <= 0 {
 		s.GameClock = 0
-		r.gameOver()
+		if s.Score[0] == s.Score[1] && r.rules.SuddenDeath {
+			r.log.Info("tied, sudden-death overtime", "score", s.Score[0])
+			s.Phase = PhaseOvertime
+			return
+		}
+		r.gameOver(ws.GameOverClock)
 	}
 }
 
 func (r *Room) tickScored() {
 	s := &r.state
-	s.PhaseTimer -= DT
+	s.PhaseTimer -= r.rules.dt()
 	if s.PhaseTimer <= 0 {
-		s.Phase = PhasePlaying
+		s.Phase = r.livePhase()
 		s.PhaseTimer = 0
 	}
 }
 
+// livePhase is the phase play resumes in after a countdown or a basket,
+// going by the score.
+func (r *Room) livePhase() GamePhase {
+	s := &r.state
+	target := r.rules.ScoreTarget
+	switch {
+	case s.GameClock <= 0:
+		return PhaseOvertime // the clock only runs out without ending the game on a tie
+	case target == 0:
+		return PhasePlaying
+	case r.rules.WinByTwo && max(s.Score[0], s.Score[1]) >= target:
+		return PhaseWinByTwo
+	case r.winsAt(s.Score[0]+3, s.Score[1]) || r.winsAt(s.Score[1]+3, s.Score[0]):
+		return PhaseGamePoint
+	}
+	return PhasePlaying
+}
+
+// winsAt reports whether a player with score has won against opp under the
+// room's score target.
+func (r *Room) winsAt(score, opp uint8) bool {
+	target := r.rules.ScoreTarget
+	if target == 0 || score < target {
+		return false
+	}
+	return !r.rules.WinByTwo || score >= opp+2
+}
+
 func (r *Room) scored(playerIdx int) {
 	s := &r.state
 
@@ -285,18 +529,19 @@ func (r *Room) scored(playerIdx int) {
 	shotX := s.Ball.ShotOriginX
 	if playerIdx == 0 {
 		// P0 scores on right hoop (x=720). 3-point line at 720-150=570.
-		if shotX < HoopRightX-ThreePointRadius {
+		if shotX < HoopRightX-r.rules.ThreePointRadius {
 			points = 3
 		}
 	} else {
 		// P1 scores on left hoop (x=80). 3-point line at 80+150=230.
-		if shotX > HoopLeftX+ThreePointRadius {
+		if shotX > HoopLeftX+r.rules.ThreePointRadius {
 			points = 3
 		}
 	}
 	s.Score[playerIdx] += points
+	wasPhase := s.Phase
 
-	log.Printf("SCORED: player %d +%d pts (shot from x=%.1f)", playerIdx, points, shotX)
+	r.log.Debug("scored", "player", playerIdx, "points", points, "x", shotX)
 
 	// Enter scored pause phase
 	s.Phase = PhaseScored
@@ -322,25 +567,31 @@ func (r *Room) scored(playerIdx int) {
 	s.Players[1].Anim = AnimIdle
 
 	// Reset shot clock
-	s.ShotClock = ShotClockSecs
-
-	for _, c := range r.conns {
-		msg, _ := ws.NewMessage(ws.MsgScored, s.Tick, struct {
-			ScorerIndex uint8    `json:"scorerIndex"`
-			Points      uint8    `json:"points"`
-			NewScore    [2]uint8 `json:"newScore"`
-		}{
-			ScorerIndex: uint8(playerIdx),
-			Points:      points,
-			NewScore:    s.Score,
-		})
-		c.Send(msg)
+	s.ShotClock = r.rules.ShotClockSecs
+
+	r.recordEvent(EventScore, playerIdx, points)
+	msg := ws.NewMessage(ws.MsgScored, s.Tick, ws.ScoredPayload{
+		ScorerIndex: uint8(playerIdx),
+		Points:      points,
+		NewScore:    s.Score,
+	})
+	r.broadcast(msg)
+
+	switch {
+	case wasPhase == PhaseOvertime:
+		r.gameOver(ws.GameOverOvertime)
+	case r.winsAt(s.Score[playerIdx], s.Score[1-playerIdx]):
+		reason := ws.GameOverScoreTarget
+		if wasPhase == PhaseWinByTwo {
+			reason = ws.GameOverWinByTwo
+		}
+		r.gameOver(reason)
 	}
 }
 
 func (r *Room) shotClockViolation() {
 	s := &r.state
-	s.ShotClock = ShotClockSecs
+	s.ShotClock = r.rules.ShotClockSecs
 
 	// Determine who had possession and give ball to the other player
 	currentOwner := -1
@@ -352,6 +603,7 @@ func (r *Room) shotClockViolation() {
 	if currentOwner == -1 && s.Ball.Owner >= 0 {
 		currentOwner = int(s.Ball.Owner)
 	}
+	r.recordEvent(EventShotClock, currentOwner, 0)
 
 	// Turnover: give ball to the other player
 	var newOwner int
@@ -378,7 +630,8 @@ func (r *Room) shotClockViolation() {
 	s.Players[1].VY = 0
 }
 
-func (r *Room) gameOver() {
+// gameOver ends the game, reason being one of the ws.GameOver* reasons.
+func (r *Room) gameOver(reason string) {
 	s := &r.state
 	s.Phase = PhaseGameOver
 	s.PhaseTimer = 0
@@ -392,63 +645,120 @@ func (r *Room) gameOver() {
 		s.Winner = -1 // tie
 	}
 
+	r.over.Store(true)
+
 	// Send game over message
-	for _, c := range r.conns {
-		msg, _ := ws.NewMessage(ws.MsgGameOver, s.Tick, struct {
-			Winner int8     `json:"winner"`
-			Score  [2]uint8 `json:"score"`
-		}{
-			Winner: s.Winner,
-			Score:  s.Score,
-		})
-		c.Send(msg)
-	}
+	r.log.Info("game over", "reason", reason, "score0", s.Score[0], "score1", s.Score[1])
+	msg := ws.NewMessage(ws.MsgGameOver, s.Tick, ws.GameOverPayload{
+		Winner: s.Winner,
+		Score:  s.Score,
+		Reason: reason,
+	})
+	r.broadcast(msg)
+
+	r.recordEvent(EventGameOver, int(s.Winner), 0)
+	r.saveReplay()
 
 	// Record tournament result and send updated stats
 	if r.tournament != nil {
-		r.tournament.RecordResult(r.nicknames[0], r.nicknames[1], s.Score[0], s.Score[1])
-
-		for i, c := range r.conns {
-			otherIdx := 1 - i
-			myStats := r.tournament.GetStats(r.nicknames[i])
-			oppStats := r.tournament.GetStats(r.nicknames[otherIdx])
-
-			msg, _ := ws.NewMessage(ws.MsgTournamentResult, s.Tick, ws.TournamentResultPayload{
-				YourStats: ws.TournamentPlayerStats{
-					Nickname:    myStats.Nickname,
-					Wins:        myStats.Wins,
-					Losses:      myStats.Losses,
-					Draws:       myStats.Draws,
-					PointsFor:   myStats.PointsFor,
-					GamesPlayed: myStats.GamesPlayed,
-				},
-				OpponentStats: ws.TournamentPlayerStats{
-					Nickname:    oppStats.Nickname,
-					Wins:        oppStats.Wins,
-					Losses:      oppStats.Losses,
-					Draws:       oppStats.Draws,
-					PointsFor:   oppStats.PointsFor,
-					GamesPlayed: oppStats.GamesPlayed,
-				},
-			})
-			c.Send(msg)
+		change := r.tournament.RecordResult(r.nicknames[0], r.nicknames[1], s.Score[0], s.Score[1])
+		r.sendTournamentResults(change)
+	}
+	r.recordForm()
+}
+
+// sendTournamentResults sends each player their updated tournament record
+// and rating change next to their opponent's. change is indexed by player.
+func (r *Room) sendTournamentResults(change RatingChange) {
+	for i, c := range r.Players() {
+		if c == nil {
+			continue
 		}
+		myStats := r.tournament.GetStats(r.nicknames[i])
+		oppStats := r.tournament.GetStats(r.nicknames[1-i])
+		msg := ws.NewMessage(ws.MsgTournamentResult, r.state.Tick, ws.TournamentResultPayload{
+			YourStats:            tournamentPlayerStats(myStats),
+			OpponentStats:        tournamentPlayerStats(oppStats),
+			YourRatingChange:     float32(change[i]),
+			OpponentRatingChange: float32(change[1-i]),
+		})
+		c.Send(msg)
+	}
+}
+
+func tournamentPlayerStats(s PlayerStats) ws.TournamentPlayerStats {
+	return ws.TournamentPlayerStats{
+		Nickname:    s.Nickname,
+		Wins:        s.Wins,
+		Losses:      s.Losses,
+		Draws:       s.Draws,
+		PointsFor:   s.PointsFor,
+		GamesPlayed: s.GamesPlayed,
+		Forfeits:    s.Forfeits,
+		Rating:      float32(s.Rating),
+		RatingDev:   float32(s.RatingDeviation),
 	}
 }
 
 func (r *Room) broadcastState() {
-	msg, err := ws.NewMessage(ws.MsgGameState, r.state.Tick, r.state)
-	if err != nil {
-		log.Printf("failed to encode state: %v", err)
-		return
+	r.history.push(&r.state) // again: a forfeit changes the state outside step()
+	defer func() { r.urgent = false }()
+
+	// The keyframe is encoded at most once per codec and shared; deltas are
+	// per recipient, keyed by the tick each one last acknowledged.
+	key := ws.NewBroadcast(ws.NewMessage(ws.MsgGameState, r.state.Tick, r.state))
+	keyframe := r.state.Tick%uint32(r.keyframeInterval()) == 0
+	var deltas []encodedDelta
+	for _, c := range r.Players() {
+		if c != nil && r.stateDue(c) {
+			r.sendState(c, &key, keyframe, &deltas)
+		}
 	}
-	// Encode once — send identical bytes to both players
-	data, err := ws.Encode(msg)
-	if err != nil {
-		log.Printf("failed to marshal state message: %v", err)
-		return
+	r.spectators.each(func(c PlayerEndpoint) {
+		if r.stateDue(c) {
+			r.sendState(c, &key, keyframe, &deltas)
+		}
+	})
+}
+
+// resendState sends the frozen game-over state as a keyframe to recipients
+// that requested one (new spectators, clients that lost sync).
+func (r *Room) resendState() {
+	var key *ws.Broadcast
+	send := func(c PlayerEndpoint) {
+		if s, ok := c.(StreamEndpoint); !ok || !s.TakeKeyframeRequest() {
+			return
+		}
+		if key == nil {
+			b := ws.NewBroadcast(ws.NewMessage(ws.MsgGameState, r.state.Tick, r.state))
+			key = &b
+		}
+		c.SendShared(key)
 	}
-	for _, c := range r.conns {
-		c.SendRaw(data)
+	for _, c := range r.Players() {
+		if c != nil {
+			send(c)
+		}
+	}
+	r.spectators.each(send)
+}
+
+// sendTo sends msg to player i, if that seat has an endpoint (a bot's
+// doesn't).
+func (r *Room) sendTo(i int, msg ws.Message) {
+	if c := r.Players()[i]; c != nil {
+		c.Send(msg)
+	}
+}
+
+// broadcast sends msg to every connected player and spectator. Rooms
+// re-simulated for replay playback have no endpoints and send nothing.
+func (r *Room) broadcast(msg ws.Message) {
+	b := ws.NewBroadcast(msg)
+	for _, c := range r.Players() {
+		if c != nil {
+			c.SendShared(&b)
+		}
 	}
+	r.spectators.broadcast(&b)
 }
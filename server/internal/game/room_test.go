@@ -0,0 +1,175 @@
+package game
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/vladimirvolkov/basketball/server/internal/ws"
+)
+
+// scriptedInputs returns n ticks of random but repeatable input for both
+// players, drawn from its own stream so it doesn't depend on the room's.
+func scriptedInputs(seed int64, n int) [][2]PlayerInput {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([][2]PlayerInput, n)
+	for t := range out {
+		for i := range out[t] {
+			out[t][i] = PlayerInput{
+				MoveX: int8(rng.Intn(3) - 1),
+				Jump:  rng.Intn(20) == 0,
+				Shoot: rng.Intn(30) == 0,
+			}
+		}
+	}
+	return out
+}
+
+// playScript steps a room with the given seed through inputs and returns
+// the state after every tick.
+func playScript(seed int64, inputs [][2]PlayerInput) []GameState {
+	r := newRoom([2]string{"A", "B"}, seed)
+	r.timeouts = RoomTimeouts{}
+	states := make([]GameState, 0, len(inputs))
+	for _, in := range inputs {
+		if !r.state.Phase.Live() {
+			in = [2]PlayerInput{}
+		}
+		r.step(in)
+		states = append(states, r.state)
+	}
+	return states
+}
+
+func TestSameSeedSameGame(t *testing.T) {
+	inputs := scriptedInputs(7, 60*DefaultTickRate)
+	a := playScript(42, inputs)
+	b := playScript(42, inputs)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("tick %d: states differ\n%+v\n%+v", a[i].Tick, a[i], b[i])
+		}
+	}
+}
+
+func TestSeedDrivesRandomOutcomes(t *testing.T) {
+	inputs := scriptedInputs(7, 60*DefaultTickRate)
+	a := playScript(42, inputs)
+	b := playScript(43, inputs)
+	for i := range a {
+		if a[i] != b[i] {
+			return
+		}
+	}
+	t.Fatal("different seeds played out identically; shots and steals should draw from the room's RNG")
+}
+
+func TestSimMatchIsReproducible(t *testing.T) {
+	play := func() GameState {
+		m := NewSimMatch([2]BotDifficulty{BotNormal, BotHard}, 99, ClassicRules())
+		for {
+			if _, ok := m.Step(); !ok {
+				return *m.State()
+			}
+		}
+	}
+	if a, b := play(), play(); a != b {
+		t.Fatalf("final states differ\n%+v\n%+v", a, b)
+	}
+}
+
+func TestTickExternalKeepsRoomRate(t *testing.T) {
+	for _, c := range []struct{ room, engine int }{{60, 60}, {30, 60}, {60, 120}, {40, 120}, {120, 240}} {
+		rules := ClassicRules()
+		rules.TickRate = c.room
+		r := recordingBotRoom(1, rules)
+		for range c.engine {
+			r.TickExternal(c.engine)
+		}
+		if got := int(r.state.Tick); got != c.room {
+			t.Errorf("%d Hz room on a %d Hz engine ran %d ticks in a second", c.room, c.engine, got)
+		}
+	}
+}
+
+// TestMatchOverMemEndpoints plays a whole game through the room's message
+// path: GameStart out, input in through each endpoint's read loop, and
+// Scored and GameOver back out to both players.
+func TestMatchOverMemEndpoints(t *testing.T) {
+	eps := [2]*MemEndpoint{NewMemEndpoint("a", "Alice", 64), NewMemEndpoint("b", "Bob", 64)}
+	r := NewSeededRoom(eps[0], eps[1], 3)
+	quick, _ := DefaultRuleBook().Preset("quick")
+	r.SetRules(quick)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx)
+
+	for i, ep := range eps {
+		var start ws.GameStartPayload
+		if msg := <-ep.Outbox(); msg.Type != ws.MsgGameStart {
+			t.Fatalf("player %d first got message 0x%02x, want GameStart", i, msg.Type)
+		} else if err := msg.DecodePayload(&start); err != nil {
+			t.Fatal(err)
+		}
+		if start.PlayerIndex != uint8(i) || start.Names != [2]string{"Alice", "Bob"} || start.Rules != "quick" {
+			t.Fatalf("player %d GameStart %+v", i, start)
+		}
+	}
+
+	// Bots do the playing; their input goes in as a client's would.
+	bots := [2]*Bot{NewBot(0, BotHard, 3), NewBot(1, BotHard, 3)}
+	var scored [2][2]uint8 // the last score each player was told about
+	var over [2]*ws.GameOverPayload
+	for tick := 0; over[0] == nil || over[1] == nil; tick++ {
+		if tick > 60*60*DefaultTickRate {
+			t.Fatal("game never ended")
+		}
+		if r.state.Phase.Live() {
+			for i, ep := range eps {
+				if !ep.SendInput(bots[i].Input(&r.state, &r.rules)) {
+					t.Fatalf("player %d endpoint closed mid-game", i)
+				}
+			}
+		}
+		r.tick()
+		for i, ep := range eps {
+		drain:
+			for {
+				select {
+				case msg := <-ep.Outbox():
+					switch msg.Type {
+					case ws.MsgScored:
+						var p ws.ScoredPayload
+						if err := msg.DecodePayload(&p); err != nil {
+							t.Fatal(err)
+						}
+						scored[i] = p.NewScore
+					case ws.MsgGameOver:
+						over[i] = new(ws.GameOverPayload)
+						if err := msg.DecodePayload(over[i]); err != nil {
+							t.Fatal(err)
+						}
+					}
+				default:
+					break drain
+				}
+			}
+		}
+	}
+
+	final := r.state.Score
+	if final == [2]uint8{} {
+		t.Fatal("nobody scored")
+	}
+	if r.state.InputSeq == [2]uint32{} {
+		t.Fatal("no input reached the room")
+	}
+	for i := range eps {
+		if scored[i] != final {
+			t.Errorf("player %d last told the score was %v, final %v", i, scored[i], final)
+		}
+		if over[i].Score != final || over[i].Winner != r.state.Winner || over[i].Forfeit {
+			t.Errorf("player %d GameOver %+v, final score %v winner %d", i, *over[i], final, r.state.Winner)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// Game rules.
+//
+// Everything that decides how a game plays — clocks, scoring, steals,
+// blocks, movement — lives in a Rules value. A room copies its Rules when it
+// is created and never looks at the preset again, so reloading or editing
+// presets can't change a game in progress. The preset's name is announced in
+// GameStart.
+//
+// The movement fields (speeds, jumps, gravity) are also simulated by the
+// client for prediction, which assumes the classic values, so a rules file
+// can't change them. Only the simulator's overrides can, to try things out.
+//
+// TickRate is how many times a second the room simulates. Every duration is
+// set in seconds and counted in ticks at the room's rate, so a preset can
+// change the rate without changing how the game plays. The engine ticks as
+// fast as the fastest preset (see RuleBook.MaxTickRate), and clients learn a
+// room's rate from GameStart.
+
+// Rules are the settings one game is played by. JSON field names match the Go
+// names case-insensitively, so override files may use either.
+type Rules struct {
+	Name     string `json:"name"`
+	TickRate int    `json:"tickRate"` // simulation ticks per second
+
+	GameDuration  float32 `json:"gameDuration"`          // seconds of game clock
+	ShotClockSecs float32 `json:"shotClockSecs"`         // seconds per possession
+	ScoreTarget   uint8   `json:"scoreTarget,omitempty"` // first to this many points wins; 0 = play the clock out
+	WinByTwo      bool    `json:"winByTwo,omitempty"`    // reaching ScoreTarget only wins with a two-point lead
+	SuddenDeath   bool    `json:"suddenDeath,omitempty"` // a tie when the clock runs out goes to next-basket-wins overtime
+
+	Gravity              float32 `json:"gravity"`
+	PlayerSpeedWithBall  float32 `json:"playerSpeedWithBall"`
+	DefenderSpeedBoost   float32 `json:"defenderSpeedBoost"`
+	JumpVelocity         float32 `json:"jumpVelocity"`
+	DefenderJumpVelocity float32 `json:"defenderJumpVelocity"`
+	AirControlMult       float32 `json:"airControlMult"`
+
+	RestitutionRim       float32 `json:"restitutionRim"`
+	RestitutionBackboard float32 `json:"restitutionBackboard"`
+	RestitutionFloor     float32 `json:"restitutionFloor"`
+	MaxShootForce        float32 `json:"maxShootForce"`
+	MinShootForce        float32 `json:"minShootForce"`
+
+	// Shot accuracy curve (see shotAccuracy): chance to hit from under the
+	// hoop, at the 3-point line and from the far wall.
+	ShotAccuracyClose float64 `json:"shotAccuracyClose"`
+	ShotAccuracyThree float64 `json:"shotAccuracyThree"`
+	ShotAccuracyFar   float64 `json:"shotAccuracyFar"`
+	ThreePointRadius  float32 `json:"threePointRadius"` // distance from hoop center
+
+	BlockRange        float32 `json:"blockRange"`
+	DeflectSpeedMult  float32 `json:"deflectSpeedMult"`
+	StealRange        float32 `json:"stealRange"`        // proximity for steal attempt
+	StealChance       float64 `json:"stealChance"`       // success probability
+	StealCooldownSecs float64 `json:"stealCooldownSecs"` // between attempts
+}
+
+// ClassicRules is the standard game: two minutes, 24-second shot clock.
+func ClassicRules() Rules {
+	return Rules{
+		Name:          "classic",
+		TickRate:      DefaultTickRate,
+		GameDuration:  120,
+		ShotClockSecs: 24,
+
+		Gravity:              1800,
+		PlayerSpeedWithBall:  300,
+		DefenderSpeedBoost:   350,
+		JumpVelocity:         -780,
+		DefenderJumpVelocity: -880,
+		AirControlMult:       0.5,
+
+		RestitutionRim:       0.6,
+		RestitutionBackboard: 0.4,
+		RestitutionFloor:     0.5,
+		MaxShootForce:        1200,
+		MinShootForce:        300,
+
+		ShotAccuracyClose: 0.6,
+		ShotAccuracyThree: 0.25,
+		ShotAccuracyFar:   0.15,
+		ThreePointRadius:  150,
+
+		BlockRange:        50,
+		DeflectSpeedMult:  0.5,
+		StealRange:        40,
+		StealChance:       0.5,
+		StealCooldownSecs: 0.5,
+	}
+}
+
+// builtinPresets are available without a rules file.
+func builtinPresets() map[string]Rules {
+	quick := ClassicRules()
+	quick.Name = "quick"
+	quick.GameDuration = 60
+
+	first21 := ClassicRules()
+	first21.Name = "first-to-21"
+	first21.ScoreTarget = 21
+	first21.GameDuration = 600 // a cap, so a stalled game still ends
+
+	winByTwo := first21
+	winByTwo.Name = "win-by-two"
+	winByTwo.WinByTwo = true
+
+	overtime := ClassicRules()
+	overtime.Name = "overtime"
+	overtime.SuddenDeath = true
+
+	noSteals := ClassicRules()
+	noSteals.Name = "no-steals"
+	noSteals.StealChance = 0
+
+	presets := map[string]Rules{}
+	for _, r := range []Rules{ClassicRules(), quick, first21, winByTwo, overtime, noSteals} {
+		presets[r.Name] = r
+	}
+	return presets
+}
+
+// SetRules replaces the classic rules the room was created with. Call before
+// Start.
+func (r *Room) SetRules(rules Rules) {
+	r.rules = rules
+	r.state.ShotClock = rules.ShotClockSecs
+	r.state.GameClock = rules.GameDuration
+	r.publishSummary()
+}
+
+// Rules returns the room's rules.
+func (r *Room) Rules() Rules {
+	return r.rules
+}
+
+// Apply overlays the fields set in a JSON object onto r, then checks the
+// result. Unknown fields are an error.
+func (r *Rules) Apply(data []byte) error {
+	next := *r
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&next); err != nil {
+		return err
+	}
+	if err := next.validate(); err != nil {
+		return err
+	}
+	*r = next
+	return nil
+}
+
+// dt is the time one tick simulates, in seconds.
+func (r *Rules) dt() float32 {
+	return 1 / float32(r.TickRate)
+}
+
+// ticks converts secs to the nearest whole number of ticks.
+func (r *Rules) ticks(secs float64) int {
+	return int(math.Round(secs * float64(r.TickRate)))
+}
+
+func (r *Rules) validate() error {
+	switch {
+	case r.TickRate < MinTickRate || r.TickRate > MaxTickRate:
+		return fmt.Errorf("tickRate must be within %d..%d", MinTickRate, MaxTickRate)
+	case r.GameDuration <= 0:
+		return errors.New("gameDuration must be positive")
+	case r.WinByTwo && r.ScoreTarget == 0:
+		return errors.New("winByTwo needs a scoreTarget")
+	case r.ShotClockSecs <= 0:
+		return errors.New("shotClockSecs must be positive")
+	case r.Gravity <= 0:
+		return errors.New("gravity must be positive")
+	case r.PlayerSpeedWithBall <= 0 || r.DefenderSpeedBoost <= 0:
+		return errors.New("playerSpeedWithBall and defenderSpeedBoost must be positive")
+	case r.JumpVelocity >= 0 || r.DefenderJumpVelocity >= 0:
+		return errors.New("jumpVelocity and defenderJumpVelocity must be negative (up)")
+	case r.AirControlMult < 0 || r.AirControlMult > 1:
+		return errors.New("airControlMult must be within 0..1")
+	case r.RestitutionRim < 0 || r.RestitutionRim > 1 ||
+		r.RestitutionBackboard < 0 || r.RestitutionBackboard > 1 ||
+		r.RestitutionFloor < 0 || r.RestitutionFloor > 1:
+		return errors.New("restitutions must be within 0..1")
+	case r.MinShootForce <= 0 || r.MaxShootForce < r.MinShootForce:
+		return errors.New("need 0 < minShootForce <= maxShootForce")
+	case r.ShotAccuracyClose < 0 || r.ShotAccuracyClose > 1 ||
+		r.ShotAccuracyThree < 0 || r.ShotAccuracyThree > 1 ||
+		r.ShotAccuracyFar < 0 || r.ShotAccuracyFar > 1:
+		return errors.New("shot accuracies must be within 0..1")
+	case r.ThreePointRadius <= 0 || r.ThreePointRadius >= CourtWidth:
+		return errors.New("threePointRadius must be positive and inside the court")
+	case r.BlockRange < 0:
+		return errors.New("blockRange must not be negative")
+	case r.DeflectSpeedMult < 0:
+		return errors.New("deflectSpeedMult must not be negative")
+	case r.StealRange < 0:
+		return errors.New("stealRange must not be negative")
+	case r.StealChance < 0 || r.StealChance > 1:
+		return errors.New("stealChance must be within 0..1")
+	case r.StealCooldownSecs < 0 || r.ticks(r.StealCooldownSecs) > math.MaxUint8:
+		return errors.New("stealCooldownSecs must not be negative, nor more than 255 ticks")
+	}
+	return nil
+}
+
+// sameMovement reports whether r moves players exactly as o does.
+func (r *Rules) sameMovement(o *Rules) bool {
+	return r.Gravity == o.Gravity &&
+		r.PlayerSpeedWithBall == o.PlayerSpeedWithBall &&
+		r.DefenderSpeedBoost == o.DefenderSpeedBoost &&
+		r.JumpVelocity == o.JumpVelocity &&
+		r.DefenderJumpVelocity == o.DefenderJumpVelocity &&
+		r.AirControlMult == o.AirControlMult
+}
+
+// RuleBook holds the named presets and the one new rooms use by default.
+type RuleBook struct {
+	Default string
+	presets map[string]Rules
+}
+
+// DefaultRuleBook has the built-in presets, defaulting to classic.
+func DefaultRuleBook() *RuleBook {
+	return &RuleBook{Default: "classic", presets: builtinPresets()}
+}
+
+// LoadRuleBook reads a rules file on top of the built-in presets:
+//
+//	{"default": "party", "presets": {"party": {"gameDuration": 90, "stealChance": 0.7}}}
+//
+// Each preset starts from the classic rules and may redefine a built-in one,
+// but not the movement fields.
+func LoadRuleBook(path string) (*RuleBook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Default string                     `json:"default"`
+		Presets map[string]json.RawMessage `json:"presets"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	rb := DefaultRuleBook()
+	for name, raw := range file.Presets {
+		r := ClassicRules()
+		if err := r.Apply(raw); err != nil {
+			return nil, fmt.Errorf("%s: preset %q: %w", path, name, err)
+		}
+		if classic := ClassicRules(); !r.sameMovement(&classic) {
+			return nil, fmt.Errorf("%s: preset %q: gravity, player speeds, jumps and air control are fixed; the client predicts movement with the classic values", path, name)
+		}
+		r.Name = name
+		rb.presets[name] = r
+	}
+	if file.Default != "" {
+		rb.Default = file.Default
+	}
+	if _, ok := rb.presets[rb.Default]; !ok {
+		return nil, fmt.Errorf("%s: default preset %q is not defined", path, rb.Default)
+	}
+	return rb, nil
+}
+
+// Preset returns a copy of the named preset; "" means the default.
+func (rb *RuleBook) Preset(name string) (Rules, bool) {
+	if name == "" {
+		name = rb.Default
+	}
+	r, ok := rb.presets[name]
+	return r, ok
+}
+
+// MaxTickRate is the fastest TickRate of any preset: the rate the engine
+// has to tick at.
+func (rb *RuleBook) MaxTickRate() int {
+	hz := MinTickRate
+	for _, r := range rb.presets {
+		hz = max(hz, r.TickRate)
+	}
+	return hz
+}
+
+// Names lists the presets, sorted.
+func (rb *RuleBook) Names() []string {
+	names := make([]string, 0, len(rb.presets))
+	for name := range rb.presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,71 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRulesRejectOutOfRange(t *testing.T) {
+	for _, override := range []string{
+		`{"gameDuration": 0}`,
+		`{"shotClockSecs": -1}`,
+		`{"playerSpeedWithBall": 0}`,
+		`{"defenderSpeedBoost": -350}`,
+		`{"jumpVelocity": 780}`,
+		`{"airControlMult": 1.5}`,
+		`{"restitutionRim": -0.1}`,
+		`{"restitutionFloor": 2}`,
+		`{"minShootForce": 900, "maxShootForce": 800}`,
+		`{"shotAccuracyFar": 1.2}`,
+		`{"threePointRadius": -150}`,
+		`{"threePointRadius": 0}`,
+		`{"threePointRadius": 1000}`,
+		`{"blockRange": -50}`,
+		`{"deflectSpeedMult": -1}`,
+		`{"stealRange": -40}`,
+		`{"stealChance": 1.5}`,
+		`{"winByTwo": true}`,
+		`{"tickRate": 10}`,
+		`{"tickRate": 1000}`,
+		`{"stealCooldownSecs": -0.5}`,
+		`{"tickRate": 240, "stealCooldownSecs": 2}`,
+	} {
+		r := ClassicRules()
+		if err := r.Apply([]byte(override)); err == nil {
+			t.Errorf("%s accepted", override)
+		} else if r != ClassicRules() {
+			t.Errorf("%s: rejected, but the rules changed anyway", override)
+		}
+	}
+
+	r := ClassicRules()
+	if err := r.Apply([]byte(`{"stealRange": 0, "blockRange": 0, "gravity": 1500}`)); err != nil {
+		t.Fatalf("in-range override rejected: %v", err)
+	}
+}
+
+func TestRuleBookKeepsClassicMovement(t *testing.T) {
+	load := func(presets string) error {
+		path := filepath.Join(t.TempDir(), "rules.json")
+		if err := os.WriteFile(path, []byte(`{"presets": `+presets+`}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadRuleBook(path)
+		return err
+	}
+	if err := load(`{"party": {"stealChance": 0.7, "threePointRadius": 200}}`); err != nil {
+		t.Fatalf("preset that leaves movement alone rejected: %v", err)
+	}
+	for _, preset := range []string{
+		`{"moon": {"gravity": 600}}`,
+		`{"fast": {"playerSpeedWithBall": 400}}`,
+		`{"hops": {"defenderJumpVelocity": -1000}}`,
+		`{"floaty": {"airControlMult": 1}}`,
+	} {
+		if err := load(preset); err == nil || !strings.Contains(err.Error(), "classic") {
+			t.Errorf("%s: got %v, want movement changes refused", preset, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package game
+
+// Headless simulation, for balancing (see cmd/sim).
+//
+// A SimMatch is a bot-vs-bot room with no endpoints, ticked as fast as the
+// caller likes, under whatever Rules it is given.
+
+// SimMatch is a bot-vs-bot game played without a network or an engine.
+type SimMatch struct {
+	room *Room
+	seen int // events already returned by Step
+}
+
+// NewSimMatch seats bots of the given levels, player 0 first. The same seed
+// and rules always play out the same game.
+func NewSimMatch(levels [2]BotDifficulty, seed int64, rules Rules) *SimMatch {
+	bots := [2]*Bot{NewBot(0, levels[0], seed), NewBot(1, levels[1], seed)}
+	r := newRoom([2]string{bots[0].Name(), bots[1].Name()}, seed)
+	r.SetRules(rules)
+	r.bots = bots
+	r.timeouts = RoomTimeouts{} // nobody sends input; don't end it for idling
+	r.recorder = &replayRecorder{}
+	return &SimMatch{room: r}
+}
+
+// Step runs one tick and returns the events it produced. ok is false once
+// the game is over.
+func (m *SimMatch) Step() (events []ReplayEvent, ok bool) {
+	r := m.room
+	if r.state.Phase == PhaseGameOver {
+		return nil, false
+	}
+	r.tick()
+	events = r.recorder.events[m.seen:]
+	m.seen = len(r.recorder.events)
+	r.recorder.inputs = r.recorder.inputs[:0] // only the events are wanted
+	return events, true
+}
+
+// State returns the state after the last Step. It must not be modified.
+func (m *SimMatch) State() *GameState {
+	return &m.room.state
+}
@@ -0,0 +1,79 @@
+package game
+
+// Snapshot rate.
+//
+// A room simulates every tick, at its rules' TickRate, but need not send
+// every state. Its snapshot rate (SetSnapshotRate, every tick by default) is
+// the most any recipient gets; a client can ask for fewer with
+// /ws?rate=<hz> or ws.MsgSnapshotRate, down to MinSnapshotRate, and is told
+// what it got in GameStart. Rates are rounded down to a whole number of
+// ticks that divides TickRate, so every recipient's sends line up with the
+// once-a-second keyframe.
+//
+// A tick that records an event (score, steal, block, shot, game over...)
+// sends its state to everyone regardless, so nobody sees the basket a
+// snapshot late.
+
+// MinSnapshotRate is the fewest states per second a client can ask for.
+const MinSnapshotRate = 10
+
+// rateLimited is implemented by endpoints whose client may have asked for a
+// lower snapshot rate.
+type rateLimited interface {
+	// SnapshotRate returns the states per second asked for; 0 means as
+	// many as the room sends.
+	SnapshotRate() int
+}
+
+func (w *WSEndpoint) SnapshotRate() int { return w.Conn.SnapshotRate() }
+
+// SetSnapshotRate caps how many states per second the room sends. Call before
+// Start.
+func (r *Room) SetSnapshotRate(hz int) {
+	r.snapRate = hz
+}
+
+// snapshotInterval is the fewest ticks between sends, at tickRate, that keeps
+// to hz.
+func snapshotInterval(tickRate, hz int) int {
+	for n := 1; n < tickRate; n++ {
+		if tickRate%n == 0 && tickRate/n <= hz {
+			return n
+		}
+	}
+	return tickRate
+}
+
+// stateInterval is the ticks between states sent to ep, as negotiated.
+func (r *Room) stateInterval(ep PlayerEndpoint) int {
+	n := 1
+	if r.snapRate > 0 {
+		n = snapshotInterval(r.rules.TickRate, r.snapRate)
+	}
+	if l, ok := ep.(rateLimited); ok {
+		if hz := l.SnapshotRate(); hz > 0 {
+			n = max(n, snapshotInterval(r.rules.TickRate, max(hz, MinSnapshotRate)))
+		}
+	}
+	return n
+}
+
+// stateDue reports whether this tick's state goes to ep.
+func (r *Room) stateDue(ep PlayerEndpoint) bool {
+	if r.urgent || r.state.Phase == PhaseGameOver {
+		return true
+	}
+	n := r.stateInterval(ep)
+	if r.halfRate {
+		n = max(n, 2) // see LoadPolicy.HalfRateBroadcast
+	}
+	return r.state.Tick%uint32(n) == 0
+}
+
+// snapshotRate is the states per second ep was granted, for GameStart.
+func (r *Room) snapshotRate(ep PlayerEndpoint) uint8 {
+	if ep == nil {
+		return 0
+	}
+	return uint8(r.rules.TickRate / r.stateInterval(ep))
+}
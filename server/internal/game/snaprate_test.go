@@ -0,0 +1,117 @@
+package game
+
+import (
+	"slices"
+	"testing"
+)
+
+// limitedEndpoint is a client that asked for hz states per second.
+type limitedEndpoint struct {
+	*MemEndpoint
+	hz int
+}
+
+func (l limitedEndpoint) SnapshotRate() int { return l.hz }
+
+func TestSnapshotInterval(t *testing.T) {
+	cases := []struct{ tickRate, hz, want int }{
+		{60, 60, 1},
+		{60, 100, 1},
+		{60, 30, 2},
+		{60, 25, 3}, // 30 is too many; 20 is the next divisor
+		{60, 10, 6},
+		{60, 1, 60},
+		{120, 30, 4},
+		{120, 60, 2},
+		{30, 20, 2},
+	}
+	for _, c := range cases {
+		if got := snapshotInterval(c.tickRate, c.hz); got != c.want {
+			t.Errorf("snapshotInterval(%d, %d) = %d, want %d", c.tickRate, c.hz, got, c.want)
+		}
+	}
+}
+
+// rateRoom is a room at tickRate sending at most snapRate states a second.
+func rateRoom(tickRate, snapRate int) *Room {
+	r := newRoom([2]string{"A", "B"}, 1)
+	rules := ClassicRules()
+	rules.TickRate = tickRate
+	r.SetRules(rules)
+	r.SetSnapshotRate(snapRate)
+	return r
+}
+
+func TestSnapshotRate(t *testing.T) {
+	mem := NewMemEndpoint("a", "A", 1)
+	cases := []struct {
+		name               string
+		tickRate, snapRate int
+		ep                 PlayerEndpoint
+		want               uint8
+	}{
+		{"every tick", 60, 0, mem, 60},
+		{"room cap", 60, 30, mem, 30},
+		{"client asks for fewer", 60, 0, limitedEndpoint{mem, 20}, 20},
+		{"client below the floor", 60, 0, limitedEndpoint{mem, 1}, MinSnapshotRate},
+		{"client asks for more than the room", 60, 30, limitedEndpoint{mem, 60}, 30},
+		{"faster room", 120, 0, limitedEndpoint{mem, 30}, 30},
+		{"faster room, uneven ask", 120, 0, limitedEndpoint{mem, 50}, 40},
+		{"no endpoint", 60, 0, nil, 0},
+	}
+	for _, c := range cases {
+		r := rateRoom(c.tickRate, c.snapRate)
+		if got := r.snapshotRate(c.ep); got != c.want {
+			t.Errorf("%s: snapshotRate = %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+// dueTicks returns which of the room's next n ticks send their state to ep.
+func dueTicks(r *Room, ep PlayerEndpoint, n int) []uint32 {
+	var due []uint32
+	for tick := range uint32(n) {
+		r.state.Tick = tick
+		if r.stateDue(ep) {
+			due = append(due, tick)
+		}
+	}
+	return due
+}
+
+func TestStateDue(t *testing.T) {
+	mem := NewMemEndpoint("a", "A", 1)
+	r := rateRoom(120, 0)
+	r.state.Phase = PhasePlaying
+
+	if got := dueTicks(r, mem, 4); len(got) != 4 {
+		t.Fatalf("uncapped room sends on ticks %v, want every tick", got)
+	}
+	slow := limitedEndpoint{mem, 30}
+	if got := dueTicks(r, slow, 9); !slices.Equal(got, []uint32{0, 4, 8}) {
+		t.Fatalf("30 Hz client at 120 Hz gets ticks %v, want every 4th", got)
+	}
+	if got := dueTicks(r, slow, r.keyframeInterval()+1); got[len(got)-1] != uint32(r.keyframeInterval()) {
+		t.Fatalf("30 Hz client misses the keyframe tick; got %v", got)
+	}
+
+	r.halfRate = true
+	if got := dueTicks(r, mem, 4); !slices.Equal(got, []uint32{0, 2}) {
+		t.Fatalf("half rate sends on ticks %v, want every other", got)
+	}
+	if got := dueTicks(r, slow, 5); !slices.Equal(got, []uint32{0, 4}) {
+		t.Fatalf("half rate sends a 30 Hz client ticks %v; it should not slow it further", got)
+	}
+	r.halfRate = false
+
+	r.urgent = true
+	if got := dueTicks(r, slow, 4); len(got) != 4 {
+		t.Fatalf("urgent ticks %v skipped; an event goes to everyone", got)
+	}
+	r.urgent = false
+
+	r.state.Phase = PhaseGameOver
+	if got := dueTicks(r, slow, 4); len(got) != 4 {
+		t.Fatalf("game over ticks %v skipped", got)
+	}
+}
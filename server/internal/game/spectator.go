@@ -0,0 +1,159 @@
+package game
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/vladimirvolkov/basketball/server/internal/ws"
+)
+
+// maxSpectators caps read-only viewers per room so one popular game
+// can't exhaust the send path.
+const maxSpectators = 64
+
+var (
+	ErrRoomNotFound = errors.New("room not found")
+	ErrRoomFull     = errors.New("too many spectators")
+)
+
+// RoomInfo is the JSON-serializable /rooms listing row.
+type RoomInfo struct {
+	ID           string    `json:"id"`
+	Names        [2]string `json:"names"`
+	Score        [2]uint8  `json:"score"`
+	Phase        GamePhase `json:"phase"`
+	GameClock    float32   `json:"gameClock"`
+	IsTournament bool      `json:"isTournament,omitempty"`
+	Spectators   int       `json:"spectators"`
+}
+
+// spectators holds the read-only endpoints attached to a room.
+// Written from spectator goroutines, read by the tick.
+type spectators struct {
+	mu    sync.Mutex
+	conns []PlayerEndpoint
+}
+
+func (sp *spectators) add(c PlayerEndpoint) bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if len(sp.conns) >= maxSpectators {
+		return false
+	}
+	sp.conns = append(sp.conns, c)
+	return true
+}
+
+func (sp *spectators) remove(c PlayerEndpoint) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	for i, s := range sp.conns {
+		if s == c {
+			sp.conns = append(sp.conns[:i], sp.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+func (sp *spectators) each(fn func(c PlayerEndpoint)) {
+	sp.mu.Lock()
+	for _, c := range sp.conns {
+		fn(c)
+	}
+	sp.mu.Unlock()
+}
+
+func (sp *spectators) broadcast(b *ws.Broadcast) {
+	sp.mu.Lock()
+	for _, c := range sp.conns {
+		c.SendShared(b)
+	}
+	sp.mu.Unlock()
+}
+
+func (sp *spectators) count() int {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return len(sp.conns)
+}
+
+// AddSpectator attaches a read-only endpoint to a running room. The
+// spectator gets the GameStart names and then the same state bytes as the
+// players; anything it sends is ignored. The endpoint is closed when the
+// room ends.
+func (r *Room) AddSpectator(conn PlayerEndpoint) error {
+	if r.finished.Load() {
+		return ErrRoomNotFound
+	}
+	if !r.spectators.add(conn) {
+		return ErrRoomFull
+	}
+	if s, ok := conn.(StreamEndpoint); ok {
+		s.ResetStream() // first state must be a keyframe, even after game over
+	}
+
+	msg := ws.NewMessage(ws.MsgGameStart, 0, ws.GameStartPayload{
+		PlayerIndex:  0,
+		Names:        r.nicknames,
+		IsTournament: r.tournament != nil,
+		Seed:         r.seed,
+		Spectator:    true,
+		Rules:        r.rules.Name,
+		SnapshotRate: r.snapshotRate(conn),
+		TickRate:     uint8(r.rules.TickRate),
+	})
+	conn.Send(msg)
+
+	go r.spectatorLoop(conn)
+	return nil
+}
+
+func (r *Room) spectatorLoop(conn PlayerEndpoint) {
+	// Read only to notice the spectator leaving; input is never applied.
+	msgs := conn.Messages()
+	for {
+		select {
+		case _, ok := <-msgs:
+			if !ok {
+				r.spectators.remove(conn)
+				return
+			}
+		case <-r.done:
+			r.spectators.remove(conn)
+			conn.Close()
+			return
+		}
+	}
+}
+
+// Info returns a summary of the room for the /rooms listing.
+// Safe to call from any goroutine.
+func (r *Room) Info() RoomInfo {
+	r.summaryMu.Lock()
+	sum := r.summary
+	r.summaryMu.Unlock()
+	return RoomInfo{
+		ID:           r.id,
+		Names:        r.nicknames,
+		Score:        sum.Score,
+		Phase:        sum.Phase,
+		GameClock:    sum.GameClock,
+		IsTournament: r.tournament != nil,
+		Spectators:   r.spectators.count(),
+	}
+}
+
+// roomSummary is the slice of GameState other goroutines may read.
+type roomSummary struct {
+	Phase     GamePhase
+	Score     [2]uint8
+	GameClock float32
+}
+
+// publishSummary copies the listing fields out of the tick-owned state.
+func (r *Room) publishSummary() {
+	s := &r.state
+	r.summaryMu.Lock()
+	r.summary = roomSummary{Phase: s.Phase, Score: s.Score, GameClock: s.GameClock}
+	r.summaryMu.Unlock()
+}
@@ -2,14 +2,12 @@ package game
 
 // Physics & court constants
 const (
-	TickRate   = 60
-	DT         = 1.0 / float32(TickRate)
-	Gravity    = float32(1800.0)
-	PlayerSpeedWithBall = float32(300.0)
-	DefenderSpeedBoost  = float32(350.0)
-	JumpVelocity         = float32(-780.0)
-	DefenderJumpVelocity = float32(-880.0)
-	AirControlMult       = float32(0.5)
+	// DefaultTickRate is the simulation rate of the classic rules. A room
+	// runs at its rules' TickRate, between MinTickRate and MaxTickRate;
+	// everything counted in ticks is worked out from it (see Rules.ticks).
+	DefaultTickRate = 60
+	MinTickRate     = 20
+	MaxTickRate     = 240 // timers fit in a uint8: a second of ticks, at most
 
 	CourtWidth  = float32(960)
 	CourtHeight = float32(450)
@@ -26,32 +24,13 @@ const (
 	RimWidth   = float32(48)
 	BackboardHeight = float32(80)
 
-	RestitutionRim       = float32(0.6)
-	RestitutionBackboard = float32(0.4)
-	RestitutionFloor     = float32(0.5)
-
-	MaxShootForce = float32(1200.0)
-	MinShootForce = float32(300.0)
-
-	ShotClockSecs = float32(24)
-	GameDuration  = float32(120)
-
 	CountdownSecs   = float32(3)
 	ScoredPauseSecs = float32(2)
-
-	// Phase 8: Defense mechanics
-	BlockRange        = float32(50)
-	DeflectSpeedMult  = float32(0.5)
-
-	// Phase 12: Steal mechanic
-	StealRange         = float32(40)  // proximity for steal attempt
-	StealChance        = 0.5          // 50% success probability
-	StealCooldownTicks = uint8(30)    // 0.5 sec cooldown (30 ticks at 60Hz)
-
-	// 3-point line: distance from hoop center
-	ThreePointRadius = float32(150)
 )
 
+// Gameplay values (clocks, speeds, steals, the 3-point line...) are per room;
+// see Rules.
+
 type GamePhase uint8
 
 const (
@@ -60,8 +39,24 @@ const (
 	PhasePlaying
 	PhaseScored
 	PhaseGameOver
+	// Variants of PhasePlaying for the ends of a game (see Rules): a basket
+	// can win it, the target is reached but the lead is under two, and
+	// sudden-death overtime after a tied regulation.
+	PhaseGamePoint
+	PhaseWinByTwo
+	PhaseOvertime
 )
 
+// Live reports whether the ball is in play: PhasePlaying or one of its
+// end-of-game variants.
+func (p GamePhase) Live() bool {
+	switch p {
+	case PhasePlaying, PhaseGamePoint, PhaseWinByTwo, PhaseOvertime:
+		return true
+	}
+	return false
+}
+
 type AnimState uint8
 
 const (
@@ -109,11 +104,15 @@ type GameState struct {
 	ShotClock  float32        `json:"shotClock"`
 	GameClock  float32        `json:"gameClock"`
 	Winner     int8           `json:"winner"` // -1=tie, 0=p1, 1=p2 (only set in GameOver)
+	InputSeq   [2]uint32      `json:"inputSeq"` // last input Seq consumed per player (0 = unsequenced)
 }
 
 type PlayerInput struct {
 	MoveX int8   `json:"moveX"`
 	Jump  bool   `json:"jump"`
 	Shoot bool   `json:"shoot"`
-	Tick  uint32 `json:"tick"`
+	Tick  uint32 `json:"tick"` // server tick the client was displaying
+	Seq   uint32 `json:"seq"`  // client sequence number, increasing per input
+
+	rewind uint8 // ticks Tick was behind when taken, capped; see lagcomp.go
 }
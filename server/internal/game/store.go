@@ -0,0 +1,263 @@
+package game
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TournamentStore persists tournament results so the leaderboard survives restarts.
+// Tournament loads a snapshot once at startup and writes every result through.
+type TournamentStore interface {
+	// Load returns the full persisted tournament state.
+	Load() (TournamentSnapshot, error)
+	// Append persists the outcome of a single game.
+	Append(u ResultUpdate) error
+	Close() error
+}
+
+// TournamentSnapshot is the complete persisted tournament state.
+type TournamentSnapshot struct {
+	Stats    map[string]PlayerStats    `json:"stats"`
+	Pairings map[string]map[string]int `json:"pairings"`
+}
+
+// ResultUpdate is what one RecordResult call writes through to the store:
+// both players' stats after the game. Applying it also counts one more
+// pairing between the two nicknames.
+type ResultUpdate struct {
+	Players [2]PlayerStats `json:"players"`
+	At      time.Time      `json:"at"`
+}
+
+func newSnapshot() TournamentSnapshot {
+	return TournamentSnapshot{
+		Stats:    make(map[string]PlayerStats),
+		Pairings: make(map[string]map[string]int),
+	}
+}
+
+// apply folds a single result into the snapshot.
+func (s *TournamentSnapshot) apply(u ResultUpdate) {
+	a, b := u.Players[0].Nickname, u.Players[1].Nickname
+	s.Stats[a] = u.Players[0]
+	s.Stats[b] = u.Players[1]
+	if s.Pairings[a] == nil {
+		s.Pairings[a] = make(map[string]int)
+	}
+	if s.Pairings[b] == nil {
+		s.Pairings[b] = make(map[string]int)
+	}
+	s.Pairings[a][b]++
+	s.Pairings[b][a]++
+}
+
+const (
+	snapshotFile = "tournament.snapshot.json"
+	journalFile  = "tournament.journal.jsonl"
+)
+
+// FileStore is a TournamentStore backed by an append-only JSON-lines journal
+// plus a periodic snapshot. Every snapshotEvery appends the full state is
+// written to the snapshot file (tmp + rename) and the journal is truncated,
+// so startup replays at most snapshotEvery lines.
+type FileStore struct {
+	mu            sync.Mutex
+	dir           string
+	journal       *os.File
+	state         TournamentSnapshot // mirrors what is on disk, used to write snapshots
+	pending       int                // journal entries since last snapshot
+	snapshotEvery int
+}
+
+// OpenFileStore opens (or creates) a file store in dir.
+// snapshotEvery <= 0 defaults to 100 results per snapshot.
+func OpenFileStore(dir string, snapshotEvery int) (*FileStore, error) {
+	if snapshotEvery <= 0 {
+		snapshotEvery = 100
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create store dir: %w", err)
+	}
+	fs := &FileStore{
+		dir:           dir,
+		state:         newSnapshot(),
+		snapshotEvery: snapshotEvery,
+	}
+	if err := fs.readSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := fs.replayJournal(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	fs.journal = f
+	return fs, nil
+}
+
+func (fs *FileStore) readSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(fs.dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+	snap := newSnapshot()
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	if snap.Stats == nil {
+		snap.Stats = make(map[string]PlayerStats)
+	}
+	if snap.Pairings == nil {
+		snap.Pairings = make(map[string]map[string]int)
+	}
+	fs.state = snap
+	return nil
+}
+
+func (fs *FileStore) replayJournal() error {
+	f, err := os.Open(filepath.Join(fs.dir, journalFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open journal: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	line := 0
+	for sc.Scan() {
+		line++
+		var u ResultUpdate
+		if err := json.Unmarshal(sc.Bytes(), &u); err != nil {
+			// A torn final write after a crash is expected — keep what we have.
+			slog.Warn("tournament journal: skipping bad line", "line", line, "err", err)
+			continue
+		}
+		fs.state.apply(u)
+		fs.pending++
+	}
+	return sc.Err()
+}
+
+// Load returns a copy of the state rebuilt from snapshot + journal.
+func (fs *FileStore) Load() (TournamentSnapshot, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	out := newSnapshot()
+	for k, v := range fs.state.Stats {
+		out.Stats[k] = v
+	}
+	for a, m := range fs.state.Pairings {
+		cp := make(map[string]int, len(m))
+		for b, n := range m {
+			cp[b] = n
+		}
+		out.Pairings[a] = cp
+	}
+	return out, nil
+}
+
+// Append writes one journal line and snapshots when the journal grows too long.
+func (fs *FileStore) Append(u ResultUpdate) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	line, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := fs.journal.Write(line); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	fs.state.apply(u)
+	fs.pending++
+
+	if fs.pending >= fs.snapshotEvery {
+		return fs.snapshotLocked()
+	}
+	return nil
+}
+
+// snapshotLocked writes the full state atomically and truncates the journal.
+// Caller must hold fs.mu.
+func (fs *FileStore) snapshotLocked() error {
+	data, err := json.Marshal(fs.state)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(fs.dir, snapshotFile+".tmp")
+	if err := writeFileSync(tmp, data); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(fs.dir, snapshotFile)); err != nil {
+		return fmt.Errorf("rename snapshot: %w", err)
+	}
+	if err := syncDir(fs.dir); err != nil {
+		return fmt.Errorf("sync store dir: %w", err)
+	}
+	// Snapshot is durable — the journal entries it covers can go.
+	if err := fs.journal.Truncate(0); err != nil {
+		return fmt.Errorf("truncate journal: %w", err)
+	}
+	fs.pending = 0
+	return nil
+}
+
+// writeFileSync writes data to path and flushes it to disk.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir flushes dir's entries, making a rename inside it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Close writes a final snapshot and closes the journal.
+func (fs *FileStore) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var err error
+	if fs.pending > 0 {
+		err = fs.snapshotLocked()
+	}
+	if cerr := fs.journal.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package game
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	_ "modernc.org/sqlite" // pure-Go SQLite driver, keeps CGO_ENABLED=0 builds working
+)
+
+// SQLStore is a TournamentStore backed by an embedded SQLite database.
+// Player stats are kept as a JSON column so new PlayerStats fields don't
+// need a schema migration.
+type SQLStore struct {
+	db *sql.DB
+}
+
+const sqlSchema = `
+CREATE TABLE IF NOT EXISTS player_stats (
+	nickname TEXT PRIMARY KEY,
+	stats    TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS pairings (
+	a     TEXT NOT NULL,
+	b     TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	PRIMARY KEY (a, b)
+);
+CREATE TABLE IF NOT EXISTS results (
+	id     INTEGER PRIMARY KEY AUTOINCREMENT,
+	at     TEXT NOT NULL,
+	update_json TEXT NOT NULL
+);`
+
+// OpenSQLStore opens (or creates) a SQLite database at path.
+func OpenSQLStore(path string) (*SQLStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	// SQLite allows a single writer; serialize through one connection.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`PRAGMA journal_mode=WAL; PRAGMA synchronous=NORMAL;`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("configure sqlite: %w", err)
+	}
+	if _, err := db.Exec(sqlSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Load reads all player stats and pairings.
+func (s *SQLStore) Load() (TournamentSnapshot, error) {
+	snap := newSnapshot()
+
+	rows, err := s.db.Query(`SELECT nickname, stats FROM player_stats`)
+	if err != nil {
+		return snap, fmt.Errorf("load stats: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var nick, raw string
+		if err := rows.Scan(&nick, &raw); err != nil {
+			return snap, err
+		}
+		var ps PlayerStats
+		if err := json.Unmarshal([]byte(raw), &ps); err != nil {
+			return snap, fmt.Errorf("decode stats for %q: %w", nick, err)
+		}
+		snap.Stats[nick] = ps
+	}
+	if err := rows.Err(); err != nil {
+		return snap, err
+	}
+
+	prow, err := s.db.Query(`SELECT a, b, count FROM pairings`)
+	if err != nil {
+		return snap, fmt.Errorf("load pairings: %w", err)
+	}
+	defer prow.Close()
+	for prow.Next() {
+		var a, b string
+		var n int
+		if err := prow.Scan(&a, &b, &n); err != nil {
+			return snap, err
+		}
+		if snap.Pairings[a] == nil {
+			snap.Pairings[a] = make(map[string]int)
+		}
+		snap.Pairings[a][b] = n
+	}
+	return snap, prow.Err()
+}
+
+// Append stores one result in a single transaction.
+func (s *SQLStore) Append(u ResultUpdate) error {
+	raw, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO results (at, update_json) VALUES (?, ?)`,
+		u.At.UTC().Format("2006-01-02T15:04:05.000Z"), string(raw)); err != nil {
+		return fmt.Errorf("insert result: %w", err)
+	}
+	for _, ps := range u.Players {
+		data, err := json.Marshal(ps)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO player_stats (nickname, stats) VALUES (?, ?)
+			ON CONFLICT(nickname) DO UPDATE SET stats = excluded.stats`, ps.Nickname, string(data)); err != nil {
+			return fmt.Errorf("upsert stats: %w", err)
+		}
+	}
+	a, b := u.Players[0].Nickname, u.Players[1].Nickname
+	for _, pair := range [2][2]string{{a, b}, {b, a}} {
+		if _, err := tx.Exec(`INSERT INTO pairings (a, b, count) VALUES (?, ?, 1)
+			ON CONFLICT(a, b) DO UPDATE SET count = count + 1`, pair[0], pair[1]); err != nil {
+			return fmt.Errorf("upsert pairing: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,166 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// sampleResults records a few games between three players and returns the
+// updates the Tournament wrote through to its store.
+func sampleResults(t *testing.T) []ResultUpdate {
+	t.Helper()
+	store := &memStore{}
+	tour, err := NewTournament(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tour.RecordResult("Alice", "Bob", 11, 7)
+	tour.RecordResult("Bob", "Carol", 5, 5)
+	tour.RecordForfeit("Carol", "Alice", 3, 6)
+	tour.RecordResult("Alice", "Bob", 2, 9)
+	if err := tour.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return store.updates
+}
+
+// applyAll folds updates into a fresh snapshot, as a store should.
+func applyAll(updates []ResultUpdate) TournamentSnapshot {
+	snap := newSnapshot()
+	for _, u := range updates {
+		snap.apply(u)
+	}
+	return snap
+}
+
+func mustLoad(t *testing.T, s TournamentStore) TournamentSnapshot {
+	t.Helper()
+	snap, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snap
+}
+
+func TestFileStoreReopens(t *testing.T) {
+	dir := t.TempDir()
+	updates := sampleResults(t)
+	// Snapshot after three results, so the fourth is left in the journal.
+	fs, err := OpenFileStore(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range updates {
+		if err := fs.Append(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := applyAll(updates)
+	if got := mustLoad(t, fs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("live store\n got %+v\nwant %+v", got, want)
+	}
+	// Reopen without Close, as after a crash: snapshot plus journal.
+	fs.journal.Close()
+	reopened, err := OpenFileStore(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got := mustLoad(t, reopened); !reflect.DeepEqual(got, want) {
+		t.Fatalf("reopened store\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestFileStoreIgnoresTornJournalLine(t *testing.T) {
+	dir := t.TempDir()
+	updates := sampleResults(t)
+	fs, err := OpenFileStore(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range updates[:2] {
+		if err := fs.Append(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs.journal.Close()
+	// A crash halfway through writing the third line.
+	f, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"players":[{"nickname":"Car`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	reopened, err := OpenFileStore(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got, want := mustLoad(t, reopened), applyAll(updates[:2]); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant the two whole results %+v", got, want)
+	}
+}
+
+func TestFileStoreSnapshotTruncatesJournal(t *testing.T) {
+	dir := t.TempDir()
+	updates := sampleResults(t)
+	fs, err := OpenFileStore(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	journalSize := func() int64 {
+		st, err := os.Stat(filepath.Join(dir, journalFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st.Size()
+	}
+
+	if err := fs.Append(updates[0]); err != nil {
+		t.Fatal(err)
+	}
+	if journalSize() == 0 {
+		t.Fatal("first result not journaled")
+	}
+	if err := fs.Append(updates[1]); err != nil {
+		t.Fatal(err)
+	}
+	if n := journalSize(); n != 0 {
+		t.Fatalf("journal is %d bytes after the snapshot, want 0", n)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotFile)); err != nil {
+		t.Fatalf("no snapshot: %v", err)
+	}
+}
+
+func TestSQLStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tournament.db")
+	updates := sampleResults(t)
+	s, err := OpenSQLStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range updates {
+		if err := s.Append(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenSQLStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got, want := mustLoad(t, reopened), applyAll(updates); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+}
@@ -1,8 +1,12 @@
 package game
 
 import (
+	"fmt"
+	"log/slog"
+	"math"
 	"sort"
 	"sync"
+	"time"
 )
 
 // PlayerStats tracks a single player's tournament performance.
@@ -14,6 +18,12 @@ type PlayerStats struct {
 	PointsFor     int    `json:"pointsFor"`
 	PointsAgainst int    `json:"pointsAgainst"`
 	GamesPlayed   int    `json:"gamesPlayed"`
+	Forfeits      int    `json:"forfeits"` // games lost by abandoning them (included in Losses)
+
+	// Glicko-2 rating; see rating.go.
+	Rating          float64 `json:"rating"`
+	RatingDeviation float64 `json:"ratingDeviation"`
+	Volatility      float64 `json:"volatility"`
 }
 
 // LeaderboardEntry is the JSON-serializable leaderboard row.
@@ -25,37 +35,181 @@ type LeaderboardEntry struct {
 	PointsFor     int    `json:"pointsFor"`
 	PointsAgainst int    `json:"pointsAgainst"`
 	GamesPlayed   int    `json:"gamesPlayed"`
+	Forfeits      int    `json:"forfeits"`
+
+	Rating          float64 `json:"rating"`
+	RatingDeviation float64 `json:"ratingDeviation"`
 }
 
-// Tournament holds all in-memory tournament state.
+// DefaultForfeitScore is the score recorded for a forfeit when the player
+// who stayed wasn't already ahead (the FIBA 20–0).
+var DefaultForfeitScore = [2]uint8{20, 0}
+
+// resultQueueLen is how many results may wait for the store before
+// recording a result blocks.
+const resultQueueLen = 1024
+
+// Tournament holds tournament state in memory and writes results through
+// to an optional TournamentStore.
+//
+// Results are recorded from the engine's tick, so they are handed to a single
+// writer goroutine rather than written under mu: a slow disk delays the
+// store, not the game or the leaderboard. Each result is numbered under mu
+// and queued after it is released, so a full queue holds up only the game
+// recording the result; writeLoop puts them back in order.
 type Tournament struct {
 	mu       sync.RWMutex
 	stats    map[string]*PlayerStats
 	pairings map[string]map[string]int // pairings[a][b] = times played
+	store    TournamentStore           // nil = in-memory only
+	writes   chan queuedResult         // to writeLoop; nil without a store
+	written  chan struct{}             // closed when writeLoop has drained writes
+	queued   uint64                    // results numbered so far, under mu
+	sending  sync.WaitGroup            // records between numbering and queueing
+
+	forfeitScore [2]uint8 // winner, loser
 }
 
-func NewTournament() *Tournament {
-	return &Tournament{
+// NewTournament creates a tournament and rebuilds its state from store.
+// A nil store keeps everything in memory.
+func NewTournament(store TournamentStore) (*Tournament, error) {
+	t := &Tournament{
 		stats:    make(map[string]*PlayerStats),
 		pairings: make(map[string]map[string]int),
+		store:    store,
+
+		forfeitScore: DefaultForfeitScore,
+	}
+	if store == nil {
+		return t, nil
+	}
+
+	snap, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("load tournament: %w", err)
 	}
+	for nick, s := range snap.Stats {
+		s.setRating(s.rating()) // stats saved before ratings existed
+		t.stats[nick] = &s
+	}
+	for a, m := range snap.Pairings {
+		cp := make(map[string]int, len(m))
+		for b, n := range m {
+			cp[b] = n
+		}
+		t.pairings[a] = cp
+	}
+	slog.Info("tournament loaded", "players", len(t.stats))
+
+	t.writes = make(chan queuedResult, resultQueueLen)
+	t.written = make(chan struct{})
+	go t.writeLoop(t.writes)
+	return t, nil
+}
+
+// queuedResult is a result on its way to the store, numbered in the order
+// it was recorded.
+type queuedResult struct {
+	seq    uint64
+	update ResultUpdate
+}
+
+// writeLoop persists results in the order they were recorded. Two games
+// ending together may queue theirs out of order; the later one waits here
+// for the earlier.
+func (t *Tournament) writeLoop(writes <-chan queuedResult) {
+	defer close(t.written)
+	var next uint64
+	early := make(map[uint64]ResultUpdate)
+	for q := range writes {
+		early[q.seq] = q.update
+		for {
+			u, ok := early[next]
+			if !ok {
+				break
+			}
+			delete(early, next)
+			next++
+			if err := t.store.Append(u); err != nil {
+				slog.Error("tournament store: failed to persist result", "nick0", u.Players[0].Nickname, "nick1", u.Players[1].Nickname, "err", err)
+			}
+		}
+	}
+}
+
+// Close waits for queued results to be written and closes the store. No
+// results may be recorded after it is called.
+func (t *Tournament) Close() error {
+	if t.store == nil {
+		return nil
+	}
+	t.mu.Lock()
+	writes := t.writes
+	t.writes = nil
+	t.mu.Unlock()
+	t.sending.Wait()
+	close(writes)
+	<-t.written
+	return t.store.Close()
 }
 
 // getOrCreate returns stats for a nickname, creating if needed. Caller must hold lock.
 func (t *Tournament) getOrCreate(nickname string) *PlayerStats {
 	s, ok := t.stats[nickname]
 	if !ok {
-		s = &PlayerStats{Nickname: nickname}
+		s = newPlayerStats(nickname)
 		t.stats[nickname] = s
 	}
 	return s
 }
 
+func newPlayerStats(nickname string) *PlayerStats {
+	s := &PlayerStats{Nickname: nickname}
+	s.setRating(s.rating())
+	return s
+}
+
+// RatingChange is how much each player's rating moved in one game, in the
+// order the players were passed to RecordResult/RecordForfeit.
+type RatingChange [2]float64
+
 // RecordResult updates tournament stats after a game.
-func (t *Tournament) RecordResult(nick1, nick2 string, score1, score2 uint8) {
+func (t *Tournament) RecordResult(nick1, nick2 string, score1, score2 uint8) RatingChange {
+	winner := -1
+	if score1 > score2 {
+		winner = 0
+	} else if score2 > score1 {
+		winner = 1
+	}
+	return t.record(nick1, nick2, score1, score2, winner, false)
+}
+
+// SetForfeitScore changes the score recorded for forfeits. Call before
+// any games are played.
+func (t *Tournament) SetForfeitScore(winner, loser uint8) {
 	t.mu.Lock()
-	defer t.mu.Unlock()
+	t.forfeitScore = [2]uint8{winner, loser}
+	t.mu.Unlock()
+}
 
+// RecordForfeit records a game the loser abandoned: a win for the player
+// who stayed, a loss and a forfeit for the leaver. If the winner was ahead
+// when the loser left the actual score stands, otherwise the configured
+// forfeit score is recorded.
+func (t *Tournament) RecordForfeit(winner, loser string, winnerScore, loserScore uint8) RatingChange {
+	t.mu.RLock()
+	fs := t.forfeitScore
+	t.mu.RUnlock()
+	if winnerScore <= loserScore {
+		winnerScore, loserScore = fs[0], fs[1]
+	}
+	return t.record(winner, loser, winnerScore, loserScore, 0, true)
+}
+
+// record applies one result; winner is 0 or 1 for nick1/nick2, -1 for a
+// draw. forfeit marks the loser as having abandoned the game.
+func (t *Tournament) record(nick1, nick2 string, score1, score2 uint8, winner int, forfeit bool) RatingChange {
+	t.mu.Lock()
 	s1 := t.getOrCreate(nick1)
 	s2 := t.getOrCreate(nick2)
 
@@ -66,17 +220,31 @@ func (t *Tournament) RecordResult(nick1, nick2 string, score1, score2 uint8) {
 	s2.PointsFor += int(score2)
 	s2.PointsAgainst += int(score1)
 
-	if score1 > score2 {
+	result := 0.5 // nick1's Glicko score
+	switch winner {
+	case 0:
 		s1.Wins++
 		s2.Losses++
-	} else if score2 > score1 {
+		if forfeit {
+			s2.Forfeits++
+		}
+		result = 1
+	case 1:
 		s2.Wins++
 		s1.Losses++
-	} else {
+		result = 0
+	default:
 		s1.Draws++
 		s2.Draws++
 	}
 
+	// Both updates use the pre-game ratings.
+	r1, r2 := s1.rating(), s2.rating()
+	n1, n2 := updateRating(r1, r2, result), updateRating(r2, r1, 1-result)
+	s1.setRating(n1)
+	s2.setRating(n2)
+	change := RatingChange{n1.Rating - r1.Rating, n2.Rating - r2.Rating}
+
 	// Update pairings
 	if t.pairings[nick1] == nil {
 		t.pairings[nick1] = make(map[string]int)
@@ -86,6 +254,22 @@ func (t *Tournament) RecordResult(nick1, nick2 string, score1, score2 uint8) {
 	}
 	t.pairings[nick1][nick2]++
 	t.pairings[nick2][nick1]++
+
+	// Number the result while still holding the lock so the store sees
+	// results in the same order as memory, but wait for queue room without it.
+	q := queuedResult{seq: t.queued, update: ResultUpdate{Players: [2]PlayerStats{*s1, *s2}, At: time.Now()}}
+	t.queued++
+	writes := t.writes
+	if writes != nil {
+		t.sending.Add(1)
+		defer t.sending.Done()
+	}
+	t.mu.Unlock()
+
+	if writes != nil {
+		writes <- q
+	}
+	return change
 }
 
 // GetStats returns a copy of stats for a nickname.
@@ -94,7 +278,7 @@ func (t *Tournament) GetStats(nickname string) PlayerStats {
 	defer t.mu.RUnlock()
 	s, ok := t.stats[nickname]
 	if !ok {
-		return PlayerStats{Nickname: nickname}
+		return *newPlayerStats(nickname)
 	}
 	return *s
 }
@@ -119,6 +303,17 @@ func (t *Tournament) TimesPlayed(nick1, nick2 string) int {
 	return 0
 }
 
+// ratingOf returns a player's current rating, if they have played a
+// tournament game.
+func (t *Tournament) ratingOf(nickname string) (Rating, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if s, ok := t.stats[nickname]; ok {
+		return s.rating(), true
+	}
+	return Rating{}, false
+}
+
 // LeaderboardByWins returns top players sorted by wins desc, then points desc.
 func (t *Tournament) LeaderboardByWins(limit int) []LeaderboardEntry {
 	t.mu.RLock()
@@ -155,6 +350,25 @@ func (t *Tournament) LeaderboardByPoints(limit int) []LeaderboardEntry {
 	return entries
 }
 
+// LeaderboardByRating returns top players sorted by rating desc; ties go to
+// the player whose rating is more certain (lower deviation).
+func (t *Tournament) LeaderboardByRating(limit int) []LeaderboardEntry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	entries := t.allEntries()
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Rating != entries[j].Rating {
+			return entries[i].Rating > entries[j].Rating
+		}
+		return entries[i].RatingDeviation < entries[j].RatingDeviation
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
 // allEntries returns all stats as LeaderboardEntry slice. Caller must hold RLock.
 func (t *Tournament) allEntries() []LeaderboardEntry {
 	entries := make([]LeaderboardEntry, 0, len(t.stats))
@@ -167,6 +381,10 @@ func (t *Tournament) allEntries() []LeaderboardEntry {
 			PointsFor:     s.PointsFor,
 			PointsAgainst: s.PointsAgainst,
 			GamesPlayed:   s.GamesPlayed,
+			Forfeits:      s.Forfeits,
+
+			Rating:          math.Round(s.Rating),
+			RatingDeviation: math.Round(s.RatingDeviation),
 		})
 	}
 	return entries
@@ -0,0 +1,57 @@
+package game
+
+import (
+	"sync"
+	"testing"
+)
+
+// memStore is a TournamentStore that keeps what it was sent.
+type memStore struct {
+	mu      sync.Mutex
+	updates []ResultUpdate
+}
+
+func (m *memStore) Load() (TournamentSnapshot, error) { return newSnapshot(), nil }
+func (m *memStore) Close() error                      { return nil }
+
+func (m *memStore) Append(u ResultUpdate) error {
+	m.mu.Lock()
+	m.updates = append(m.updates, u)
+	m.mu.Unlock()
+	return nil
+}
+
+func TestConcurrentResultsReachStoreInOrder(t *testing.T) {
+	store := &memStore{}
+	tour, err := NewTournament(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const games = 200
+	var wg sync.WaitGroup
+	for i := range games {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tour.RecordResult("Alice", "Bob", uint8(i%5), 2)
+		}()
+	}
+	wg.Wait()
+	if err := tour.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.updates) != games {
+		t.Fatalf("store got %d results, want %d", len(store.updates), games)
+	}
+	// Each update carries the stats after its game, so in recording order
+	// GamesPlayed counts up by one.
+	for i, u := range store.updates {
+		if got := u.Players[0].GamesPlayed; got != i+1 {
+			t.Fatalf("result %d has Alice at %d games; results reached the store out of order", i, got)
+		}
+	}
+	if last, mem := store.updates[games-1].Players[0], tour.GetStats("Alice"); last != mem {
+		t.Fatalf("store ends on %+v, memory on %+v", last, mem)
+	}
+}
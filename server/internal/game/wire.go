@@ -0,0 +1,119 @@
+package game
+
+import (
+	"encoding/binary"
+
+	"github.com/vladimirvolkov/basketball/server/internal/ws"
+)
+
+// Binary wire forms (see ws/binary.go for the frame layout). Only fields the
+// client sees in JSON are encoded — the `json:"-"` server-side fields stay off
+// the wire here too.
+
+const (
+	playerFlagGrounded = 1 << 0
+	playerFlagHasBall  = 1 << 1
+	ballFlagInFlight   = 1 << 0
+)
+
+func (p *PlayerState) appendBinary(b []byte) []byte {
+	b = ws.AppendF32(b, p.X)
+	b = ws.AppendF32(b, p.Y)
+	b = ws.AppendF32(b, p.VX)
+	b = ws.AppendF32(b, p.VY)
+	var flags byte
+	if p.Grounded {
+		flags |= playerFlagGrounded
+	}
+	if p.HasBall {
+		flags |= playerFlagHasBall
+	}
+	return append(b, byte(p.Facing), byte(p.Anim), flags, p.StealCooldown)
+}
+
+func (p *PlayerState) readBinary(r *ws.BinaryReader) {
+	p.X, p.Y, p.VX, p.VY = r.F32(), r.F32(), r.F32(), r.F32()
+	p.Facing = r.I8()
+	p.Anim = AnimState(r.U8())
+	flags := r.U8()
+	p.Grounded = flags&playerFlagGrounded != 0
+	p.HasBall = flags&playerFlagHasBall != 0
+	p.StealCooldown = r.U8()
+}
+
+func (bs *BallState) appendBinary(b []byte) []byte {
+	b = ws.AppendF32(b, bs.X)
+	b = ws.AppendF32(b, bs.Y)
+	b = ws.AppendF32(b, bs.VX)
+	b = ws.AppendF32(b, bs.VY)
+	var flags byte
+	if bs.InFlight {
+		flags |= ballFlagInFlight
+	}
+	return append(b, byte(bs.Owner), flags)
+}
+
+func (bs *BallState) readBinary(r *ws.BinaryReader) {
+	bs.X, bs.Y, bs.VX, bs.VY = r.F32(), r.F32(), r.F32(), r.F32()
+	bs.Owner = r.I8()
+	bs.InFlight = r.U8()&ballFlagInFlight != 0
+}
+
+// AppendBinary implements encoding.BinaryAppender.
+func (s GameState) AppendBinary(b []byte) ([]byte, error) {
+	b = binary.LittleEndian.AppendUint32(b, s.Tick)
+	b = append(b, byte(s.Phase))
+	b = ws.AppendF32(b, s.PhaseTimer)
+	for i := range s.Players {
+		b = s.Players[i].appendBinary(b)
+	}
+	b = s.Ball.appendBinary(b)
+	b = append(b, s.Score[0], s.Score[1])
+	b = ws.AppendF32(b, s.ShotClock)
+	b = ws.AppendF32(b, s.GameClock)
+	b = append(b, byte(s.Winner))
+	b = binary.LittleEndian.AppendUint32(b, s.InputSeq[0])
+	return binary.LittleEndian.AppendUint32(b, s.InputSeq[1]), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (s *GameState) UnmarshalBinary(data []byte) error {
+	r := ws.NewBinaryReader(data)
+	s.Tick = r.U32()
+	s.Phase = GamePhase(r.U8())
+	s.PhaseTimer = r.F32()
+	for i := range s.Players {
+		s.Players[i].readBinary(r)
+	}
+	s.Ball.readBinary(r)
+	s.Score[0], s.Score[1] = r.U8(), r.U8()
+	s.ShotClock = r.F32()
+	s.GameClock = r.F32()
+	s.Winner = r.I8()
+	s.InputSeq[0], s.InputSeq[1] = r.U32(), r.U32()
+	return r.Err()
+}
+
+// AppendBinary implements encoding.BinaryAppender (same layout as ws.PlayerInputPayload).
+func (in PlayerInput) AppendBinary(b []byte) ([]byte, error) {
+	var flags byte
+	if in.Jump {
+		flags |= ws.InputFlagJump
+	}
+	if in.Shoot {
+		flags |= ws.InputFlagShoot
+	}
+	b = append(b, byte(in.MoveX), flags)
+	return binary.LittleEndian.AppendUint32(b, in.Seq), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (in *PlayerInput) UnmarshalBinary(data []byte) error {
+	r := ws.NewBinaryReader(data)
+	in.MoveX = r.I8()
+	flags := r.U8()
+	in.Jump = flags&ws.InputFlagJump != 0
+	in.Shoot = flags&ws.InputFlagShoot != 0
+	in.Seq = r.U32()
+	return r.Err()
+}
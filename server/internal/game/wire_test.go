@@ -0,0 +1,66 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/vladimirvolkov/basketball/server/internal/ws"
+)
+
+// sampleState is a mid-game state with every wire field populated.
+func sampleState() GameState {
+	ball := NewBall()
+	ball.X, ball.Y, ball.VX, ball.VY = 512.25, 201.5, -310.75, -95.125
+	ball.InFlight = true
+	return GameState{
+		Tick:  4321,
+		Phase: PhasePlaying,
+		Players: [2]PlayerState{
+			{X: 433.5, Y: 356, VX: 300, Facing: 1, Anim: AnimDribble, Grounded: true, StealCooldown: 12},
+			{X: 611.25, Y: 301.75, VX: -175, VY: -420.5, Facing: -1, Anim: AnimJump},
+		},
+		Ball:      ball,
+		Score:     [2]uint8{14, 11},
+		ShotClock: 17.35,
+		GameClock: 63.9,
+		Winner:    -1,
+		InputSeq:  [2]uint32{70213, 69988},
+	}
+}
+
+// benchmarkEncode measures the message every room sends both players on
+// every tick. B/frame times TickRate*2 is a room's outbound bandwidth.
+func benchmarkEncode(b *testing.B, codec ws.Codec) {
+	s := sampleState()
+	frame, err := ws.EncodeFor(codec, ws.NewMessage(ws.MsgGameState, s.Tick, s))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		ws.EncodeFor(codec, ws.NewMessage(ws.MsgGameState, s.Tick, s))
+	}
+	b.ReportMetric(float64(len(frame)), "B/frame")
+}
+
+func BenchmarkEncodeJSON(b *testing.B)   { benchmarkEncode(b, ws.CodecJSON) }
+func BenchmarkEncodeBinary(b *testing.B) { benchmarkEncode(b, ws.CodecBinary) }
+
+func TestGameStateBinaryRoundTrip(t *testing.T) {
+	want := sampleState()
+	b, err := want.AppendBinary(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got GameState
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	// Server-only ball fields stay off the wire.
+	want.Ball.PickupCooldown, want.Ball.ShooterIdx, want.Ball.ShotAgeTicks, want.Ball.ShotOriginX = 0, 0, 0, 0
+	if got != want {
+		t.Fatalf("round trip changed the state\nsent %+v\ngot  %+v", want, got)
+	}
+	if err := got.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Fatal("truncated state decoded without error")
+	}
+}
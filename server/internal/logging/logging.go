@@ -0,0 +1,69 @@
+// Package logging sets up the server's log/slog logger and keeps lines that
+// could fire every tick from flooding it.
+//
+// Loggers are built up with context as they are handed down: a connection's
+// carries its conn ID, IP and nickname, a room's its room ID and both
+// players. Play-by-play (shots, steals, scores) is logged at debug level.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// New returns a logger writing to w. level is debug, info, warn or error
+// ("" means info); format is text or json ("" means text).
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("log level %q: want debug, info, warn or error", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("log format %q: want text or json", format)
+}
+
+// Sampler lets a repeating log line through at most once per interval and
+// counts the ones it held back:
+//
+//	if n, ok := c.dropLog.Allow(); ok {
+//		c.Log.Warn("send buffer full, dropping message", "suppressed", n)
+//	}
+//
+// The zero value allows one line per second. Safe for concurrent use.
+type Sampler struct {
+	Interval time.Duration // 0 means one second
+
+	next       atomic.Int64 // unix nanos before which lines are held back
+	suppressed atomic.Uint64
+}
+
+// Allow reports whether to log now, and how many lines were held back since
+// the last one that was.
+func (s *Sampler) Allow() (suppressed uint64, ok bool) {
+	now := time.Now().UnixNano()
+	next := s.next.Load()
+	if now < next || !s.next.CompareAndSwap(next, now+int64(s.interval())) {
+		s.suppressed.Add(1)
+		return 0, false
+	}
+	return s.suppressed.Swap(0), true
+}
+
+func (s *Sampler) interval() time.Duration {
+	if s.Interval > 0 {
+		return s.Interval
+	}
+	return time.Second
+}
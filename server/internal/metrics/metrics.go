@@ -0,0 +1,332 @@
+// Package metrics is a minimal Prometheus-compatible instrumentation
+// library: counters, gauges and fixed-bucket histograms, written out in the
+// text exposition format. It covers what the server needs without the
+// client_golang dependency tree.
+//
+// Metrics are registered once, usually as package variables, and updated
+// lock-free from hot paths:
+//
+//	var dropped = metrics.NewCounter("basketball_send_dropped_total", "Messages dropped on full send buffers.")
+//	dropped.Inc()
+//
+// Optional labels are given as name/value pairs. Series of one name share a
+// HELP and TYPE and must all have the same kind.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default is the registry the package-level constructors add to and Handler
+// serves.
+var Default = NewRegistry()
+
+// Registry holds metric families in registration order.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	byName   map[string]*family
+}
+
+type family struct {
+	name, help, kind string
+	series           []series
+}
+
+// series is one labelled time series (several lines, for a histogram).
+type series interface {
+	write(w *bufio.Writer, name string)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{byName: map[string]*family{}}
+}
+
+func (r *Registry) add(name, help, kind string, s series) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.byName[name]
+	if f == nil {
+		f = &family{name: name, help: help, kind: kind}
+		r.byName[name] = f
+		r.families = append(r.families, f)
+	}
+	if f.kind != kind {
+		panic(fmt.Sprintf("metrics: %s registered as both %s and %s", name, f.kind, kind))
+	}
+	f.series = append(f.series, s)
+}
+
+// labels formats name/value pairs as {a="1",b="2"}, or "" without any.
+type labels string
+
+func makeLabels(pairs []string) labels {
+	if len(pairs)%2 != 0 {
+		panic("metrics: labels must be name/value pairs")
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return labels(b.String())
+}
+
+// with adds one more label, for a histogram's le.
+func (l labels) with(name, value string) string {
+	pair := name + `="` + value + `"`
+	if l == "" {
+		return "{" + pair + "}"
+	}
+	return string(l[:len(l)-1]) + "," + pair + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// ── Counter ──
+
+// Counter is a monotonically increasing count.
+type Counter struct {
+	labels
+	v atomic.Uint64
+}
+
+// NewCounter registers a counter in Default.
+func NewCounter(name, help string, labelPairs ...string) *Counter {
+	return Default.Counter(name, help, labelPairs...)
+}
+
+func (r *Registry) Counter(name, help string, labelPairs ...string) *Counter {
+	c := &Counter{labels: makeLabels(labelPairs)}
+	r.add(name, help, "counter", c)
+	return c
+}
+
+func (c *Counter) Inc()         { c.v.Add(1) }
+func (c *Counter) Add(n uint64) { c.v.Add(n) }
+func (c *Counter) Value() uint64 {
+	return c.v.Load()
+}
+
+func (c *Counter) write(w *bufio.Writer, name string) {
+	fmt.Fprintf(w, "%s%s %d\n", name, c.labels, c.v.Load())
+}
+
+// ── Gauge ──
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	labels
+	bits atomic.Uint64 // math.Float64bits
+}
+
+// NewGauge registers a gauge in Default.
+func NewGauge(name, help string, labelPairs ...string) *Gauge {
+	return Default.Gauge(name, help, labelPairs...)
+}
+
+func (r *Registry) Gauge(name, help string, labelPairs ...string) *Gauge {
+	g := &Gauge{labels: makeLabels(labelPairs)}
+	r.add(name, help, "gauge", g)
+	return g
+}
+
+func (g *Gauge) Set(v float64) { g.bits.Store(math.Float64bits(v)) }
+func (g *Gauge) Add(d float64) {
+	for {
+		old := g.bits.Load()
+		if g.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+d)) {
+			return
+		}
+	}
+}
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+func (g *Gauge) write(w *bufio.Writer, name string) {
+	fmt.Fprintf(w, "%s%s %s\n", name, g.labels, formatFloat(g.Value()))
+}
+
+// funcSeries reads its value at scrape time.
+type funcSeries struct {
+	labels
+	fn func() float64
+}
+
+func (f *funcSeries) write(w *bufio.Writer, name string) {
+	fmt.Fprintf(w, "%s%s %s\n", name, f.labels, formatFloat(f.fn()))
+}
+
+// NewGaugeFunc registers a gauge in Default whose value is fn() at scrape
+// time. fn must be safe to call from any goroutine.
+func NewGaugeFunc(name, help string, fn func() float64, labelPairs ...string) {
+	Default.GaugeFunc(name, help, fn, labelPairs...)
+}
+
+func (r *Registry) GaugeFunc(name, help string, fn func() float64, labelPairs ...string) {
+	r.add(name, help, "gauge", &funcSeries{labels: makeLabels(labelPairs), fn: fn})
+}
+
+// NewCounterFunc is NewGaugeFunc for a count kept elsewhere that only grows.
+func NewCounterFunc(name, help string, fn func() float64, labelPairs ...string) {
+	Default.CounterFunc(name, help, fn, labelPairs...)
+}
+
+func (r *Registry) CounterFunc(name, help string, fn func() float64, labelPairs ...string) {
+	r.add(name, help, "counter", &funcSeries{labels: makeLabels(labelPairs), fn: fn})
+}
+
+// ── Histogram ──
+
+// Histogram counts observations into fixed buckets. Bucket counts are kept
+// non-cumulative and summed at scrape time, so Observe is a bucket search
+// and two atomic adds.
+type Histogram struct {
+	labels
+	upper  []float64 // bucket upper bounds, ascending; +Inf is implicit
+	counts []atomic.Uint64
+	sum    atomic.Uint64 // math.Float64bits
+}
+
+// NewHistogram registers a histogram in Default. buckets are the upper
+// bounds, ascending.
+func NewHistogram(name, help string, buckets []float64, labelPairs ...string) *Histogram {
+	return Default.Histogram(name, help, buckets, labelPairs...)
+}
+
+func (r *Registry) Histogram(name, help string, buckets []float64, labelPairs ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: " + name + " buckets are not sorted")
+	}
+	h := &Histogram{
+		labels: makeLabels(labelPairs),
+		upper:  buckets,
+		counts: make([]atomic.Uint64, len(buckets)+1),
+	}
+	r.add(name, help, "histogram", h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.upper, v)].Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// ObserveSince records the seconds elapsed since start.
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+func (h *Histogram) write(w *bufio.Writer, name string) {
+	var cum uint64
+	for i := range h.counts {
+		cum += h.counts[i].Load()
+		le := "+Inf"
+		if i < len(h.upper) {
+			le = formatFloat(h.upper[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, h.labels.with("le", le), cum)
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, h.labels, formatFloat(math.Float64frombits(h.sum.Load())))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, h.labels, cum)
+}
+
+// ExpBuckets returns n upper bounds starting at start, each factor times the
+// last.
+func ExpBuckets(start, factor float64, n int) []float64 {
+	b := make([]float64, n)
+	for i := range b {
+		b[i] = start
+		start *= factor
+	}
+	return b
+}
+
+// ── Exposition ──
+
+// WriteTo writes every metric in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := make([]family, len(r.families))
+	for i, f := range r.families {
+		families[i] = *f
+		families[i].series = append([]series(nil), f.series...)
+	}
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, helpEscaper.Replace(f.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+		for _, s := range f.series {
+			s.write(bw, f.name)
+		}
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Handler serves Default for Prometheus to scrape.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
@@ -7,6 +7,13 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/vladimirvolkov/basketball/server/internal/metrics"
+)
+
+var (
+	connRejected = metrics.NewCounter("basketball_ratelimit_rejected_total", "Connections and messages refused by the per-IP rate limiter.", "kind", "connection")
+	msgRejected  = metrics.NewCounter("basketball_ratelimit_rejected_total", "Connections and messages refused by the per-IP rate limiter.", "kind", "message")
 )
 
 type visitor struct {
@@ -73,6 +80,7 @@ func (rl *IPRateLimiter) ConnectAllowed(ip string) bool {
 	if !ok {
 		// Reject if this shard is at capacity (prevents memory exhaustion)
 		if len(s.visitors) >= rl.maxVisitors {
+			connRejected.Inc()
 			return false
 		}
 		s.visitors[ip] = &visitor{
@@ -84,6 +92,7 @@ func (rl *IPRateLimiter) ConnectAllowed(ip string) bool {
 		return true
 	}
 	if v.connections >= rl.maxConnsPerIP {
+		connRejected.Inc()
 		return false
 	}
 	v.connections++
@@ -137,6 +146,7 @@ func (rl *IPRateLimiter) MessageAllowed(ip string) bool {
 	}
 
 	if v.tokens <= 0 {
+		msgRejected.Inc()
 		return false
 	}
 	v.tokens--
@@ -0,0 +1,393 @@
+package ws
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/vladimirvolkov/basketball/server/internal/logging"
+	"github.com/vladimirvolkov/basketball/server/internal/metrics"
+)
+
+// Codec selects the wire format of a connection.
+//
+// The browser client in client/ speaks JSON only. The binary codec, and the
+// delta snapshots that ride on it, are for clients that opt in with
+// ?codec=binary or the basketball.binary subprotocol: native and bot clients
+// and load generators, which decode frames with the Go types' UnmarshalBinary
+// or a port of the layout below.
+type Codec uint8
+
+const (
+	CodecJSON   Codec = iota // text frames, {"type","tick","payload"} — easy to debug
+	CodecBinary              // binary frames, packed little-endian
+	numCodecs
+)
+
+// Subprotocol names accepted during the WebSocket handshake.
+const (
+	SubprotocolJSON   = "basketball.json"
+	SubprotocolBinary = "basketball.binary"
+)
+
+func (c Codec) String() string {
+	if c == CodecBinary {
+		return "binary"
+	}
+	return "json"
+}
+
+// ── Binary frame layout ──
+//
+//	type:u8 tick:u32 payload...
+//
+// Payloads implement encoding.BinaryAppender (outbound) and
+// encoding.BinaryUnmarshaler (inbound). All integers and floats are
+// little-endian; bools are packed into flag bytes; strings are u8 length + bytes.
+
+const binaryHeaderLen = 5
+
+// encodeTime is how long EncodeBinary and Encode take per message, by codec.
+var (
+	encodeBuckets    = metrics.ExpBuckets(0.000001, 2, 12) // 1 µs to 2 ms
+	encodeTimeBinary = metrics.NewHistogram("basketball_encode_seconds", "Time to encode one outbound message.", encodeBuckets, "codec", "binary")
+	encodeTimeJSON   = metrics.NewHistogram("basketball_encode_seconds", "Time to encode one outbound message.", encodeBuckets, "codec", "json")
+)
+
+var binPool = sync.Pool{
+	New: func() any { b := make([]byte, 0, 256); return &b },
+}
+
+// EncodeBinary serializes a Message into a binary frame.
+func EncodeBinary(msg Message) ([]byte, error) {
+	defer encodeTimeBinary.ObserveSince(time.Now())
+	bp := binPool.Get().(*[]byte)
+	buf := append((*bp)[:0], msg.Type)
+	buf = binary.LittleEndian.AppendUint32(buf, msg.Tick)
+
+	switch {
+	case msg.body != nil:
+		a, ok := msg.body.(encoding.BinaryAppender)
+		if !ok {
+			binPool.Put(bp)
+			return nil, fmt.Errorf("message 0x%02x: %T has no binary form", msg.Type, msg.body)
+		}
+		var err error
+		if buf, err = a.AppendBinary(buf); err != nil {
+			binPool.Put(bp)
+			return nil, err
+		}
+	case msg.bin != nil:
+		buf = append(buf, msg.bin...)
+	}
+
+	out := make([]byte, len(buf))
+	copy(out, buf)
+	*bp = buf
+	binPool.Put(bp)
+	return out, nil
+}
+
+// DecodeBinary parses a binary frame. The payload stays raw until
+// Message.DecodePayload is called with the expected type.
+func DecodeBinary(data []byte) (Message, error) {
+	if len(data) < binaryHeaderLen {
+		return Message{}, errors.New("binary frame too short")
+	}
+	return Message{
+		Type: data[0],
+		Tick: binary.LittleEndian.Uint32(data[1:5]),
+		bin:  data[binaryHeaderLen:],
+	}, nil
+}
+
+// EncodeFor serializes msg in the given codec.
+func EncodeFor(codec Codec, msg Message) ([]byte, error) {
+	if codec == CodecBinary {
+		return EncodeBinary(msg)
+	}
+	return Encode(msg)
+}
+
+// Broadcast fans one message out to many connections, encoding it at most
+// once per codec no matter how many recipients share that codec.
+type Broadcast struct {
+	msg    Message
+	enc    [numCodecs][]byte
+	failed [numCodecs]bool
+}
+
+func NewBroadcast(msg Message) Broadcast {
+	return Broadcast{msg: msg}
+}
+
+// Message returns the message being fanned out, for recipients that take it
+// unencoded.
+func (b *Broadcast) Message() Message {
+	return b.msg
+}
+
+// broadcastErrLog samples encode failures, which repeat on every tick that
+// sends the same kind of message.
+var broadcastErrLog logging.Sampler
+
+// SendTo queues the message on c in c's codec.
+func (b *Broadcast) SendTo(c *Conn) {
+	codec := c.Codec
+	if b.enc[codec] == nil {
+		if b.failed[codec] {
+			return
+		}
+		data, err := EncodeFor(codec, b.msg)
+		if err != nil {
+			b.failed[codec] = true
+			if n, ok := broadcastErrLog.Allow(); ok {
+				slog.Error("broadcast encode failed", "type", b.msg.Type, "codec", codec.String(), "err", err, "suppressed", n)
+			}
+			return
+		}
+		b.enc[codec] = data
+	}
+	c.SendRaw(b.enc[codec])
+}
+
+// ── Append/read helpers for payload implementations ──
+
+func AppendF32(b []byte, v float32) []byte {
+	return binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+}
+
+func AppendString(b []byte, s string) []byte {
+	if len(s) > 255 {
+		s = s[:255]
+	}
+	b = append(b, byte(len(s)))
+	return append(b, s...)
+}
+
+// BinaryReader reads little-endian fields in order and remembers the first
+// short read, so decoders check Err once at the end.
+type BinaryReader struct {
+	buf []byte
+	err error
+}
+
+var errShortPayload = errors.New("binary payload too short")
+
+func NewBinaryReader(b []byte) *BinaryReader {
+	return &BinaryReader{buf: b}
+}
+
+func (r *BinaryReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.buf) < n {
+		r.err = errShortPayload
+		return nil
+	}
+	out := r.buf[:n]
+	r.buf = r.buf[n:]
+	return out
+}
+
+func (r *BinaryReader) U8() uint8 {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *BinaryReader) I8() int8 { return int8(r.U8()) }
+
+func (r *BinaryReader) U16() uint16 {
+	if b := r.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *BinaryReader) U32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *BinaryReader) U64() uint64 {
+	if b := r.take(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *BinaryReader) F32() float32 { return math.Float32frombits(r.U32()) }
+
+func (r *BinaryReader) Str() string {
+	n := int(r.U8())
+	if b := r.take(n); b != nil {
+		return string(b)
+	}
+	return ""
+}
+
+func (r *BinaryReader) Err() error { return r.err }
+
+// ── Payload binary forms ──
+
+// Input flag bits, shared with game.PlayerInput's binary form.
+const (
+	InputFlagJump  = 1 << 0
+	InputFlagShoot = 1 << 1
+)
+
+func (p PlayerInputPayload) AppendBinary(b []byte) ([]byte, error) {
+	var flags byte
+	if p.Jump {
+		flags |= InputFlagJump
+	}
+	if p.Shoot {
+		flags |= InputFlagShoot
+	}
+	b = append(b, byte(p.MoveX), flags)
+	return binary.LittleEndian.AppendUint32(b, p.Seq), nil
+}
+
+func (p *PlayerInputPayload) UnmarshalBinary(data []byte) error {
+	r := NewBinaryReader(data)
+	p.MoveX = r.I8()
+	flags := r.U8()
+	p.Jump = flags&InputFlagJump != 0
+	p.Shoot = flags&InputFlagShoot != 0
+	p.Seq = r.U32()
+	return r.Err()
+}
+
+func (p PingPayload) AppendBinary(b []byte) ([]byte, error) {
+	return binary.LittleEndian.AppendUint64(b, p.ClientTime), nil
+}
+
+func (p *PingPayload) UnmarshalBinary(data []byte) error {
+	r := NewBinaryReader(data)
+	p.ClientTime = r.U64()
+	return r.Err()
+}
+
+func (p JoinQueuePayload) AppendBinary(b []byte) ([]byte, error) {
+	return AppendString(b, p.Name), nil
+}
+
+func (p *JoinQueuePayload) UnmarshalBinary(data []byte) error {
+	r := NewBinaryReader(data)
+	p.Name = r.Str()
+	return r.Err()
+}
+
+func (p StateAckPayload) AppendBinary(b []byte) ([]byte, error) {
+	return binary.LittleEndian.AppendUint32(b, p.Tick), nil
+}
+
+func (p *StateAckPayload) UnmarshalBinary(data []byte) error {
+	r := NewBinaryReader(data)
+	p.Tick = r.U32()
+	return r.Err()
+}
+
+func (p SnapshotRatePayload) AppendBinary(b []byte) ([]byte, error) {
+	return append(b, p.Rate), nil
+}
+
+func (p *SnapshotRatePayload) UnmarshalBinary(data []byte) error {
+	r := NewBinaryReader(data)
+	p.Rate = r.U8()
+	return r.Err()
+}
+
+func (p PongPayload) AppendBinary(b []byte) ([]byte, error) {
+	b = binary.LittleEndian.AppendUint64(b, p.ClientTime)
+	return binary.LittleEndian.AppendUint64(b, p.ServerTime), nil
+}
+
+func (p GameStartPayload) AppendBinary(b []byte) ([]byte, error) {
+	b = append(b, p.PlayerIndex)
+	b = AppendString(b, p.Names[0])
+	b = AppendString(b, p.Names[1])
+	var flags byte
+	if p.IsTournament {
+		flags |= 1 << 0
+	}
+	if p.Spectator {
+		flags |= 1 << 1
+	}
+	b = append(b, flags)
+	b = binary.LittleEndian.AppendUint64(b, uint64(p.Seed))
+	b = AppendString(b, p.ResumeToken)
+	b = AppendString(b, p.Rules)
+	return append(b, p.SnapshotRate, p.TickRate), nil
+}
+
+func (p ScoredPayload) AppendBinary(b []byte) ([]byte, error) {
+	return append(b, p.ScorerIndex, p.Points, p.NewScore[0], p.NewScore[1]), nil
+}
+
+func (p GameOverPayload) AppendBinary(b []byte) ([]byte, error) {
+	var flags byte
+	if p.Forfeit {
+		flags |= 1 << 0
+	}
+	b = append(b, byte(p.Winner), p.Score[0], p.Score[1], flags)
+	return AppendString(b, p.Reason), nil
+}
+
+func (p PlayerDisconnectedPayload) AppendBinary(b []byte) ([]byte, error) {
+	return append(b, p.PlayerIndex), nil
+}
+
+func (p MatchPausedPayload) AppendBinary(b []byte) ([]byte, error) {
+	return AppendF32(append(b, p.PlayerIndex), p.Grace), nil
+}
+
+func (p MatchResumedPayload) AppendBinary(b []byte) ([]byte, error) {
+	return append(b, p.PlayerIndex), nil
+}
+
+func (p RematchOfferPayload) AppendBinary(b []byte) ([]byte, error) {
+	return append(b, p.PlayerIndex), nil
+}
+
+func (p QueueStatusPayload) AppendBinary(b []byte) ([]byte, error) {
+	for _, v := range [...]uint16{p.Position, p.QueueSize, p.Elapsed, p.EstimatedWait} {
+		b = binary.LittleEndian.AppendUint16(b, v)
+	}
+	var flags byte
+	if p.Fallback {
+		flags |= 1 << 0
+	}
+	return append(b, flags), nil
+}
+
+func (p RoomCodePayload) AppendBinary(b []byte) ([]byte, error) {
+	b = AppendString(b, p.Code)
+	return binary.LittleEndian.AppendUint16(b, p.ExpiresIn), nil
+}
+
+func (s TournamentPlayerStats) AppendBinary(b []byte) ([]byte, error) {
+	b = AppendString(b, s.Nickname)
+	for _, v := range [...]int{s.Wins, s.Losses, s.Draws, s.PointsFor, s.GamesPlayed, s.Forfeits} {
+		b = binary.LittleEndian.AppendUint32(b, uint32(v))
+	}
+	b = AppendF32(b, s.Rating)
+	return AppendF32(b, s.RatingDev), nil
+}
+
+func (p TournamentResultPayload) AppendBinary(b []byte) ([]byte, error) {
+	b, _ = p.YourStats.AppendBinary(b)
+	b, _ = p.OpponentStats.AppendBinary(b)
+	b = AppendF32(b, p.YourRatingChange)
+	return AppendF32(b, p.OpponentRatingChange), nil
+}
@@ -0,0 +1,87 @@
+package ws
+
+import "testing"
+
+// The per-tick GameState benchmarks live in game/wire_test.go; these cover
+// the messages ws encodes and decodes itself.
+
+var sampleStart = GameStartPayload{
+	PlayerIndex:  1,
+	Names:        [2]string{"Alice", "Bob"},
+	IsTournament: true,
+	Seed:         -8512034417,
+	ResumeToken:  "b3c1f0a9d2e84c67",
+	Rules:        "classic",
+	SnapshotRate: 30,
+	TickRate:     60,
+}
+
+var sampleInput = PlayerInputPayload{MoveX: -1, Jump: true, Seq: 70213}
+
+func benchmarkEncode(b *testing.B, codec Codec) {
+	msg := NewMessage(MsgGameStart, 4321, sampleStart)
+	frame, err := EncodeFor(codec, msg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		EncodeFor(codec, msg)
+	}
+	b.ReportMetric(float64(len(frame)), "B/frame")
+}
+
+func BenchmarkEncodeJSON(b *testing.B)   { benchmarkEncode(b, CodecJSON) }
+func BenchmarkEncodeBinary(b *testing.B) { benchmarkEncode(b, CodecBinary) }
+
+func benchmarkDecodeInput(b *testing.B, codec Codec) {
+	frame, err := EncodeFor(codec, NewMessage(MsgPlayerInput, 1, sampleInput))
+	if err != nil {
+		b.Fatal(err)
+	}
+	decode := Decode
+	if codec == CodecBinary {
+		decode = DecodeBinary
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		msg, _ := decode(frame)
+		var in PlayerInputPayload
+		msg.DecodePayload(&in)
+	}
+	b.ReportMetric(float64(len(frame)), "B/frame")
+}
+
+func BenchmarkDecodeInputJSON(b *testing.B)   { benchmarkDecodeInput(b, CodecJSON) }
+func BenchmarkDecodeInputBinary(b *testing.B) { benchmarkDecodeInput(b, CodecBinary) }
+
+func TestInputRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{CodecJSON, CodecBinary} {
+		frame, err := EncodeFor(codec, NewMessage(MsgPlayerInput, 9, sampleInput))
+		if err != nil {
+			t.Fatal(err)
+		}
+		decode := Decode
+		if codec == CodecBinary {
+			decode = DecodeBinary
+		}
+		msg, err := decode(frame)
+		if err != nil {
+			t.Fatalf("%s: %v", codec, err)
+		}
+		var in PlayerInputPayload
+		if err := msg.DecodePayload(&in); err != nil {
+			t.Fatalf("%s: %v", codec, err)
+		}
+		if msg.Type != MsgPlayerInput || msg.Tick != 9 || in != sampleInput {
+			t.Fatalf("%s: got type %#x tick %d %+v, want %+v", codec, msg.Type, msg.Tick, in, sampleInput)
+		}
+	}
+}
+
+func TestShortBinaryPayload(t *testing.T) {
+	var in PlayerInputPayload
+	if err := in.UnmarshalBinary([]byte{0xff, 1}); err == nil {
+		t.Fatal("truncated input decoded without error")
+	}
+}
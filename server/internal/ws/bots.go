@@ -0,0 +1,78 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// Bot opponents.
+//
+// /ws?mode=bot[&difficulty=easy|normal|hard] plays a server-side AI right
+// away. Players in the regular queue are also matched with one once they have
+// waited botAfter without finding a human (see SetBotFallback).
+
+// DefaultBotDifficulty is used when neither the player nor SetBotFallback
+// picks one.
+const DefaultBotDifficulty = "normal"
+
+// SetBotFallback makes the regular queue hand a player to a bot of the given
+// difficulty after waiting for after. Zero disables it. Call before serving.
+func (h *Hub) SetBotFallback(after time.Duration, difficulty string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.botAfter = after
+	h.botDifficulty = difficulty
+}
+
+// playBot starts a bot game for a player who asked for one.
+func (h *Hub) playBot(conn *Conn) {
+	if h.full() {
+		conn.Log.Warn("server full, rejecting bot game")
+		go conn.CloseWith(websocket.StatusTryAgainLater, "server full")
+		return
+	}
+	difficulty := conn.BotLevel
+	if difficulty == "" {
+		difficulty = DefaultBotDifficulty
+	}
+	h.startBotRoom(conn, difficulty)
+}
+
+// botFallback hands everyone who has waited botAfter to a bot. Requires h.mu.
+func (h *Hub) botFallback(now time.Time) {
+	if h.botAfter <= 0 {
+		return
+	}
+	q := &h.queue
+	for i := 0; i < len(q.entries); i++ {
+		if h.full() {
+			return
+		}
+		if now.Sub(q.entries[i].joinedAt) < h.botAfter {
+			continue
+		}
+		e := q.take(i, now)
+		i--
+		h.startBotRoom(e.conn, h.botDifficulty, e.released)
+	}
+}
+
+// RematchBot starts a fresh bot game for a player who asked to play again
+// after one.
+func (h *Hub) RematchBot(conn *Conn, difficulty string, released ...<-chan struct{}) {
+	if h.full() {
+		conn.Log.Warn("server full, requeueing bot rematch")
+		h.Requeue(conn, released...)
+		return
+	}
+	h.startBotRoom(conn, difficulty, released...)
+}
+
+// startBotRoom creates a bot room for conn, once the queue entry it was
+// taken from, if any, has released the connection.
+func (h *Hub) startBotRoom(conn *Conn, difficulty string, released ...<-chan struct{}) {
+	h.activeRooms.Add(1)
+	conn.Log.Info("bot match", "difficulty", difficulty, "rooms", h.activeRooms.Load())
+	whenReleased(func() { h.creator.CreateBotRoom(conn, difficulty) }, released...)
+}
@@ -2,11 +2,14 @@ package ws
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/coder/websocket"
+	"github.com/vladimirvolkov/basketball/server/internal/logging"
+	"github.com/vladimirvolkov/basketball/server/internal/metrics"
 	"github.com/vladimirvolkov/basketball/server/internal/middleware"
 )
 
@@ -18,10 +21,26 @@ type Conn struct {
 	ID       string
 	Nickname string
 	IP       string
-	Mode     string // "" for regular, "tournament" for tournament
+	Mode     string // "" for regular, "tournament", "private" or "bot"
+	BotLevel string // difficulty asked for with mode=bot; "" for the server default
+	Rules    string // rules preset asked for when hosting a private room or playing a bot; "" for the server default
+	Codec    Codec  // wire format for outbound messages; set before the first Send
 	limiter  *middleware.IPRateLimiter
+	Log      *slog.Logger // carries conn, ip and, once known, nick
+
+	dropLog   logging.Sampler // "send buffer full" can fire every tick
+	decodeLog logging.Sampler
+
+	ackedTick    atomic.Uint32 // last state tick the client acknowledged (0 = none)
+	keyframeReq  atomic.Bool   // client asked for a full state
+	snapshotRate atomic.Uint32 // states per second the client asked for (0 = the room's)
+
+	readOnce sync.Once
+	incoming chan Message
 }
 
+var sendDropped = metrics.NewCounter("basketball_send_dropped_total", "Outbound messages dropped because a connection's send buffer was full.")
+
 func NewConn(ws *websocket.Conn, id string, ip string, limiter *middleware.IPRateLimiter) *Conn {
 	return &Conn{
 		ws:      ws,
@@ -30,60 +49,138 @@ func NewConn(ws *websocket.Conn, id string, ip string, limiter *middleware.IPRat
 		ID:      id,
 		IP:      ip,
 		limiter: limiter,
+		Log:     slog.With("conn", id, "ip", ip),
 	}
 }
 
+// SetNickname sets Nickname and adds it to Log.
+func (c *Conn) SetNickname(nickname string) {
+	c.Nickname = nickname
+	c.Log = slog.With("conn", c.ID, "ip", c.IP, "nick", nickname)
+}
+
 func (c *Conn) Send(msg Message) {
-	data, err := Encode(msg)
+	data, err := EncodeFor(c.Codec, msg)
 	if err != nil {
-		log.Printf("conn %s: encode error: %v", c.ID, err)
+		c.Log.Error("encode failed", "type", msg.Type, "err", err)
 		return
 	}
+	c.queue(data)
+}
+
+// queue hands data to the write loop, dropping it if the client is too far
+// behind.
+func (c *Conn) queue(data []byte) {
 	select {
 	case c.sendCh <- data:
 	default:
-		log.Printf("conn %s: send buffer full, dropping message", c.ID)
+		sendDropped.Inc()
+		if n, ok := c.dropLog.Allow(); ok {
+			c.Log.Warn("send buffer full, dropping message", "suppressed", n)
+		}
 	}
 }
 
 // SendRaw sends pre-encoded bytes directly, skipping per-connection encoding.
-// Use for broadcast messages that are encoded once and sent to multiple connections.
+// data must already be in c.Codec — use Broadcast to fan out to mixed codecs.
 func (c *Conn) SendRaw(data []byte) {
-	select {
-	case c.sendCh <- data:
-	default:
-		log.Printf("conn %s: send buffer full, dropping message", c.ID)
-	}
+	c.queue(data)
 }
 
-func (c *Conn) ReadLoop(ctx context.Context) <-chan Message {
-	ch := make(chan Message, 128)
-	go func() {
-		defer close(ch)
-		for {
-			_, data, err := c.ws.Read(ctx)
-			if err != nil {
-				log.Printf("conn %s: read error: %v", c.ID, err)
-				c.Close()
-				return
-			}
-			// Per-IP message rate limiting
-			if c.limiter != nil && !c.limiter.MessageAllowed(c.IP) {
-				continue // drop message silently, don't disconnect
+// AckedTick returns the last state tick the client acknowledged.
+func (c *Conn) AckedTick() uint32 {
+	return c.ackedTick.Load()
+}
+
+// TakeKeyframeRequest reports (and clears) a pending keyframe request.
+func (c *Conn) TakeKeyframeRequest() bool {
+	return c.keyframeReq.Swap(false)
+}
+
+// SnapshotRate returns the states per second the client asked for, or 0 for
+// as many as the room sends.
+func (c *Conn) SnapshotRate() int {
+	return int(c.snapshotRate.Load())
+}
+
+// SetSnapshotRate records the states per second the client asked for.
+func (c *Conn) SetSnapshotRate(hz int) {
+	c.snapshotRate.Store(uint32(max(hz, 0)))
+}
+
+// ResetStream forgets the client's acked tick and forces a keyframe. Called
+// when the connection joins a new room, whose ticks restart from zero.
+func (c *Conn) ResetStream() {
+	c.ackedTick.Store(0)
+	c.keyframeReq.Store(true)
+}
+
+// Messages returns the connection's inbound message stream. The read loop
+// starts on the first call and runs until the connection closes, so the same
+// channel is handed from a room back to the hub and on to the next room.
+// The channel is closed when the client goes away.
+func (c *Conn) Messages() <-chan Message {
+	c.readOnce.Do(func() {
+		c.incoming = make(chan Message, 128)
+		go c.readLoop()
+	})
+	return c.incoming
+}
+
+func (c *Conn) readLoop() {
+	ch := c.incoming
+	defer close(ch)
+	for {
+		// Not tied to any room's context: cancelling a Read closes the socket.
+		typ, data, err := c.ws.Read(context.Background())
+		if err != nil {
+			c.Log.Debug("read failed", "err", err)
+			c.Close()
+			return
+		}
+		// Per-IP message rate limiting
+		if c.limiter != nil && !c.limiter.MessageAllowed(c.IP) {
+			continue // drop message silently, don't disconnect
+		}
+		// Accept either frame type regardless of the negotiated codec
+		var msg Message
+		if typ == websocket.MessageBinary {
+			msg, err = DecodeBinary(data)
+		} else {
+			msg, err = Decode(data)
+		}
+		if err != nil {
+			if n, ok := c.decodeLog.Allow(); ok {
+				c.Log.Warn("decode failed", "err", err, "suppressed", n)
 			}
-			msg, err := Decode(data)
-			if err != nil {
-				log.Printf("conn %s: decode error: %v", c.ID, err)
-				continue
+			continue
+		}
+		// State acks and snapshot rates are connection bookkeeping —
+		// handle them here so players, spectators and replays all get
+		// delta compression, and a rate outlives the room it was set in.
+		switch msg.Type {
+		case MsgStateAck:
+			var ack StateAckPayload
+			if msg.DecodePayload(&ack) == nil {
+				c.ackedTick.Store(ack.Tick)
 			}
-			select {
-			case ch <- msg:
-			case <-ctx.Done():
-				return
+			continue
+		case MsgRequestKeyframe:
+			c.keyframeReq.Store(true)
+			continue
+		case MsgSnapshotRate:
+			var rate SnapshotRatePayload
+			if msg.DecodePayload(&rate) == nil {
+				c.snapshotRate.Store(uint32(rate.Rate))
 			}
+			continue
+		}
+		select {
+		case ch <- msg:
+		case <-c.done:
+			return
 		}
-	}()
-	return ch
+	}
 }
 
 func (c *Conn) WriteLoop(ctx context.Context) {
@@ -124,21 +221,40 @@ func (c *Conn) drainSendCh(ctx context.Context) error {
 
 // writeWithTimeout writes a single message with a short deadline.
 func (c *Conn) writeWithTimeout(ctx context.Context, data []byte) error {
+	typ := websocket.MessageText
+	if c.Codec == CodecBinary {
+		typ = websocket.MessageBinary
+	}
 	ctx2, cancel := context.WithTimeout(ctx, 2*time.Second)
-	err := c.ws.Write(ctx2, websocket.MessageText, data)
+	err := c.ws.Write(ctx2, typ, data)
 	cancel()
 	if err != nil {
-		log.Printf("conn %s: write error: %v", c.ID, err)
+		c.Log.Warn("write failed", "err", err)
 		c.Close()
 		return err
 	}
 	return nil
 }
 
+// Application close codes (4000–4999) for sessions the server ends on its
+// own. Clients should not auto-reconnect on these.
+const (
+	CloseRoomExpired   websocket.StatusCode = 4000 // game-over screen timed out
+	CloseIdle          websocket.StatusCode = 4001 // no input for too long
+	CloseInviteExpired websocket.StatusCode = 4002 // nobody used the private room code in time
+	CloseInviteInvalid websocket.StatusCode = 4003 // unknown, used or expired private room code
+)
+
 func (c *Conn) Close() {
+	c.CloseWith(websocket.StatusNormalClosure, "")
+}
+
+// CloseWith closes the connection with a specific close code and reason.
+// Only the first Close/CloseWith takes effect.
+func (c *Conn) CloseWith(code websocket.StatusCode, reason string) {
 	c.once.Do(func() {
 		close(c.done)
-		c.ws.Close(websocket.StatusNormalClosure, "")
+		c.ws.Close(code, reason)
 	})
 }
 
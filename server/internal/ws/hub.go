@@ -3,9 +3,11 @@ package ws
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"regexp"
+	"slices"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -64,9 +66,39 @@ type TournamentMatcher interface {
 	TimesPlayed(nick1, nick2 string) int
 }
 
+// SkillRater rates players for pairing the regular queue (breaks import
+// cycle with game package).
+type SkillRater interface {
+	SkillRating(nickname string) float64
+}
+
 type RoomCreator interface {
 	CreateRoom(p1, p2 *Conn)
 	CreateTournamentRoom(p1, p2 *Conn)
+	// CreateBotRoom seats p against a server-side AI of the given
+	// difficulty ("easy", "normal", "hard").
+	CreateBotRoom(p *Conn, difficulty string)
+}
+
+// Watcher attaches read-only viewers to live rooms and recorded replays
+// (breaks import cycle with game package).
+type Watcher interface {
+	// Spectate attaches conn to a running room and returns immediately.
+	Spectate(conn *Conn, roomID string) error
+	// StreamReplay plays a recorded match and blocks until the viewer leaves.
+	StreamReplay(conn *Conn, id string) error
+}
+
+// Resumer rebinds a reconnecting player to its seat in a live room
+// (breaks import cycle with game package).
+type Resumer interface {
+	Resume(conn *Conn, token string) error
+}
+
+// LoadShedder says when the game engine is too busy to take more rooms
+// (breaks import cycle with game package).
+type LoadShedder interface {
+	RefuseNewRooms() bool
 }
 
 // HubStats holds live server metrics.
@@ -75,23 +107,33 @@ type HubStats struct {
 	TotalConnections    uint64 `json:"totalConnections"`
 	WaitingPlayers      int    `json:"waitingPlayers"`
 	TournamentQueueSize int    `json:"tournamentQueueSize"`
-}
-
-type tournamentEntry struct {
-	conn     *Conn
-	joinedAt time.Time
+	PrivateRooms        int    `json:"privateRooms"` // hosts waiting for a friend
 }
 
 type Hub struct {
 	mu      sync.Mutex
-	waiting *Conn
 	creator RoomCreator
 	nextID  atomic.Uint64
 
+	// Regular queue, matched by skill (see matchmaking.go)
+	queue waitQueue
+
 	// Tournament
-	tournamentQueue []*tournamentEntry
+	tournamentQueue waitQueue
 	tournament      TournamentMatcher
 
+	// Private rooms by invite code (see private.go)
+	privateRooms map[string]*privateRoom
+
+	// Bot opponents (see bots.go)
+	botAfter      time.Duration // regular-queue wait before a bot steps in; 0 never
+	botDifficulty string
+
+	watcher Watcher
+	resumer Resumer
+	shedder LoadShedder // nil never refuses
+	skill   SkillRater  // nil pairs the regular queue by wait time alone
+
 	activeRooms      atomic.Int64
 	totalConnections atomic.Uint64
 
@@ -99,32 +141,55 @@ type Hub struct {
 	originPatterns []string
 }
 
-func NewHub(creator RoomCreator, limiter *middleware.IPRateLimiter, originPatterns []string, tournament TournamentMatcher) *Hub {
+func NewHub(creator RoomCreator, limiter *middleware.IPRateLimiter, originPatterns []string, tournament TournamentMatcher, watcher Watcher, resumer Resumer) *Hub {
 	return &Hub{
 		creator:        creator,
 		limiter:        limiter,
 		originPatterns: originPatterns,
 		tournament:     tournament,
+		watcher:        watcher,
+		resumer:        resumer,
+
+		queue:           waitQueue{fallback: skillFallbackAfter, waits: regularWaitTime},
+		tournamentQueue: waitQueue{fallback: tournamentRematchAfter, waits: tournamentWaitTime},
+		privateRooms:    make(map[string]*privateRoom),
+		botDifficulty:   DefaultBotDifficulty,
 	}
 }
 
 // Stats returns a snapshot of current server metrics.
 func (h *Hub) Stats() HubStats {
 	h.mu.Lock()
-	w := 0
-	if h.waiting != nil {
-		w = 1
-	}
-	tq := len(h.tournamentQueue)
+	w := len(h.queue.entries)
+	tq := len(h.tournamentQueue.entries)
+	pr := len(h.privateRooms)
 	h.mu.Unlock()
 	return HubStats{
 		ActiveRooms:         h.activeRooms.Load(),
 		TotalConnections:    h.totalConnections.Load(),
 		WaitingPlayers:      w,
 		TournamentQueueSize: tq,
+		PrivateRooms:        pr,
 	}
 }
 
+// SetLoadShedder makes the hub turn away new rooms whenever s says so, on
+// top of the maxActiveRooms cap. Call before serving.
+func (h *Hub) SetLoadShedder(s LoadShedder) {
+	h.shedder = s
+}
+
+// SetSkillRater makes the regular queue pair players by s's ratings. Call
+// before serving.
+func (h *Hub) SetSkillRater(s SkillRater) {
+	h.skill = s
+}
+
+// full reports whether a new room must wait or be refused.
+func (h *Hub) full() bool {
+	return h.activeRooms.Load() >= maxActiveRooms || h.shedder != nil && h.shedder.RefuseNewRooms()
+}
+
 // RoomEnded decrements the active room counter. Call when a room goroutine exits.
 func (h *Hub) RoomEnded() {
 	h.activeRooms.Add(-1)
@@ -142,6 +207,7 @@ func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
 		// Disable compression — game state messages are small (<500 bytes)
 		// and compression adds significant CPU overhead at 60 Hz per room.
 		CompressionMode: websocket.CompressionDisabled,
+		Subprotocols:    []string{SubprotocolBinary, SubprotocolJSON},
 	}
 	if len(h.originPatterns) > 0 {
 		acceptOpts.OriginPatterns = h.originPatterns
@@ -152,7 +218,7 @@ func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
 		if h.limiter != nil {
 			h.limiter.Disconnect(ip)
 		}
-		log.Printf("ws accept error: %v", err)
+		slog.Warn("ws accept failed", "ip", ip, "err", err)
 		return
 	}
 
@@ -165,13 +231,17 @@ func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
 
 	// Parse and sanitize nickname from query parameter
 	nickname := sanitizeNickname(r.URL.Query().Get("name"))
-	conn.Nickname = nickname
+	conn.SetNickname(nickname)
 
 	// Parse game mode
 	mode := r.URL.Query().Get("mode")
 	conn.Mode = mode
+	conn.Codec = negotiateCodec(r.URL.Query().Get("codec"), ws.Subprotocol())
+	if rate, err := strconv.Atoi(r.URL.Query().Get("rate")); err == nil {
+		conn.SetSnapshotRate(rate) // the room rounds it to one it can keep to
+	}
 
-	log.Printf("new connection: %s [%s] mode=%s from %s (total: %d)", id, nickname, mode, ip, h.totalConnections.Load())
+	conn.Log.Info("connected", "mode", mode, "codec", conn.Codec.String(), "total", h.totalConnections.Load())
 
 	// Use background context so connection lives beyond HTTP handler
 	go conn.WriteLoop(context.Background())
@@ -184,57 +254,154 @@ func (h *Hub) HandleWS(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	// Route to appropriate matchmaking
-	if mode == "tournament" {
+	// Route to appropriate matchmaking. A dropped player coming back to its
+	// seat falls through to matchmaking if the room is gone or the grace
+	// window has passed.
+	resume := r.URL.Query().Get("resume")
+	switch {
+	case resume != "" && h.resume(conn, resume):
+	case mode == "tournament":
 		h.tryTournamentMatch(conn)
-	} else {
+	case mode == "private":
+		create := r.URL.Query().Get("create") == "1"
+		if create {
+			conn.Rules = r.URL.Query().Get("rules") // the host picks the rules
+		}
+		h.private(conn, create, r.URL.Query().Get("code"))
+	case mode == "bot":
+		conn.BotLevel = r.URL.Query().Get("difficulty")
+		conn.Rules = r.URL.Query().Get("rules")
+		h.playBot(conn)
+	case mode == "spectate":
+		h.spectate(conn, r.URL.Query().Get("room"))
+	case mode == "replay":
+		go h.streamReplay(conn, r.URL.Query().Get("id"))
+	default:
 		h.tryMatch(conn)
 	}
 
 	// Block until the connection is closed — keeps HTTP handler alive
 	// which keeps the underlying TCP connection open for WebSocket
 	<-conn.Done()
-	log.Printf("connection closed: %s", id)
+	conn.Log.Info("disconnected")
 }
 
-func (h *Hub) tryMatch(conn *Conn) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+// negotiateCodec picks the wire format: an explicit ?codec= query parameter
+// wins, then the negotiated subprotocol, then JSON.
+func negotiateCodec(query, subprotocol string) Codec {
+	switch query {
+	case "binary":
+		return CodecBinary
+	case "json":
+		return CodecJSON
+	}
+	if subprotocol == SubprotocolBinary {
+		return CodecBinary
+	}
+	return CodecJSON
+}
 
-	if h.waiting == nil {
-		h.waiting = conn
-		log.Printf("%s waiting for opponent", conn.ID)
+// resume tries to rebind conn to the seat identified by token.
+func (h *Hub) resume(conn *Conn, token string) bool {
+	if h.resumer == nil {
+		return false
+	}
+	if err := h.resumer.Resume(conn, token); err != nil {
+		conn.Log.Info("resume failed", "err", err)
+		return false
+	}
+	return true
+}
 
-		// If this player disconnects while waiting, clean up
-		go func() {
-			<-conn.Done()
-			h.mu.Lock()
-			if h.waiting == conn {
-				h.waiting = nil
-				log.Printf("%s disconnected while waiting", conn.ID)
-			}
-			h.mu.Unlock()
-		}()
+// spectate attaches a read-only viewer to a live room.
+func (h *Hub) spectate(conn *Conn, roomID string) {
+	if h.watcher == nil {
+		conn.ws.Close(websocket.StatusPolicyViolation, "spectating disabled")
+		conn.Close()
 		return
 	}
+	if err := h.watcher.Spectate(conn, roomID); err != nil {
+		conn.Log.Info("spectate failed", "room", roomID, "err", err)
+		conn.ws.Close(websocket.StatusPolicyViolation, err.Error())
+		conn.Close()
+	}
+}
 
-	// Limit active rooms to prevent resource exhaustion
-	if h.activeRooms.Load() >= maxActiveRooms {
-		log.Printf("max rooms reached, rejecting %s", conn.ID)
-		go func() {
-			conn.ws.Close(websocket.StatusTryAgainLater, "server full")
-		}()
+// streamReplay plays a recorded match to a viewer connection.
+func (h *Hub) streamReplay(conn *Conn, id string) {
+	if h.watcher == nil {
+		conn.ws.Close(websocket.StatusPolicyViolation, "replays disabled")
+		conn.Close()
 		return
 	}
+	if err := h.watcher.StreamReplay(conn, id); err != nil {
+		conn.Log.Info("replay failed", "replay", id, "err", err)
+		conn.ws.Close(websocket.StatusPolicyViolation, "replay unavailable")
+	}
+	conn.Close()
+}
 
-	conn.Nickname = deduplicateNickname(h.waiting.Nickname, conn.Nickname)
+// ── Returning from a finished room ──
+
+// Rematch starts a fresh room for two players who both asked to play again
+// on their existing connections. Rematches are never rated, tournament
+// players included: rated pairings go through the tournament queue and its
+// no-repeat rule, and the players stay in tournament mode for their next
+// Play Again. The new room starts once the old one has released the
+// connections (see whenReleased).
+func (h *Hub) Rematch(p1, p2 *Conn, released ...<-chan struct{}) {
+	if h.full() {
+		slog.Warn("server full, requeueing rematch", "conn0", p1.ID, "conn1", p2.ID)
+		h.Requeue(p1, released...)
+		h.Requeue(p2, released...)
+		return
+	}
+	h.activeRooms.Add(1)
+	slog.Info("rematch", "conn0", p1.ID, "nick0", p1.Nickname, "conn1", p2.ID, "nick1", p2.Nickname, "rooms", h.activeRooms.Load())
+	whenReleased(func() { h.creator.CreateRoom(p1, p2) }, released...)
+}
 
-	opponent := h.waiting
-	h.waiting = nil
+// Requeue puts a connection whose room ended back into the matchmaking it
+// originally came from, without a reconnect, once the old room has released
+// it.
+func (h *Hub) Requeue(conn *Conn, released ...<-chan struct{}) {
+	if len(released) > 0 {
+		whenReleased(func() { h.Requeue(conn) }, released...)
+		return
+	}
+	select {
+	case <-conn.Done():
+		return
+	default:
+	}
+	switch conn.Mode {
+	case "tournament":
+		h.tryTournamentMatch(conn)
+	case "private":
+		h.hostPrivate(conn) // a fresh code to share
+	case "bot":
+		h.playBot(conn)
+	default:
+		h.tryMatch(conn)
+	}
+}
 
-	h.activeRooms.Add(1)
-	log.Printf("matched %s [%s] vs %s [%s] (rooms: %d)", opponent.ID, opponent.Nickname, conn.ID, conn.Nickname, h.activeRooms.Load())
-	h.creator.CreateRoom(opponent, conn)
+// Lobby holds a still-connected player whose room ended without a rematch
+// request (opponent left, or the game-over screen timed out). The first
+// MsgJoinQueue ("Play Again") sends it back to matchmaking. It starts
+// reading once the old room has released the connection.
+func (h *Hub) Lobby(conn *Conn, released ...<-chan struct{}) {
+	go func() {
+		for _, c := range released {
+			<-c
+		}
+		for msg := range conn.Messages() {
+			if msg.Type == MsgJoinQueue {
+				h.Requeue(conn)
+				return
+			}
+		}
+	}()
 }
 
 // ── Tournament matchmaking ──
@@ -243,64 +410,50 @@ func (h *Hub) tryTournamentMatch(conn *Conn) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	q := &h.tournamentQueue
+	now := time.Now()
+
 	// Try to find an opponent this player hasn't played before
-	for i, candidate := range h.tournamentQueue {
+	for i, candidate := range q.entries {
 		if candidate.conn == conn {
 			continue
 		}
 		if !h.tournament.HavePlayedBefore(conn.Nickname, candidate.conn.Nickname) {
 			// New opponent found — match them
-			h.tournamentQueue = append(h.tournamentQueue[:i], h.tournamentQueue[i+1:]...)
-			h.startTournamentRoom(candidate.conn, conn)
+			q.take(i, now)
+			h.startTournamentRoom(candidate.conn, conn, candidate.released)
+			q.pushStatus(now)
 			return
 		}
 	}
 
 	// No new opponent available — add to queue
-	entry := &tournamentEntry{conn: conn, joinedAt: time.Now()}
-	h.tournamentQueue = append(h.tournamentQueue, entry)
-	log.Printf("%s [%s] waiting in tournament queue (size: %d)", conn.ID, conn.Nickname, len(h.tournamentQueue))
-
-	// Clean up on disconnect
-	go func() {
-		<-conn.Done()
-		h.mu.Lock()
-		for i, e := range h.tournamentQueue {
-			if e.conn == conn {
-				h.tournamentQueue = append(h.tournamentQueue[:i], h.tournamentQueue[i+1:]...)
-				log.Printf("%s disconnected from tournament queue", conn.ID)
-				break
-			}
-		}
-		h.mu.Unlock()
-	}()
+	e := q.add(conn, 0)
+	conn.Log.Info("waiting in tournament queue", "queue", len(q.entries))
+	q.pushStatus(now)
+	go h.waitInQueue(q, e)
 
 	// 20-second fallback: allow rematch if no new opponent appears
-	go func() {
-		timer := time.NewTimer(20 * time.Second)
-		defer timer.Stop()
+	h.scan(q, h.tournamentFallback)
+}
 
-		select {
-		case <-timer.C:
-			h.tryTournamentRematch(conn)
-		case <-conn.Done():
-			return
+// tournamentFallback offers a rematch to everyone who has waited past
+// tournamentRematchAfter. Requires h.mu.
+func (h *Hub) tournamentFallback(now time.Time) {
+	for _, e := range slices.Clone(h.tournamentQueue.entries) {
+		if now.Sub(e.joinedAt) >= tournamentRematchAfter {
+			h.tryTournamentRematch(e.conn, now)
 		}
-	}()
+	}
 }
 
-func (h *Hub) tryTournamentRematch(conn *Conn) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+// tryTournamentRematch pairs conn with whoever it has played least.
+// Requires h.mu.
+func (h *Hub) tryTournamentRematch(conn *Conn, now time.Time) {
+	q := &h.tournamentQueue
 
 	// Find conn in queue
-	connIdx := -1
-	for i, e := range h.tournamentQueue {
-		if e.conn == conn {
-			connIdx = i
-			break
-		}
-	}
+	connIdx := slices.IndexFunc(q.entries, func(e *queueEntry) bool { return e.conn == conn })
 	if connIdx == -1 {
 		return // already matched or disconnected
 	}
@@ -308,7 +461,7 @@ func (h *Hub) tryTournamentRematch(conn *Conn) {
 	// Find any other player in queue, preferring least-played opponent
 	bestIdx := -1
 	bestCount := int(^uint(0) >> 1) // max int
-	for i, candidate := range h.tournamentQueue {
+	for i, candidate := range q.entries {
 		if i == connIdx {
 			continue
 		}
@@ -323,23 +476,20 @@ func (h *Hub) tryTournamentRematch(conn *Conn) {
 		return // still alone in queue
 	}
 
-	opponent := h.tournamentQueue[bestIdx]
+	opponent, self := q.entries[bestIdx], q.entries[connIdx]
 
 	// Remove both from queue (higher index first to avoid shifting issues)
-	if bestIdx > connIdx {
-		h.tournamentQueue = append(h.tournamentQueue[:bestIdx], h.tournamentQueue[bestIdx+1:]...)
-		h.tournamentQueue = append(h.tournamentQueue[:connIdx], h.tournamentQueue[connIdx+1:]...)
-	} else {
-		h.tournamentQueue = append(h.tournamentQueue[:connIdx], h.tournamentQueue[connIdx+1:]...)
-		h.tournamentQueue = append(h.tournamentQueue[:bestIdx], h.tournamentQueue[bestIdx+1:]...)
-	}
+	q.take(max(bestIdx, connIdx), now)
+	q.take(min(bestIdx, connIdx), now)
 
-	h.startTournamentRoom(opponent.conn, conn)
+	h.startTournamentRoom(opponent.conn, conn, opponent.released, self.released)
 }
 
-func (h *Hub) startTournamentRoom(p1, p2 *Conn) {
-	if h.activeRooms.Load() >= maxActiveRooms {
-		log.Printf("max rooms reached, rejecting tournament match %s vs %s", p1.ID, p2.ID)
+// startTournamentRoom creates a rated room for p1 and p2 once the queue
+// entries they were matched from have released their connections.
+func (h *Hub) startTournamentRoom(p1, p2 *Conn, released ...<-chan struct{}) {
+	if h.full() {
+		slog.Warn("server full, rejecting tournament match", "conn0", p1.ID, "conn1", p2.ID)
 		go func() {
 			p1.ws.Close(websocket.StatusTryAgainLater, "server full")
 			p2.ws.Close(websocket.StatusTryAgainLater, "server full")
@@ -347,9 +497,9 @@ func (h *Hub) startTournamentRoom(p1, p2 *Conn) {
 		return
 	}
 
-	p2.Nickname = deduplicateNickname(p1.Nickname, p2.Nickname)
+	p2.SetNickname(deduplicateNickname(p1.Nickname, p2.Nickname))
 
 	h.activeRooms.Add(1)
-	log.Printf("tournament matched %s [%s] vs %s [%s] (rooms: %d)", p1.ID, p1.Nickname, p2.ID, p2.Nickname, h.activeRooms.Load())
-	h.creator.CreateTournamentRoom(p1, p2)
+	slog.Info("tournament match", "conn0", p1.ID, "nick0", p1.Nickname, "conn1", p2.ID, "nick1", p2.Nickname, "rooms", h.activeRooms.Load())
+	whenReleased(func() { h.creator.CreateTournamentRoom(p1, p2) }, released...)
 }
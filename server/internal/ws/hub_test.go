@@ -0,0 +1,163 @@
+package ws
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCreator records the rooms the hub asks for. If onCreate is set it
+// runs in the creating goroutine, standing in for a room starting up.
+type fakeCreator struct {
+	mu       sync.Mutex
+	rooms    []string // "casual", "tournament" or "bot"
+	seats    [][2]*Conn
+	onCreate func(p1, p2 *Conn)
+	created  chan struct{}
+}
+
+func (f *fakeCreator) add(kind string, p1, p2 *Conn) {
+	f.mu.Lock()
+	f.rooms = append(f.rooms, kind)
+	f.seats = append(f.seats, [2]*Conn{p1, p2})
+	f.mu.Unlock()
+	if f.onCreate != nil {
+		f.onCreate(p1, p2)
+	}
+	if f.created != nil {
+		f.created <- struct{}{}
+	}
+}
+
+func (f *fakeCreator) CreateRoom(p1, p2 *Conn)           { f.add("casual", p1, p2) }
+func (f *fakeCreator) CreateTournamentRoom(p1, p2 *Conn) { f.add("tournament", p1, p2) }
+func (f *fakeCreator) CreateBotRoom(p *Conn, _ string)   { f.add("bot", p, nil) }
+
+// testConn returns a Conn without a socket. Its inbound messages come from
+// the returned channel instead of a read loop.
+func testConn(id, nick, mode string) *Conn {
+	c := NewConn(nil, id, "127.0.0.1", nil)
+	c.Nickname, c.Mode = nick, mode
+	c.readOnce.Do(func() { c.incoming = make(chan Message, 8) })
+	return c
+}
+
+func TestTournamentRematchIsUnrated(t *testing.T) {
+	fc := &fakeCreator{}
+	h := NewHub(fc, nil, nil, nil, nil, nil)
+	p1, p2 := testConn("c1", "Alice", "tournament"), testConn("c2", "Bob", "tournament")
+	fc.created = make(chan struct{}, 1)
+	h.Rematch(p1, p2)
+	<-fc.created
+	if len(fc.rooms) != 1 || fc.rooms[0] != "casual" {
+		t.Fatalf("rematch created %v, want one casual room", fc.rooms)
+	}
+	if p1.Mode != "tournament" || p2.Mode != "tournament" {
+		t.Fatal("rematch took the players out of tournament mode")
+	}
+}
+
+// TestMatchHandsOffConnections checks that a matched player's queue reader
+// has stopped before its room is created, so the room's read loop is the only
+// reader of the connection and can't lose the first inputs to the queue.
+func TestMatchHandsOffConnections(t *testing.T) {
+	fc := &fakeCreator{created: make(chan struct{}, 1)}
+	h := NewHub(fc, nil, nil, nil, nil, nil)
+	h.tryMatch(testConn("c1", "Alice", ""))
+	h.mu.Lock()
+	alice := h.queue.entries[0]
+	h.mu.Unlock()
+
+	fc.onCreate = func(p1, p2 *Conn) {
+		select {
+		case <-alice.released:
+		default:
+			t.Error("room created while Alice's queue reader was still running")
+		}
+	}
+	h.tryMatch(testConn("c2", "Bob", ""))
+	select {
+	case <-fc.created:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no room created")
+	}
+}
+
+// TestRematchWaitsForOldRoom checks that a rematch room isn't created while
+// the finished room's read loops may still be reading the connections.
+func TestRematchWaitsForOldRoom(t *testing.T) {
+	fc := &fakeCreator{created: make(chan struct{}, 1)}
+	h := NewHub(fc, nil, nil, nil, nil, nil)
+	released := make(chan struct{})
+	h.Rematch(testConn("c1", "Alice", ""), testConn("c2", "Bob", ""), released)
+
+	select {
+	case <-fc.created:
+		t.Fatal("rematch room created before the old room released its players")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(released)
+	select {
+	case <-fc.created:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no rematch room after the old room released its players")
+	}
+}
+
+func TestSkillWindowWidens(t *testing.T) {
+	prev := skillWindow(0)
+	if prev != skillWindowBase {
+		t.Fatalf("window on joining %v, want %v", prev, skillWindowBase)
+	}
+	for w := time.Second; w < skillFallbackAfter; w += time.Second {
+		if got := skillWindow(w); got <= prev {
+			t.Fatalf("window after %v is %v, not wider than %v", w, got, prev)
+		} else {
+			prev = got
+		}
+	}
+	if got := skillWindow(skillFallbackAfter); !math.IsInf(got, 1) {
+		t.Fatalf("window after the fallback %v, want anyone", got)
+	}
+}
+
+// queued adds a player to h's regular queue with no reader to wait for.
+func queued(h *Hub, nick string, rating float64, joined time.Time) *queueEntry {
+	e := h.queue.add(testConn("c-"+nick, nick, ""), rating)
+	e.joinedAt = joined
+	close(e.released)
+	return e
+}
+
+func TestMatchQueuePairsClosestInWindow(t *testing.T) {
+	fc := &fakeCreator{created: make(chan struct{}, 2)}
+	h := NewHub(fc, nil, nil, nil, nil, nil)
+	now := time.Now()
+	h.mu.Lock()
+	alice := queued(h, "Alice", 1500, now)
+	bob := queued(h, "Bob", 1580, now)
+	carol := queued(h, "Carol", 1520, now)
+	dave := queued(h, "Dave", 1900, now)
+
+	h.matchQueue(now)
+	if len(h.queue.entries) != 2 || h.queue.entries[0] != bob || h.queue.entries[1] != dave {
+		t.Fatal("want Alice paired with Carol, the closest, and Dave out of everyone's window")
+	}
+	// Ten seconds on, Bob's window reaches Dave.
+	h.matchQueue(now.Add(10 * time.Second))
+	if len(h.queue.entries) != 0 {
+		t.Fatalf("%d players still queued after the windows widened", len(h.queue.entries))
+	}
+	h.mu.Unlock()
+
+	want := map[[2]*Conn]bool{{alice.conn, carol.conn}: true, {bob.conn, dave.conn}: true}
+	for range 2 {
+		<-fc.created
+	}
+	for _, s := range fc.seats {
+		if !want[s] {
+			t.Fatalf("paired %s with %s", s[0].Nickname, s[1].Nickname)
+		}
+	}
+}
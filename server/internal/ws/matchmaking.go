@@ -0,0 +1,272 @@
+package ws
+
+import (
+	"log/slog"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/vladimirvolkov/basketball/server/internal/metrics"
+)
+
+// Skill-based matchmaking for the regular queue.
+//
+// Waiting players form a pool. Two players are paired when their ratings are
+// within the search window of either of them; the window starts narrow and
+// widens the longer a player waits, until after skillFallbackAfter anyone will
+// do (like the tournament rematch fallback).
+//
+// Ratings come from the hub's SkillRater (game.RecentForm): a tournament
+// player's rating, otherwise one worked out from the player's recent casual
+// win rate. A newcomer queues at the default rating.
+//
+// Each queued player has a reader goroutine (waitInQueue) watching its
+// connection for MsgLeaveQueue. A matched pair's room is only created once
+// both readers have returned, so the room's read loop is the only one
+// reading the connection and no input is lost to the queue.
+//
+// Both queues keep their players informed with MsgQueueStatus: sent on
+// joining and whenever position, size, elapsed wait, estimate or fallback
+// state change. A queued player can back out with MsgLeaveQueue and stays
+// connected in the lobby.
+
+const (
+	skillWindowBase        = 100.0            // rating points either side on joining
+	skillWindowGrowth      = 25.0             // rating points added per second waited
+	skillFallbackAfter     = 20 * time.Second // then match with anyone
+	tournamentRematchAfter = 20 * time.Second // then allow a repeat opponent
+	matchInterval          = time.Second      // how often a queue is re-scanned
+)
+
+// Time from joining a queue to being matched (a bot counts), per queue.
+var (
+	waitBuckets        = []float64{1, 2, 5, 10, 15, 20, 30, 45, 60, 90, 120, 300}
+	regularWaitTime    = metrics.NewHistogram("basketball_matchmaking_wait_seconds", "Time players spent queued before being matched.", waitBuckets, "queue", "regular")
+	tournamentWaitTime = metrics.NewHistogram("basketball_matchmaking_wait_seconds", "Time players spent queued before being matched.", waitBuckets, "queue", "tournament")
+)
+
+type queueEntry struct {
+	conn     *Conn
+	rating   float64
+	joinedAt time.Time
+	dequeued chan struct{} // closed when the entry leaves its queue
+	released chan struct{} // closed when waitInQueue stops reading conn
+
+	sent QueueStatusPayload // last status sent, so unchanged values aren't resent
+}
+
+// waitQueue is a list of waiting players in join order. All access requires
+// Hub.mu.
+type waitQueue struct {
+	entries  []*queueEntry
+	fallback time.Duration // wait after which the queue stops being picky
+	avgWait  time.Duration // recent time-to-match, for wait estimates
+	scanning bool          // a scan loop is running
+	waits    *metrics.Histogram
+}
+
+func (q *waitQueue) add(conn *Conn, rating float64) *queueEntry {
+	e := &queueEntry{conn: conn, rating: rating, joinedAt: time.Now(), dequeued: make(chan struct{}), released: make(chan struct{})}
+	q.entries = append(q.entries, e)
+	return e
+}
+
+// take removes the entry at i because it was matched, and folds its wait
+// into the estimate.
+func (q *waitQueue) take(i int, now time.Time) *queueEntry {
+	e := q.entries[i]
+	q.entries = slices.Delete(q.entries, i, i+1)
+	close(e.dequeued)
+
+	w := now.Sub(e.joinedAt)
+	q.waits.Observe(w.Seconds())
+	if q.avgWait == 0 {
+		q.avgWait = w
+	} else {
+		q.avgWait += (w - q.avgWait) / 8
+	}
+	return e
+}
+
+// drop removes conn without a match. Reports whether it was queued.
+func (q *waitQueue) drop(conn *Conn) bool {
+	i := slices.IndexFunc(q.entries, func(e *queueEntry) bool { return e.conn == conn })
+	if i < 0 {
+		return false
+	}
+	close(q.entries[i].dequeued)
+	q.entries = slices.Delete(q.entries, i, i+1)
+	return true
+}
+
+// pushStatus sends MsgQueueStatus to each waiting player whose status
+// changed.
+func (q *waitQueue) pushStatus(now time.Time) {
+	size := clampU16(float64(len(q.entries)))
+	for i, e := range q.entries {
+		waited := now.Sub(e.joinedAt)
+		var estimate time.Duration
+		if q.avgWait > 0 {
+			estimate = max(q.avgWait-waited, 0)
+		}
+		status := QueueStatusPayload{
+			Position:      clampU16(float64(i + 1)),
+			QueueSize:     size,
+			Elapsed:       clampU16(waited.Seconds()),
+			EstimatedWait: clampU16(math.Ceil(estimate.Seconds())),
+			Fallback:      waited >= q.fallback,
+		}
+		if status == e.sent {
+			continue
+		}
+		e.sent = status
+		e.conn.Send(NewMessage(MsgQueueStatus, 0, status))
+	}
+}
+
+func clampU16(v float64) uint16 {
+	return uint16(min(v, math.MaxUint16))
+}
+
+// scan runs match over q once a second, pushing status after each pass,
+// until q is empty. Requires h.mu.
+func (h *Hub) scan(q *waitQueue, match func(now time.Time)) {
+	if q.scanning || len(q.entries) == 0 {
+		return
+	}
+	q.scanning = true
+	go func() {
+		ticker := time.NewTicker(matchInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			h.mu.Lock()
+			match(now)
+			q.pushStatus(now)
+			if len(q.entries) == 0 {
+				q.scanning = false
+				h.mu.Unlock()
+				return
+			}
+			h.mu.Unlock()
+		}
+	}()
+}
+
+// waitInQueue watches a queued player until it leaves q: disconnecting or
+// sending MsgLeaveQueue takes it out; the latter parks it in the lobby.
+// Every queued entry must have one running, since whenReleased waits for it.
+func (h *Hub) waitInQueue(q *waitQueue, e *queueEntry) {
+	defer close(e.released)
+	msgs := e.conn.Messages()
+	for {
+		select {
+		case <-e.dequeued:
+			return
+		case <-e.conn.Done():
+			h.leaveQueue(q, e.conn, "disconnected while waiting")
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				msgs = nil // closing; Done follows
+				continue
+			}
+			if msg.Type == MsgLeaveQueue && h.leaveQueue(q, e.conn, "left the queue") {
+				h.Lobby(e.conn)
+				return
+			}
+		}
+	}
+}
+
+// whenReleased runs start once every released channel is closed: the
+// matched players' waiting goroutines (waitInQueue, waitForGuest), or the
+// read loops of the room they just finished, have stopped reading their
+// connections, so the room start creates is the only reader. start runs on its own goroutine, without h.mu.
+func whenReleased(start func(), released ...<-chan struct{}) {
+	go func() {
+		for _, c := range released {
+			<-c
+		}
+		start()
+	}()
+}
+
+func (h *Hub) leaveQueue(q *waitQueue, conn *Conn, why string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !q.drop(conn) {
+		return false
+	}
+	conn.Log.Info(why)
+	q.pushStatus(time.Now())
+	return true
+}
+
+// ── Regular queue ──
+
+// skillWindow is how far apart two ratings may be for a player who has
+// waited this long.
+func skillWindow(waited time.Duration) float64 {
+	if waited >= skillFallbackAfter {
+		return math.Inf(1)
+	}
+	return skillWindowBase + skillWindowGrowth*waited.Seconds()
+}
+
+// skillRating is the rating the regular queue pairs a player by; see the
+// note at the top of this file.
+func (h *Hub) skillRating(nickname string) float64 {
+	if h.skill == nil {
+		return 0
+	}
+	return h.skill.SkillRating(nickname)
+}
+
+func (h *Hub) tryMatch(conn *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e := h.queue.add(conn, h.skillRating(conn.Nickname))
+	conn.Log.Info("waiting for opponent", "rating", math.Round(e.rating), "queue", len(h.queue.entries))
+	go h.waitInQueue(&h.queue, e)
+
+	now := time.Now()
+	h.matchQueue(now)
+	h.queue.pushStatus(now)
+	// Whoever is left is re-scanned as their windows widen.
+	h.scan(&h.queue, h.matchQueue)
+}
+
+// matchQueue pairs every compatible pair of waiting players, longest-waiting
+// first, each with the closest rating in range. Requires h.mu.
+func (h *Hub) matchQueue(now time.Time) {
+	q := &h.queue
+	for i := 0; i < len(q.entries); i++ {
+		// Leave everyone queued until a room frees up.
+		if h.full() {
+			break
+		}
+		a := q.entries[i]
+		best, bestGap := -1, math.Inf(1)
+		for j := i + 1; j < len(q.entries); j++ {
+			b := q.entries[j]
+			gap := math.Abs(a.rating - b.rating)
+			window := max(skillWindow(now.Sub(a.joinedAt)), skillWindow(now.Sub(b.joinedAt)))
+			if gap <= window && gap < bestGap {
+				best, bestGap = j, gap
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		b := q.take(best, now)
+		q.take(i, now)
+		i--
+
+		b.conn.SetNickname(deduplicateNickname(a.conn.Nickname, b.conn.Nickname))
+		h.activeRooms.Add(1)
+		slog.Info("match", "conn0", a.conn.ID, "nick0", a.conn.Nickname, "conn1", b.conn.ID, "nick1", b.conn.Nickname, "gap", math.Round(bestGap), "rooms", h.activeRooms.Load())
+		whenReleased(func() { h.creator.CreateRoom(a.conn, b.conn) }, a.released, b.released)
+	}
+	h.botFallback(now)
+}
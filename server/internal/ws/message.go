@@ -2,15 +2,22 @@ package ws
 
 import (
 	"bytes"
+	"encoding"
 	"encoding/json"
+	"fmt"
 	"sync"
+	"time"
 )
 
 // Client -> Server message types
 const (
-	MsgPlayerInput uint8 = 0x01
-	MsgJoinQueue   uint8 = 0x02
-	MsgPing        uint8 = 0x04
+	MsgPlayerInput     uint8 = 0x01
+	MsgJoinQueue       uint8 = 0x02
+	MsgPing            uint8 = 0x04
+	MsgStateAck        uint8 = 0x05 // client has applied the state for a tick (delta base)
+	MsgRequestKeyframe uint8 = 0x06 // client lost sync — next state must be a full keyframe
+	MsgLeaveQueue      uint8 = 0x07 // stop matchmaking but keep the connection
+	MsgSnapshotRate    uint8 = 0x08 // ask for fewer (or the default number of) states per second
 )
 
 // Server -> Client message types
@@ -22,24 +29,45 @@ const (
 	MsgPong               uint8 = 0x86
 	MsgPlayerDisconnected uint8 = 0x87
 	MsgTournamentResult   uint8 = 0x88
+	MsgReplayFrame        uint8 = 0x89 // payload is a GameState, same as MsgGameState
+	MsgGameStateDelta     uint8 = 0x8A // binary only: changed fields since an acked tick
+	MsgRematchOffer       uint8 = 0x8B // opponent pressed Play Again after game over
+	MsgMatchPaused        uint8 = 0x8C // a player dropped; game frozen while they may resume
+	MsgMatchResumed       uint8 = 0x8D // the dropped player is back
+	MsgQueueStatus        uint8 = 0x8E // where a waiting player stands in its matchmaking queue
+	MsgRoomCode           uint8 = 0x8F // invite code for a private room the player is hosting
 )
 
 type Message struct {
 	Type    uint8           `json:"type"`
 	Tick    uint32          `json:"tick"`
 	Payload json.RawMessage `json:"payload"`
+
+	body any    // outbound payload; encoded per connection codec on send
+	bin  []byte // inbound binary payload (nil for JSON messages)
 }
 
 type PlayerInputPayload struct {
-	MoveX int8 `json:"moveX"`
-	Jump  bool `json:"jump"`
-	Shoot bool `json:"shoot"`
+	MoveX int8   `json:"moveX"`
+	Jump  bool   `json:"jump"`
+	Shoot bool   `json:"shoot"`
+	Seq   uint32 `json:"seq"` // increasing per input; echoed back in GameState.inputSeq
 }
 
 type JoinQueuePayload struct {
 	Name string `json:"name"`
 }
 
+type StateAckPayload struct {
+	Tick uint32 `json:"tick"`
+}
+
+// SnapshotRatePayload asks for Rate states per second; 0 takes the room's
+// rate. The server rounds it to one it can keep to.
+type SnapshotRatePayload struct {
+	Rate uint8 `json:"rate"`
+}
+
 type PingPayload struct {
 	ClientTime uint64 `json:"clientTime"`
 }
@@ -48,22 +76,62 @@ type GameStartPayload struct {
 	PlayerIndex  uint8     `json:"playerIndex"`
 	Names        [2]string `json:"names"`
 	IsTournament bool      `json:"isTournament,omitempty"`
+	Seed         int64     `json:"seed"`                // room RNG seed — same seed + same inputs = same game
+	Spectator    bool      `json:"spectator,omitempty"` // read-only viewer; input is ignored
+	// ResumeToken lets this player reconnect to the same seat with
+	// /ws?resume=<token> if the connection drops mid-game.
+	ResumeToken string `json:"resumeToken,omitempty"`
+	Rules       string `json:"rules,omitempty"` // name of the rules preset the room plays by
+	// SnapshotRate is how many states per second this client is sent;
+	// events still arrive on the tick they happen.
+	SnapshotRate uint8 `json:"snapshotRate,omitempty"`
+	// TickRate is how many ticks the room simulates per second: what state
+	// ticks and steal cooldowns count.
+	TickRate uint8 `json:"tickRate,omitempty"`
 }
 
 type TournamentPlayerStats struct {
-	Nickname    string `json:"nickname"`
-	Wins        int    `json:"wins"`
-	Losses      int    `json:"losses"`
-	Draws       int    `json:"draws"`
-	PointsFor   int    `json:"pointsFor"`
-	GamesPlayed int    `json:"gamesPlayed"`
+	Nickname    string  `json:"nickname"`
+	Wins        int     `json:"wins"`
+	Losses      int     `json:"losses"`
+	Draws       int     `json:"draws"`
+	PointsFor   int     `json:"pointsFor"`
+	GamesPlayed int     `json:"gamesPlayed"`
+	Forfeits    int     `json:"forfeits"`
+	Rating      float32 `json:"rating"`
+	RatingDev   float32 `json:"ratingDeviation"`
 }
 
 type TournamentResultPayload struct {
 	YourStats     TournamentPlayerStats `json:"yourStats"`
 	OpponentStats TournamentPlayerStats `json:"opponentStats"`
+	// Rating points gained (positive) or lost in this game.
+	YourRatingChange     float32 `json:"yourRatingChange"`
+	OpponentRatingChange float32 `json:"opponentRatingChange"`
+}
+
+type ScoredPayload struct {
+	ScorerIndex uint8    `json:"scorerIndex"`
+	Points      uint8    `json:"points"`
+	NewScore    [2]uint8 `json:"newScore"`
 }
 
+type GameOverPayload struct {
+	Winner  int8     `json:"winner"`
+	Score   [2]uint8 `json:"score"`
+	Forfeit bool     `json:"forfeit,omitempty"` // loser didn't come back in time
+	Reason  string   `json:"reason"`            // one of the GameOver* reasons below
+}
+
+// Why a game ended, as GameOverPayload.Reason.
+const (
+	GameOverClock       = "clock"        // regulation ran out
+	GameOverScoreTarget = "score-target" // first to the rules' target
+	GameOverWinByTwo    = "win-by-two"   // target reached, then a two-point lead
+	GameOverOvertime    = "overtime"     // first basket of sudden-death overtime
+	GameOverForfeit     = "forfeit"      // a player didn't come back in time
+)
+
 type PongPayload struct {
 	ClientTime uint64 `json:"clientTime"`
 	ServerTime uint64 `json:"serverTime"`
@@ -73,6 +141,35 @@ type PlayerDisconnectedPayload struct {
 	PlayerIndex uint8 `json:"playerIndex"`
 }
 
+type MatchPausedPayload struct {
+	PlayerIndex uint8   `json:"playerIndex"` // who dropped
+	Grace       float32 `json:"grace"`       // seconds they have to resume before forfeiting
+}
+
+type MatchResumedPayload struct {
+	PlayerIndex uint8 `json:"playerIndex"`
+}
+
+type RematchOfferPayload struct {
+	PlayerIndex uint8 `json:"playerIndex"` // who asked
+}
+
+type QueueStatusPayload struct {
+	Position      uint16 `json:"position"` // 1 = next in line
+	QueueSize     uint16 `json:"queueSize"`
+	Elapsed       uint16 `json:"elapsed"`       // seconds waited so far
+	EstimatedWait uint16 `json:"estimatedWait"` // seconds; 0 when there is no estimate yet
+	// Fallback is set once the player has waited long enough that the queue
+	// stops being picky: any rating in the regular queue, a repeat opponent
+	// in the tournament.
+	Fallback bool `json:"fallback"`
+}
+
+type RoomCodePayload struct {
+	Code      string `json:"code"`
+	ExpiresIn uint16 `json:"expiresIn"` // seconds until the code stops working
+}
+
 // bufPool recycles encoding buffers to reduce GC pressure in the hot path.
 // At 60 Hz × 100 rooms, this avoids ~12 000 alloc/s from json.Marshal.
 var bufPool = sync.Pool{
@@ -81,23 +178,15 @@ var bufPool = sync.Pool{
 
 // Encode serializes a Message to JSON using a pooled buffer.
 func Encode(msg Message) ([]byte, error) {
-	buf := bufPool.Get().(*bytes.Buffer)
-	buf.Reset()
-	err := json.NewEncoder(buf).Encode(msg)
-	if err != nil {
-		bufPool.Put(buf)
-		return nil, err
+	defer encodeTimeJSON.ObserveSince(time.Now())
+	if msg.Payload == nil && msg.body != nil {
+		payload, err := marshalJSON(msg.body)
+		if err != nil {
+			return nil, err
+		}
+		msg.Payload = payload
 	}
-	// json.Encoder.Encode appends '\n' — trim it for clean WS messages.
-	raw := buf.Bytes()
-	if len(raw) > 0 && raw[len(raw)-1] == '\n' {
-		raw = raw[:len(raw)-1]
-	}
-	// Copy out so the buffer can be returned to the pool immediately.
-	out := make([]byte, len(raw))
-	copy(out, raw)
-	bufPool.Put(buf)
-	return out, nil
+	return marshalJSON(msg)
 }
 
 func Decode(data []byte) (Message, error) {
@@ -106,25 +195,55 @@ func Decode(data []byte) (Message, error) {
 	return msg, err
 }
 
-// NewMessage constructs a Message with a JSON-encoded payload (pooled buffer).
-func NewMessage(typ uint8, tick uint32, payload any) (Message, error) {
+// NewMessage constructs an outbound Message. The payload is encoded later by
+// Encode or EncodeBinary, so each connection pays only for its own codec.
+func NewMessage(typ uint8, tick uint32, payload any) Message {
+	return Message{
+		Type: typ,
+		Tick: tick,
+		body: payload,
+	}
+}
+
+// DecodePayload unmarshals an inbound payload into v, using whichever codec
+// the message arrived in. Binary payloads require v to implement
+// encoding.BinaryUnmarshaler. A message built with NewMessage and handed over
+// in-process is decoded from its JSON form.
+func (m Message) DecodePayload(v any) error {
+	if m.Payload == nil && m.body != nil {
+		payload, err := marshalJSON(m.body)
+		if err != nil {
+			return err
+		}
+		m.Payload = payload
+	}
+	if m.bin != nil {
+		u, ok := v.(encoding.BinaryUnmarshaler)
+		if !ok {
+			return fmt.Errorf("message 0x%02x: %T has no binary form", m.Type, v)
+		}
+		return u.UnmarshalBinary(m.bin)
+	}
+	return json.Unmarshal(m.Payload, v)
+}
+
+// marshalJSON encodes v with a pooled buffer and returns a trimmed copy.
+func marshalJSON(v any) ([]byte, error) {
 	buf := bufPool.Get().(*bytes.Buffer)
 	buf.Reset()
-	err := json.NewEncoder(buf).Encode(payload)
+	err := json.NewEncoder(buf).Encode(v)
 	if err != nil {
 		bufPool.Put(buf)
-		return Message{}, err
+		return nil, err
 	}
+	// json.Encoder.Encode appends '\n' — trim it for clean WS messages.
 	raw := buf.Bytes()
 	if len(raw) > 0 && raw[len(raw)-1] == '\n' {
 		raw = raw[:len(raw)-1]
 	}
-	data := make([]byte, len(raw))
-	copy(data, raw)
+	// Copy out so the buffer can be returned to the pool immediately.
+	out := make([]byte, len(raw))
+	copy(out, raw)
 	bufPool.Put(buf)
-	return Message{
-		Type:    typ,
-		Tick:    tick,
-		Payload: json.RawMessage(data),
-	}, nil
+	return out, nil
 }
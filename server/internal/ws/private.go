@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"crypto/rand"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// Private rooms.
+//
+// /ws?mode=private&create=1 hosts a room: the player gets a short invite code
+// in MsgRoomCode and waits. A friend connecting with /ws?mode=private&code=XYZ
+// joins that exact room. Codes are single-use and expire after privateCodeTTL;
+// the host can withdraw one early with MsgLeaveQueue.
+
+const (
+	privateCodeTTL      = 10 * time.Minute
+	privateCodeLen      = 5
+	privateCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O or 1/I
+)
+
+type privateRoom struct {
+	host     *Conn
+	expires  time.Time
+	closed   chan struct{} // closed once the code is used or withdrawn
+	released chan struct{} // closed when waitForGuest stops reading host
+}
+
+func (h *Hub) private(conn *Conn, create bool, code string) {
+	switch {
+	case code != "":
+		h.joinPrivate(conn, code)
+	case create:
+		h.hostPrivate(conn)
+	default:
+		go conn.CloseWith(CloseInviteInvalid, "missing room code")
+	}
+}
+
+// newPrivateCode returns an unused invite code. Requires h.mu.
+func (h *Hub) newPrivateCode() string {
+	for {
+		var b [privateCodeLen]byte
+		rand.Read(b[:])
+		for i := range b {
+			b[i] = privateCodeAlphabet[int(b[i])%len(privateCodeAlphabet)]
+		}
+		if code := string(b[:]); h.privateRooms[code] == nil {
+			return code
+		}
+	}
+}
+
+func (h *Hub) hostPrivate(conn *Conn) {
+	p := &privateRoom{host: conn, expires: time.Now().Add(privateCodeTTL), closed: make(chan struct{}), released: make(chan struct{})}
+	h.mu.Lock()
+	code := h.newPrivateCode()
+	h.privateRooms[code] = p
+	h.mu.Unlock()
+
+	conn.Log.Info("hosting private room", "code", code)
+	conn.Send(NewMessage(MsgRoomCode, 0, RoomCodePayload{
+		Code:      code,
+		ExpiresIn: clampU16(privateCodeTTL.Seconds()),
+	}))
+	go h.waitForGuest(code, p)
+}
+
+// waitForGuest watches a host until its code is used, expires or is
+// withdrawn.
+func (h *Hub) waitForGuest(code string, p *privateRoom) {
+	defer close(p.released)
+	timer := time.NewTimer(time.Until(p.expires))
+	defer timer.Stop()
+	msgs := p.host.Messages()
+	for {
+		select {
+		case <-p.closed:
+			return
+		case <-p.host.Done():
+			h.withdrawPrivate(code, p, "host disconnected")
+			return
+		case <-timer.C:
+			if h.withdrawPrivate(code, p, "expired") {
+				p.host.CloseWith(CloseInviteExpired, "room code expired")
+			}
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				msgs = nil // closing; Done follows
+				continue
+			}
+			if msg.Type == MsgLeaveQueue && h.withdrawPrivate(code, p, "withdrawn") {
+				h.Lobby(p.host)
+				return
+			}
+		}
+	}
+}
+
+// withdrawPrivate removes an unused code. Reports whether it was still open.
+func (h *Hub) withdrawPrivate(code string, p *privateRoom, why string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.privateRooms[code] != p {
+		return false
+	}
+	delete(h.privateRooms, code)
+	close(p.closed)
+	slog.Info("private room closed", "code", code, "why", why)
+	return true
+}
+
+func (h *Hub) joinPrivate(conn *Conn, code string) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p := h.privateRooms[code]
+	if p == nil || time.Now().After(p.expires) {
+		conn.Log.Info("no such private room", "code", code)
+		go conn.CloseWith(CloseInviteInvalid, "unknown or expired room code")
+		return
+	}
+
+	// Limit active rooms to prevent resource exhaustion. The code stays
+	// valid so the friend can try again.
+	if h.full() {
+		conn.Log.Warn("server full, rejecting private room guest", "code", code)
+		go conn.CloseWith(websocket.StatusTryAgainLater, "server full")
+		return
+	}
+
+	delete(h.privateRooms, code)
+	close(p.closed)
+
+	conn.SetNickname(deduplicateNickname(p.host.Nickname, conn.Nickname))
+
+	h.activeRooms.Add(1)
+	slog.Info("private match", "code", code, "conn0", p.host.ID, "nick0", p.host.Nickname, "conn1", conn.ID, "nick1", conn.Nickname, "rooms", h.activeRooms.Load())
+	whenReleased(func() { h.creator.CreateRoom(p.host, conn) }, p.released)
+}